@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/config"
+	"github.com/WhiCu/p2pFileShare/control"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+// command - подкоманда p2pfs.
+type command struct {
+	run      func(cfg *config.Config, args []string) error
+	usage    string // Строка использования для справки
+	summary  string // Краткое описание
+	logLevel string // Уровень журнала по умолчанию
+}
+
+// commands - подкоманды по именам; "" - консольный режим без подкоманды.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"":       {run: runInteractive, usage: "[порт] [узлы...]", summary: "консольный режим", logLevel: "info"},
+		"daemon": {run: runDaemon, usage: "daemon [флаги] [узлы...]", summary: "узел в фоне с API управления", logLevel: "info"},
+		"send":   {run: runSend, usage: "send [флаги] <узел> <файл>", summary: "отправить файл узлу и дождаться завершения", logLevel: "warn"},
+		"share":  {run: runShare, usage: "share [флаги] <файл>", summary: "открыть файл по ссылке для команды get", logLevel: "warn"},
+		"get":    {run: runGet, usage: "get [флаги] <ссылка>", summary: "скачать файл по ссылке", logLevel: "warn"},
+		"peers":  {run: runPeers, usage: "peers [флаги]", summary: "список узлов", logLevel: "warn"},
+		"chat":   {run: runChat, usage: "chat [флаги] [узел]", summary: "чат с узлом или со всеми узлами", logLevel: "warn"},
+		"help":   {run: func(*config.Config, []string) error { usage(); return nil }, usage: "help", summary: "эта справка", logLevel: "warn"},
+	}
+}
+
+// usage выводит справку по подкомандам.
+func usage() {
+	fmt.Fprintln(os.Stderr, "Использование: p2pfs [-config файл] <команда> [флаги] [аргументы]")
+	fmt.Fprintln(os.Stderr, "\nКоманды:")
+	for _, name := range []string{"", "daemon", "send", "share", "get", "peers", "chat", "help"} {
+		cmd := commands[name]
+		fmt.Fprintf(os.Stderr, "  p2pfs %-28s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nКоманды send, share, get, peers и chat работают через запущенный узел (p2pfs daemon),")
+	fmt.Fprintln(os.Stderr, "а если он недоступен или указан -standalone - через временный узел в том же процессе.")
+	fmt.Fprintln(os.Stderr, "Флаги команды: p2pfs <команда> -h")
+	fmt.Fprintln(os.Stderr, "\nНастройки читаются из файла YAML или TOML (-config, переменная "+config.EnvPath+" или")
+	fmt.Fprintln(os.Stderr, "config.yaml в каталоге настроек пользователя), затем из переменных окружения P2PFS_*, затем из флагов.")
+	if dir, err := config.Dir(); err == nil {
+		fmt.Fprintf(os.Stderr, "Каталог настроек: %s\n", dir)
+	}
+}
+
+// clientOptions - параметры подключения команды к узлу.
+type clientOptions struct {
+	control    string        // Адрес API управления
+	standalone bool          // Не искать запущенный узел
+	timeout    time.Duration // Ограничение времени работы команды (0 - без ограничения)
+	node       nodeOptions   // Параметры временного узла
+}
+
+// newFlagSet создаёт набор флагов подкоманды name с общими параметрами подключения.
+// Временный узел получает настройки cfg с любым свободным портом (см. temporaryConfig).
+func newFlagSet(name string, cfg *config.Config, o *clientOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Использование: p2pfs %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&o.control, "control", cfg.ControlAddr(), "адрес API управления запущенного узла")
+	fs.BoolVar(&o.standalone, "standalone", false, "не обращаться к запущенному узлу, работать через временный")
+	fs.DurationVar(&o.timeout, "timeout", 0, "ограничение времени работы (0 - без ограничения)")
+	o.node.cfg = temporaryConfig(cfg)
+	registerNodeFlags(fs, o.node.cfg)
+	return fs
+}
+
+// session - подключение команды к узлу: запущенному или временному.
+type session struct {
+	*control.Client
+	ctx       context.Context
+	cancel    context.CancelFunc
+	temporary bool   // Узел временный и остановится вместе с командой
+	close     func() // Освобождает ресурсы сессии
+}
+
+// openSession подключается к запущенному узлу или запускает временный.
+// ctx сессии отменяется по Ctrl+C и по истечении timeout.
+func openSession(o clientOptions) (*session, error) {
+	if err := o.node.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	cancel := stop
+	if o.timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, o.timeout)
+		cancel = func() { cancelTimeout(); stop() }
+	}
+	s := &session{ctx: ctx, cancel: cancel, close: cancel}
+
+	if !o.standalone {
+		c, err := newControlClient(o.control)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		pingCtx, cancelPing := context.WithTimeout(ctx, time.Second)
+		err = c.Ping(pingCtx)
+		cancelPing()
+		if err == nil {
+			s.Client = c
+			return s, nil
+		}
+		if errors.Is(err, control.ErrForeignSocket) {
+			slog.Warn("API управления не используется", "err", err)
+		}
+	}
+
+	// Временный узел с API на собственном сокете: команды работают одинаково в обоих режимах
+	dir, err := os.MkdirTemp("", "p2pfs-")
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	nodeCtx, stopNode := context.WithCancel(context.Background())
+	p, err := startNode(nodeCtx, o.node)
+	if err != nil {
+		stopNode()
+		os.RemoveAll(dir)
+		cancel()
+		return nil, fmt.Errorf("не удалось запустить узел: %w", err)
+	}
+	addr := "unix://" + filepath.Join(dir, "control.sock")
+	if _, err := startControl(p, addr); err != nil {
+		stopNode()
+		<-p.Done()
+		os.RemoveAll(dir)
+		cancel()
+		return nil, err
+	}
+	c, err := control.NewClient(addr)
+	if err != nil {
+		stopNode()
+		<-p.Done()
+		os.RemoveAll(dir)
+		cancel()
+		return nil, err
+	}
+	s.Client, s.temporary = c, true
+	s.close = func() {
+		stopNode()
+		<-p.Done()
+		os.RemoveAll(dir)
+		cancel()
+	}
+	return s, nil
+}
+
+// ensurePeer подключает узел к peer, если соединения с ним ещё нет.
+// peer - адрес, идентификатор или имя узла.
+func (s *session) ensurePeer(peer string) error {
+	peers, err := s.Peers(s.ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range peers.Connected {
+		if p.Key == peer || p.Addr == peer || p.PeerID == peer || p.Username == peer {
+			return nil
+		}
+	}
+	_, err = s.Connect(s.ctx, peer)
+	return err
+}
+
+// waitTransfer ждёт завершения передачи, выводя ход в stderr.
+func (s *session) waitTransfer(id string) (control.Transfer, error) {
+	r := newProgressRenderer(os.Stderr)
+	return s.WaitTransfer(s.ctx, id, func(t control.Transfer) {
+		r.Update(transferProgress(t))
+	})
+}
+
+// transferProgress переводит состояние передачи из API в transfer.Progress для отрисовки.
+func transferProgress(t control.Transfer) transfer.Progress {
+	p := transfer.Progress{
+		ID:       t.ID,
+		Filename: t.Filename,
+		Peer:     t.Peer,
+		Done:     t.Done,
+		Total:    t.Total,
+		Rate:     t.Rate,
+		ETA:      time.Duration(t.ETA * float64(time.Second)),
+	}
+	if t.Direction == transfer.DirectionDownload.String() {
+		p.Direction = transfer.DirectionDownload
+	}
+	for state := transfer.StateQueued; state <= transfer.StateCanceled; state++ {
+		if state.String() == t.State {
+			p.State = state
+		}
+	}
+	if t.Error != "" {
+		p.Err = errors.New(t.Error)
+	}
+	return p
+}
+
+// runSend отправляет файл узлу и ждёт завершения передачи.
+func runSend(cfg *config.Config, args []string) error {
+	var o clientOptions
+	fs := newFlagSet("send", cfg, &o)
+	priority := fs.Int("priority", transfer.PriorityNormal, "приоритет в очереди передач (-1, 0, 1)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("нужны узел и файл")
+	}
+	peer := fs.Arg(0)
+	path, err := filepath.Abs(fs.Arg(1)) // Путь разбирает узел, у которого может быть другой рабочий каталог
+	if err != nil {
+		return err
+	}
+
+	s, err := openSession(o)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if err := s.ensurePeer(peer); err != nil {
+		return fmt.Errorf("не удалось подключиться к %s: %w", peer, err)
+	}
+	started, sendErr := s.SendFile(s.ctx, control.SendFileRequest{Peer: peer, Path: path, Priority: *priority})
+	if sendErr != nil && len(started) == 0 {
+		return sendErr
+	}
+	for _, t := range started {
+		if _, err := s.waitTransfer(t.ID); err != nil {
+			return err
+		}
+	}
+	return sendErr
+}
+
+// runShare открывает файл по ссылке. Через запущенный узел команда сразу завершается;
+// временный узел раздаёт файл до Ctrl+C или, с флагом -once, до первой успешной отдачи.
+func runShare(cfg *config.Config, args []string) error {
+	var o clientOptions
+	fs := newFlagSet("share", cfg, &o)
+	once := fs.Bool("once", false, "завершиться после первой успешной отдачи файла (для временного узла)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("нужен файл")
+	}
+	path, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	s, err := openSession(o)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	share, err := s.Share(s.ctx, path)
+	if err != nil {
+		return err
+	}
+	fmt.Println(share.Link)
+	if !s.temporary {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "Файл раздаётся, Ctrl+C - завершить")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if !*once {
+			continue
+		}
+		list, err := s.Transfers(s.ctx)
+		if err != nil {
+			return err
+		}
+		for _, t := range list {
+			if t.Direction == transfer.DirectionUpload.String() && t.Filename == filepath.Base(path) && t.State == transfer.StateCompleted.String() {
+				return nil
+			}
+		}
+	}
+}
+
+// runGet скачивает файл по ссылке и ждёт завершения передачи.
+func runGet(cfg *config.Config, args []string) error {
+	var o clientOptions
+	fs := newFlagSet("get", cfg, &o)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("нужна ссылка")
+	}
+
+	s, err := openSession(o)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	d, err := s.Download(s.ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	t, err := s.waitTransfer(d.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Println(t.Path) // Имя могло измениться, если файл с таким именем уже был
+	return nil
+}
+
+// runPeers выводит активные соединения и желаемые узлы.
+func runPeers(cfg *config.Config, args []string) error {
+	var o clientOptions
+	fs := newFlagSet("peers", cfg, &o)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s, err := openSession(o)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	peers, err := s.Peers(s.ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range peers.Connected {
+		line := p.Key
+		if p.Username != "" && p.Username != p.Addr {
+			line += " (" + p.Username + ")"
+		}
+		if p.RTT > 0 {
+			line += fmt.Sprintf(" rtt %.0fms, потери %.0f%%", p.RTT, p.Loss*100)
+		}
+		fmt.Println(line)
+	}
+	for _, p := range peers.Desired {
+		if p.State == "connected" {
+			continue
+		}
+		line := fmt.Sprintf("%s [%s]", p.Address, p.State)
+		if p.LastError != "" {
+			line += ": " + p.LastError
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// runChat показывает входящие сообщения и отправляет строки, введённые в stdin,
+// узлу peer или всем узлам. Завершается по Ctrl+D или Ctrl+C.
+func runChat(cfg *config.Config, args []string) error {
+	var o clientOptions
+	fs := newFlagSet("chat", cfg, &o)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("можно указать только один узел")
+	}
+	peer := fs.Arg(0)
+
+	s, err := openSession(o)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if peer != "" {
+		if err := s.ensurePeer(peer); err != nil {
+			return fmt.Errorf("не удалось подключиться к %s: %w", peer, err)
+		}
+	}
+
+	history, err := s.Messages(s.ctx, peer)
+	if err != nil {
+		return err
+	}
+	for _, msg := range history {
+		fmt.Printf("%s: %s\n", msg.Sender, msg.Content)
+	}
+
+	go func() {
+		err := s.Events(s.ctx, func(e control.Event) {
+			switch e.Type {
+			case "text-received":
+				fmt.Printf("%s: %s\n", e.Sender, e.Content)
+			case "peer-connected":
+				fmt.Printf("* %s подключился\n", e.Username)
+			case "peer-disconnected":
+				fmt.Printf("* %s отключился\n", e.Username)
+			}
+		})
+		if err != nil && s.ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Поток событий прерван: %v\n", err)
+		}
+		s.cancel()
+	}()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if err := s.SendMessage(s.ctx, peer, line); err != nil {
+				fmt.Fprintf(os.Stderr, "Не отправлено: %v\n", err)
+			}
+		}
+	}
+}
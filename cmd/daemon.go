@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/WhiCu/p2pFileShare/config"
+	"github.com/WhiCu/p2pFileShare/control"
+	"github.com/WhiCu/p2pFileShare/peer"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// startControl запускает API управления узлом на addr. Сервер останавливается вместе с узлом;
+// возвращаемый канал закрывается, когда сервер остановлен и ключ доступа удалён.
+// Для TCP-адреса создаётся ключ доступа, который клиенты читают из файла controlTokenPath.
+func startControl(p *peer.Peer, addr string) (<-chan struct{}, error) {
+	tokenPath, err := controlTokenPath(addr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := control.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := control.NewServer(p)
+	if tokenPath != "" {
+		token, err := writeControlToken(tokenPath)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		srv.SetToken(token)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			slog.Error("API управления остановлен", "err", err)
+		}
+	}()
+	go func() {
+		defer close(stopped)
+		<-p.Done()
+		srv.Close()
+		if tokenPath != "" {
+			os.Remove(tokenPath)
+		}
+	}()
+	slog.Info("API управления запущен", "addr", addr)
+	return stopped, nil
+}
+
+// controlTokenPath возвращает путь к файлу ключа доступа к API на TCP-адресе addr
+// в каталоге настроек пользователя. Unix-сокету ключ не нужен: путь пустой.
+func controlTokenPath(addr string) (string, error) {
+	scheme, address := transport.SplitAddr(addr)
+	if scheme != "tcp" {
+		return "", nil
+	}
+	dir, err := config.Dir()
+	if err != nil {
+		return "", fmt.Errorf("не удалось определить каталог ключа API: %w", err)
+	}
+	name := strings.NewReplacer(":", "_", "[", "", "]", "").Replace(address)
+	return filepath.Join(dir, "control-"+name+".token"), nil
+}
+
+// writeControlToken создаёт новый ключ доступа к API и сохраняет его в path,
+// доступный только владельцу.
+func writeControlToken(path string) (string, error) {
+	token, err := control.NewToken()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	os.Remove(path) // Права существующего файла WriteFile не меняет
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("не удалось сохранить ключ API: %w", err)
+	}
+	return token, nil
+}
+
+// newControlClient создаёт клиента API на addr с ключом доступа из controlTokenPath, если он есть.
+func newControlClient(addr string) (*control.Client, error) {
+	c, err := control.NewClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	if path, err := controlTokenPath(addr); err == nil && path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			c.SetToken(strings.TrimSpace(string(data)))
+		}
+	}
+	return c, nil
+}
+
+// runDaemon запускает узел без консоли: узлом управляют через API управления
+// до остановки по Ctrl+C или запросу /shutdown.
+func runDaemon(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Использование: p2pfs %s\n", commands["daemon"].usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.Control, "control", cfg.ControlAddr(), "адрес API управления (unix:///путь или 127.0.0.1:порт)")
+	fs.StringVar(&cfg.Metrics, "metrics", cfg.Metrics, "адрес HTTP-сервера метрик (пустой - не запускать)")
+	registerNodeFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	o := nodeOptions{cfg: cfg, peers: fs.Args()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	p, err := startNode(ctx, o)
+	if err != nil {
+		return fmt.Errorf("не удалось запустить узел: %w", err)
+	}
+	stopped, err := startControl(p, cfg.Control)
+	if err != nil {
+		stop()
+		<-p.Done()
+		return fmt.Errorf("не удалось запустить API управления: %w", err)
+	}
+	<-stopped
+	slog.Info("завершение программы")
+	return nil
+}
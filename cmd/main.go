@@ -2,33 +2,94 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"time"
 
+	"github.com/WhiCu/p2pFileShare/config"
+	"github.com/WhiCu/p2pFileShare/logging"
 	"github.com/WhiCu/p2pFileShare/peer"
 	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/tui"
 )
 
 func main() {
+	global := flag.NewFlagSet("p2pfs", flag.ContinueOnError)
+	global.Usage = usage
+	configPath := global.String("config", "", "файл настроек YAML или TOML")
+	if err := global.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	args := global.Args()
+	cmd, ok := commands[""]
+	if len(args) > 0 {
+		if c, found := commands[args[0]]; found {
+			cmd, ok, args = c, true, args[1:]
+		}
+	}
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
 
-	port := "8080"
-	if len(os.Args) > 1 {
-		port = os.Args[1]
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		os.Exit(1)
+	}
+	level := cfg.Log.Level
+	if level == "" {
+		level = cmd.logLevel
+	}
+	logger, err := logging.New(os.Stderr, level, cfg.Log.Format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Некорректные настройки журнала: %v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+	if cfg.File != "" {
+		slog.Debug("настройки загружены", "file", cfg.File)
 	}
-	p := peer.NewTCPPeer("testPeer", "localhost", port)
 
-	//p.StartBootstrap(config.MustGet("BOOTSTRAP_PORT"))
-	go p.StartTCPListener()
+	if err := cmd.run(cfg, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Ошибка: %v\n", err)
+		os.Exit(1)
+	}
+}
 
-	if len(os.Args) > 2 {
-		p.ConnectToPeers(os.Args[2:]...)
+// runInteractive запускает узел с консольным интерфейсом: p2pfs [порт] [узлы...].
+// В терминале открывается полноэкранный интерфейс, иначе (ввод из файла или канала)
+// строки stdin читаются построчно.
+func runInteractive(cfg *config.Config, args []string) error {
+	o := nodeOptions{cfg: cfg, progress: true}
+	if len(args) > 0 {
+		cfg.Listen.Port, o.peers = args[0], args[1:]
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if tui.IsTerminal(os.Stdin) && tui.IsTerminal(os.Stdout) {
+		return runUI(o)
 	}
 
-	go waitForExit()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	p, err := startNode(ctx, o)
+	if err != nil {
+		return fmt.Errorf("не удалось запустить узел: %w", err)
+	}
+	if cfg.Control != "" {
+		if _, err := startControl(p, cfg.Control); err != nil {
+			slog.Warn("API управления недоступен", "err", err)
+		}
+	}
+
+	go waitForExit(p)
 
 	consoleReader := bufio.NewReader(os.Stdin)
 	for {
@@ -46,18 +107,38 @@ func main() {
 		text += "Текущие подключения:\n"
 
 		p.Connections.Range(func(key, value any) bool {
+			conn := value.(*connection.Connection)
 			text += fmt.Sprintf("%s", key)
-			if value.(*connection.Connection).Username != "" {
-				text += " (" + value.(*connection.Connection).Username + ")\n"
+			if conn.Username() != "" {
+				text += " (" + conn.Username() + ")"
+			}
+			if st := conn.Stats(); st.Pongs > 0 {
+				text += fmt.Sprintf(" rtt %s ±%s, потери %.0f%%", st.SRTT.Round(time.Millisecond), st.Jitter.Round(time.Millisecond), st.Loss()*100)
+			}
+			if q := conn.QueueStats(); q.Depth > 0 || q.Dropped > 0 {
+				text += fmt.Sprintf(" очередь %d/%d, отброшено %d", q.Depth, q.Capacity, q.Dropped)
 			}
 			text += "\n"
 			return true
 		})
 
+		text += "Желаемые узлы:\n"
+
+		for _, st := range p.PeerStates() {
+			text += fmt.Sprintf("%s [%s]", st.Address, st.State)
+			if st.State == peer.PeerBackoff {
+				text += fmt.Sprintf(" попытка %d, повтор через %s", st.Attempts+1, time.Until(st.NextAttempt).Round(time.Second))
+			}
+			if st.LastError != nil {
+				text += fmt.Sprintf(": %v", st.LastError)
+			}
+			text += "\n"
+		}
+
 		text += "Текущая история:\n"
 
 		p.Connections.Range(func(key, value any) bool {
-			for _, msg := range value.(*connection.Connection).Chat {
+			for _, msg := range value.(*connection.Connection).Chat() {
 				text += fmt.Sprintf("%s: %s\n", msg.Sender, msg.Content)
 			}
 			return true
@@ -71,25 +152,20 @@ func main() {
 
 		if message == "exit" {
 			fmt.Println("Выход...")
-			break
+			stop() // Отмена контекста останавливает узел
+			<-p.Done()
+			return nil
 		}
 
-		p.SendMessageToPeers(message)
-
-		// if strings.HasPrefix(text, "file ") {
-		// 	filePath := strings.TrimPrefix(text, "file ")
-		// 	p.sendFileToPeers(filePath)
-		// } else {
-		// 	p.sendMessageToPeers(text)
-		// }
+		if !handleTransferCommand(os.Stdout, p, message) {
+			p.SendMessageToPeers(message)
+		}
 	}
 }
 
-func waitForExit() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
-	<-sigChan
-	log.Println("Завершение программы...")
-	time.Sleep(2 * time.Second) // Задержка для корректного завершения всех горутин
+// waitForExit завершает программу после остановки узла (по Ctrl+C или команде exit).
+func waitForExit(p *peer.Peer) {
+	<-p.Done()
+	slog.Info("завершение программы")
 	os.Exit(0)
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/WhiCu/p2pFileShare/config"
+	"github.com/WhiCu/p2pFileShare/peer"
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// nodeOptions - параметры запуска локального узла.
+type nodeOptions struct {
+	cfg      *config.Config // Настройки узла
+	peers    []string       // Узлы, к которым подключиться после запуска, помимо bootstrap.peers
+	progress bool           // Выводить ход передач в stderr
+}
+
+// registerNodeFlags добавляет в набор флагов параметры узла. Флаги привязаны к полям cfg,
+// поэтому переопределяют значения из файла настроек и окружения.
+func registerNodeFlags(fs *flag.FlagSet, cfg *config.Config) {
+	fs.StringVar(&cfg.Identity.Name, "name", cfg.Identity.Name, "имя пользователя узла")
+	fs.StringVar(&cfg.Identity.Host, "host", cfg.Identity.Host, "хост узла")
+	fs.StringVar(&cfg.Identity.Advertise, "advertise", cfg.Identity.Advertise, "внешний адрес узла host[:port] для ссылок на файлы")
+	fs.StringVar(&cfg.Listen.Port, "port", cfg.Listen.Port, "порт узла (0 - любой свободный)")
+	fs.StringVar(&cfg.DownloadDir, "download-dir", cfg.DownloadDir, "каталог загрузок (пустой - файлы не принимаются)")
+	fs.TextVar(&cfg.Limits.Upload, "limit-up", cfg.Limits.Upload, "ограничение скорости отправки (512K, 2M, 0 - без ограничения)")
+	fs.TextVar(&cfg.Limits.Download, "limit-down", cfg.Limits.Download, "ограничение скорости приёма")
+}
+
+// temporaryConfig возвращает настройки временного узла команды: любой свободный порт
+// и без слушателей, которые заняты запущенным узлом с теми же настройками.
+func temporaryConfig(cfg *config.Config) *config.Config {
+	tmp := *cfg
+	tmp.Listen.Port = "0"
+	if host, _, err := net.SplitHostPort(tmp.Identity.Advertise); err == nil {
+		tmp.Identity.Advertise = host // Порт занят запущенным узлом: в ссылках порт временного
+	}
+	tmp.Listen.WS, tmp.Listen.WSS = "", ""
+	tmp.Bootstrap.Port = ""
+	tmp.Metrics = ""
+	return &tmp
+}
+
+// startNode создаёт и запускает узел с дополнительными транспортами, метриками
+// и подключениями по настройкам o. Отмена ctx останавливает узел.
+func startNode(ctx context.Context, o nodeOptions) (*peer.Peer, error) {
+	cfg := o.cfg
+	tlsConf, err := cfg.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	policy, err := connection.ParseQueuePolicy(cfg.Limits.QueuePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	p := peer.NewTCPPeer(cfg.Identity.Name, cfg.Identity.Host, cfg.Listen.Port)
+	p.SetLogger(slog.Default())
+	p.SetAdvertiseAddr(cfg.Identity.Advertise)
+	if cfg.DownloadDir != "" {
+		if err := p.SetDownloadDir(cfg.DownloadDir); err != nil {
+			slog.Warn("приём файлов отключён", "err", err)
+		}
+	}
+	p.Throttle.SetGlobal(int64(cfg.Limits.Upload), int64(cfg.Limits.Download))
+	p.Throttle.SetPeerDefault(int64(cfg.Limits.PeerUpload), int64(cfg.Limits.PeerDownload))
+	for name, l := range cfg.Limits.Peers {
+		p.SetPeerLimit(name, int64(l.Upload), int64(l.Download))
+	}
+	p.Transfers.SetLimits(cfg.Limits.MaxTransfers, cfg.Limits.MaxPerPeer)
+	p.SetQueuePolicy(policy)
+	if o.progress {
+		p.OnProgress(newProgressRenderer(os.Stderr).Update)
+	}
+
+	p.AddTransport(transport.WebSocket(cfg.Listen.WSOrigins...))
+	p.AddTransport(transport.SecureWebSocket(tlsConf, cfg.Listen.WSOrigins...))
+	quic, err := transport.QUIC(tlsConf)
+	if err != nil {
+		slog.Warn("QUIC недоступен", "err", err)
+	} else {
+		p.AddTransport(quic)
+	}
+
+	if err := p.Start(ctx); err != nil {
+		return nil, err
+	}
+	if cfg.Listen.WS != "" {
+		if err := p.Listen("ws", cfg.Listen.WS); err != nil {
+			slog.Warn("WebSocket недоступен", "err", err)
+		}
+	}
+	if cfg.Listen.WSS != "" {
+		if err := p.Listen("wss", cfg.Listen.WSS); err != nil {
+			slog.Warn("WebSocket поверх TLS недоступен", "err", err)
+		}
+	}
+	if quic != nil && cfg.Listen.QUIC {
+		if err := p.StartListener(quic.Name()); err != nil {
+			slog.Warn("QUIC недоступен", "err", err)
+		}
+	}
+	if cfg.Bootstrap.Port != "" {
+		if err := p.StartBootstrap(cfg.Bootstrap.Port); err != nil {
+			slog.Warn("Bootstrap-сервер недоступен", "err", err)
+		}
+	}
+	if cfg.Metrics != "" {
+		if err := p.ServeMetrics(cfg.Metrics); err != nil {
+			slog.Warn("метрики недоступны", "err", err)
+		}
+	}
+
+	if peers := append(slices.Clone(cfg.Bootstrap.Peers), o.peers...); len(peers) > 0 {
+		p.ConnectToPeers(peers...)
+	}
+	return p, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+// progressBarWidth - ширина полосы прогресса в символах
+const progressBarWidth = 30
+
+// progressRenderer выводит ход передач файлов в виде строки прогресса,
+// перерисовываемой на месте.
+type progressRenderer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// newProgressRenderer создаёт отрисовщик прогресса, пишущий в out.
+func newProgressRenderer(out io.Writer) *progressRenderer {
+	return &progressRenderer{out: out}
+}
+
+// Update перерисовывает строку прогресса для события p.
+// Завершённая передача оставляет итоговую строку и переводит курсор на новую строку.
+func (r *progressRenderer) Update(p transfer.Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	line := formatProgress(p)
+	if p.Finished() {
+		fmt.Fprintf(r.out, "\r\033[2K%s\n", line)
+		return
+	}
+	fmt.Fprintf(r.out, "\r\033[2K%s", line)
+}
+
+// formatProgress формирует строку вида "↑ file.bin [=====>    ] 52% 1.2 MiB/s ETA 3s".
+func formatProgress(p transfer.Progress) string {
+	arrow := "↑"
+	if p.Direction == transfer.DirectionDownload {
+		arrow = "↓"
+	}
+
+	filled := int(p.Percent() / 100 * progressBarWidth)
+	filled = min(max(filled, 0), progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+
+	line := fmt.Sprintf("%s %s [%s] %3.0f%% %s/%s",
+		arrow, p.Filename, bar, p.Percent(), formatBytes(float64(p.Done)), formatBytes(float64(p.Total)))
+
+	switch p.State {
+	case transfer.StateRunning:
+		line += fmt.Sprintf(" %s/s", formatBytes(p.Rate))
+		if p.ETA > 0 {
+			line += " ETA " + p.ETA.Round(time.Second).String()
+		}
+	case transfer.StateFailed:
+		line += fmt.Sprintf(" ошибка: %v", p.Err)
+	default:
+		line += " " + p.State.String()
+	}
+	return line
+}
+
+// formatBytes переводит количество байт в человекочитаемый вид.
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/WhiCu/p2pFileShare/peer"
+	"github.com/WhiCu/p2pFileShare/peer/ratelimit"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+// handleTransferCommand выполняет команды управления передачами файлов:
+//
+//	file [-high|-low] <путь>  - отправить файл всем узлам
+//	transfers                 - список передач
+//	pause|resume|cancel <id>  - управление передачей
+//	limit <up> <down> [узел]  - ограничить скорость всего или для узла по адресу, ID или имени
+//	                            (например, 512K, 2M, 0 - без ограничения)
+//
+// Результат команды выводится в w. Возвращает false, если строка не является такой командой.
+func handleTransferCommand(w io.Writer, p *peer.Peer, line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch fields[0] {
+	case "file":
+		args := fields[1:]
+		priority := transfer.PriorityNormal
+		if len(args) > 0 {
+			switch args[0] {
+			case "-high":
+				priority, args = transfer.PriorityHigh, args[1:]
+			case "-low":
+				priority, args = transfer.PriorityLow, args[1:]
+			}
+		}
+		if len(args) == 0 {
+			fmt.Fprintln(w, "Использование: file [-high|-low] <путь>")
+			return true
+		}
+		p.SendFileToPeers(strings.Join(args, " "), priority)
+
+	case "transfers":
+		list := p.Transfers.List()
+		if len(list) == 0 {
+			fmt.Fprintln(w, "Нет активных передач")
+		}
+		for _, pr := range list {
+			fmt.Fprintf(w, "%s %s\n", pr.ID, formatProgress(pr))
+		}
+
+	case "pause", "resume", "cancel":
+		if len(fields) != 2 {
+			fmt.Fprintf(w, "Использование: %s <id>\n", fields[0])
+			return true
+		}
+		var err error
+		switch fields[0] {
+		case "pause":
+			err = p.Transfers.Pause(fields[1])
+		case "resume":
+			err = p.Transfers.Resume(fields[1])
+		case "cancel":
+			err = p.Transfers.Cancel(fields[1])
+		}
+		if err != nil {
+			fmt.Fprintf(w, "%s %s: %v\n", fields[0], fields[1], err)
+		}
+
+	case "limit":
+		if len(fields) != 3 && len(fields) != 4 {
+			fmt.Fprintln(w, "Использование: limit <up> <down> [узел]")
+			return true
+		}
+		up, err := ratelimit.ParseRate(fields[1])
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return true
+		}
+		down, err := ratelimit.ParseRate(fields[2])
+		if err != nil {
+			fmt.Fprintln(w, err)
+			return true
+		}
+		if len(fields) == 4 {
+			if !p.SetPeerLimit(fields[3], up, down) {
+				fmt.Fprintf(w, "Узел %s не подключён: ограничение применится при подключении\n", fields[3])
+			}
+		} else {
+			p.Throttle.SetGlobal(up, down)
+		}
+
+	default:
+		return false
+	}
+	return true
+}
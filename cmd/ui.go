@@ -0,0 +1,573 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/logging"
+	"github.com/WhiCu/p2pFileShare/peer"
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/transfer"
+	"github.com/WhiCu/p2pFileShare/tui"
+)
+
+const (
+	// uiRefresh - период обновления экрана: RTT, скорость передач, таймеры переподключения
+	uiRefresh = 500 * time.Millisecond
+	// chatHistory - сколько строк хранит панель чата
+	chatHistory = 1000
+	// finishedShown - сколько завершённых передач показывает панель передач
+	finishedShown = 5
+	// shortID - сколько символов идентификатора передачи показывается на панели
+	shortID = 8
+	// requestTimeout - время ожидания ответа на запрос файла по ссылке
+	requestTimeout = 30 * time.Second
+)
+
+// uiCommands - команды строки ввода с описаниями для /help.
+var uiCommands = []struct{ name, usage string }{
+	{"/msg", "/msg <узел> <текст>          - личное сообщение"},
+	{"/send", "/send <узел> <путь>          - отправить файл узлу"},
+	{"/file", "/file [-high|-low] <путь>    - отправить файл всем узлам"},
+	{"/share", "/share <путь>                - открыть файл по ссылке"},
+	{"/get", "/get <ссылка>                - скачать файл по ссылке"},
+	{"/connect", "/connect <адрес>             - подключиться к узлу"},
+	{"/disconnect", "/disconnect <узел>           - отключиться от узла"},
+	{"/pause", "/pause <id>                  - приостановить передачу"},
+	{"/resume", "/resume <id>                 - возобновить передачу"},
+	{"/cancel", "/cancel <id>                 - отменить передачу"},
+	{"/limit", "/limit <up> <down> [узел]    - ограничить скорость (512K, 2M, 0)"},
+	{"/transfers", "/transfers                   - список передач"},
+	{"/help", "/help                        - эта справка"},
+	{"/quit", "/quit                        - выход (также Ctrl+C)"},
+}
+
+// ui - полноэкранный интерфейс узла: панели узлов, чата и передач и строка ввода.
+// Строка без «/» отправляется в общий чат, Tab дополняет команды, имена узлов и
+// идентификаторы передач, стрелки листают историю ввода, PgUp/PgDn - чат.
+type ui struct {
+	p      *peer.Peer
+	term   *tui.Terminal
+	editor tui.Editor
+	ctx    context.Context // Контекст узла: подключения по командам поддерживаются до его отмены
+	quit   func()          // Останавливает узел
+
+	mu       sync.Mutex
+	chat     []tui.Line // Сообщения чата и уведомления
+	scroll   int        // Прокрутка чата назад от последней строки
+	stopping bool       // Узел останавливается
+
+	redraw chan struct{} // Запрос внеочередной перерисовки
+}
+
+// runUI запускает узел с полноэкранным интерфейсом.
+func runUI(o nodeOptions) error {
+	term, err := tui.Open(os.Stdin, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer term.Close()
+
+	u := &ui{term: term, redraw: make(chan struct{}, 1)}
+	u.editor.Complete = u.complete
+
+	// Журнал выводится в панель чата без времени записи: запись в stderr испортила бы экран
+	level := slog.LevelWarn
+	if o.cfg.Log.Level != "" {
+		if level, err = logging.ParseLevel(o.cfg.Log.Level); err != nil {
+			return err
+		}
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(u.writer(tui.StyleDim), &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	o.progress = false // Ход передач показывает панель передач
+	p, err := startNode(ctx, o)
+	if err != nil {
+		return fmt.Errorf("не удалось запустить узел: %w", err)
+	}
+	if o.cfg.Control != "" {
+		if _, err := startControl(p, o.cfg.Control); err != nil {
+			slog.Warn("API управления недоступен", "err", err)
+		}
+	}
+	u.p, u.ctx, u.quit = p, ctx, stop
+
+	p.Subscribe(u.event)
+	p.OnProgress(u.progress)
+	u.notice(tui.StyleDim, "Узел %s запущен на %s. /help - список команд", p.Username, p.Addr())
+	return u.run()
+}
+
+// run обрабатывает ввод и перерисовывает экран до остановки узла.
+func (u *ui) run() error {
+	keys := make(chan tui.Key)
+	go u.term.ReadKeys(keys)
+	resized := u.term.Resized()
+	ticker := time.NewTicker(uiRefresh)
+	defer ticker.Stop()
+
+	for {
+		u.term.Draw(u.frame())
+		select {
+		case <-u.p.Done():
+			return nil
+		case k, ok := <-keys:
+			if !ok {
+				u.stop()
+				continue
+			}
+			u.handleKey(k)
+		case <-u.redraw:
+		case <-resized:
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop останавливает узел; run завершится после остановки.
+func (u *ui) stop() {
+	u.mu.Lock()
+	u.stopping = true
+	u.mu.Unlock()
+	u.quit()
+}
+
+// changed запрашивает перерисовку экрана, не блокируясь.
+func (u *ui) changed() {
+	select {
+	case u.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// handleKey обрабатывает нажатие клавиши.
+func (u *ui) handleKey(k tui.Key) {
+	switch k.Code {
+	case tui.KeyCtrlC:
+		u.stop()
+		return
+	case tui.KeyCtrlD:
+		if line, _ := u.editor.Line(); line == "" {
+			u.stop()
+		}
+		return
+	case tui.KeyPageUp, tui.KeyPageDown:
+		_, height := u.term.Size()
+		page := tui.MainHeight(height, len(u.transferLines()))
+		u.mu.Lock()
+		if k.Code == tui.KeyPageUp {
+			u.scroll = min(u.scroll+page, max(len(u.chat)-1, 0))
+		} else {
+			u.scroll = max(u.scroll-page, 0)
+		}
+		u.mu.Unlock()
+		return
+	case tui.KeyEscape:
+		u.mu.Lock()
+		u.scroll = 0
+		u.mu.Unlock()
+		return
+	}
+	if line, ok := u.editor.HandleKey(k); ok {
+		u.mu.Lock()
+		u.scroll = 0
+		u.mu.Unlock()
+		go u.execute(line) // Команды могут ждать сеть, экран при этом обновляется
+	}
+}
+
+// execute выполняет введённую строку: команду или сообщение в общий чат.
+func (u *ui) execute(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	if !strings.HasPrefix(line, "/") {
+		u.say(line)
+		return
+	}
+	fields := strings.Fields(line)
+	args := fields[1:]
+	rest := func(n int) string { // Текст после n-го аргумента с сохранением пробелов
+		s := strings.TrimSpace(line[len(fields[0]):])
+		for i := 0; i < n; i++ {
+			s = strings.TrimSpace(strings.TrimPrefix(s, args[i]))
+		}
+		return s
+	}
+
+	switch fields[0] {
+	case "/help":
+		for _, c := range uiCommands {
+			u.notice(tui.StyleNormal, "%s", c.usage)
+		}
+		u.notice(tui.StyleDim, "Tab - дополнение, ↑/↓ - история, PgUp/PgDn - прокрутка чата, Esc - к последним сообщениям")
+	case "/quit", "/exit":
+		u.stop()
+	case "/msg":
+		if len(args) < 2 {
+			u.notice(tui.StyleError, "Использование: /msg <узел> <текст>")
+			return
+		}
+		conn, ok := u.p.Connection(args[0])
+		if !ok {
+			u.notice(tui.StyleError, "%s: %v", args[0], peer.ErrUnknownPeer)
+			return
+		}
+		text := rest(1)
+		u.p.SendMessageToPeer(conn, text, nil)
+		u.add(tui.Line{Text: fmt.Sprintf("%s вы → %s: %s", time.Now().Format("15:04"), peerName(conn), text), Style: tui.StyleBold})
+	case "/send":
+		if len(args) < 2 {
+			u.notice(tui.StyleError, "Использование: /send <узел> <путь>")
+			return
+		}
+		conn, ok := u.p.Connection(args[0])
+		if !ok {
+			u.notice(tui.StyleError, "%s: %v", args[0], peer.ErrUnknownPeer)
+			return
+		}
+		if _, err := u.p.SendFile(conn, rest(1), transfer.PriorityNormal); err != nil {
+			u.notice(tui.StyleError, "Не удалось отправить файл: %v", err)
+		}
+	case "/share":
+		if len(args) == 0 {
+			u.notice(tui.StyleError, "Использование: /share <путь>")
+			return
+		}
+		link, err := u.p.Share(rest(0))
+		if err != nil {
+			u.notice(tui.StyleError, "Не удалось открыть файл: %v", err)
+			return
+		}
+		u.notice(tui.StyleAccent, "Ссылка: %s", link)
+	case "/get":
+		if len(args) != 1 {
+			u.notice(tui.StyleError, "Использование: /get <ссылка>")
+			return
+		}
+		u.download(args[0])
+	case "/connect":
+		if len(args) != 1 {
+			u.notice(tui.StyleError, "Использование: /connect <адрес>")
+			return
+		}
+		if err := u.p.ConnectToPeer(u.ctx, args[0]); err != nil {
+			u.notice(tui.StyleError, "Не удалось подключиться к %s: %v", args[0], err)
+		}
+	case "/disconnect":
+		if len(args) != 1 {
+			u.notice(tui.StyleError, "Использование: /disconnect <узел>")
+			return
+		}
+		if err := u.p.DisconnectPeer(context.Background(), args[0]); err != nil {
+			u.notice(tui.StyleError, "%s: %v", args[0], err)
+		}
+	case "/pause", "/resume", "/cancel":
+		if len(args) == 1 {
+			args[0] = u.transferID(args[0])
+		}
+		handleTransferCommand(u.writer(tui.StyleError), u.p, strings.TrimPrefix(strings.Join(fields, " "), "/"))
+	case "/file", "/limit", "/transfers":
+		handleTransferCommand(u.writer(tui.StyleNormal), u.p, strings.TrimPrefix(line, "/"))
+	default:
+		u.notice(tui.StyleError, "Неизвестная команда %s, /help - список команд", fields[0])
+	}
+}
+
+// say отправляет сообщение в общий чат.
+func (u *ui) say(text string) {
+	n := 0
+	u.p.Connections.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	if n == 0 {
+		u.notice(tui.StyleError, "Нет подключённых узлов: /connect <адрес>")
+		return
+	}
+	u.p.SendMessageToPeers(text)
+	u.add(tui.Line{Text: fmt.Sprintf("%s вы: %s", time.Now().Format("15:04"), text), Style: tui.StyleBold})
+}
+
+// download запрашивает файл по ссылке, при необходимости подключаясь к узлу-источнику.
+func (u *ui) download(s string) {
+	link, err := peer.ParseLink(s)
+	if err != nil {
+		u.notice(tui.StyleError, "%v", err)
+		return
+	}
+	conn, ok := u.p.Connection(link.Address)
+	if !ok {
+		if err := u.p.ConnectToPeer(u.ctx, link.Address); err != nil {
+			u.notice(tui.StyleError, "Не удалось подключиться к %s: %v", link.Address, err)
+			return
+		}
+		if conn, ok = u.p.Connection(link.Address); !ok {
+			u.notice(tui.StyleError, "Соединение с %s закрыто сразу после установки", link.Address)
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(u.ctx, requestTimeout)
+	defer cancel()
+	if _, err := u.p.RequestFile(ctx, conn, link.ID); err != nil {
+		u.notice(tui.StyleError, "Не удалось запросить файл: %v", err)
+		return
+	}
+	u.notice(tui.StyleDim, "Файл запрошен у %s, будет сохранён в %s", peerName(conn), u.p.DownloadDir())
+}
+
+// event показывает событие узла в панели чата.
+func (u *ui) event(e peer.Event) {
+	switch e.Type {
+	case peer.EventTextReceived:
+		u.add(tui.Line{Text: fmt.Sprintf("%s %s: %s", e.Time.Format("15:04"), peerName(e.Conn), e.Message.Content)})
+	case peer.EventPeerConnected:
+		u.notice(tui.StyleDim, "* %s подключился (%s)", peerName(e.Conn), e.Conn.Addr())
+	case peer.EventPeerDisconnected:
+		u.notice(tui.StyleDim, "* %s отключился", peerName(e.Conn))
+	case peer.EventFileOffered:
+		u.notice(tui.StyleAccent, "* %s отправляет файл %s", peerName(e.Conn), e.Message.Filename)
+	}
+}
+
+// progress обновляет панель передач и сообщает в чате о завершении передачи.
+func (u *ui) progress(pr transfer.Progress) {
+	if pr.Finished() {
+		style := tui.StyleDim
+		if pr.State == transfer.StateFailed {
+			style = tui.StyleError
+		}
+		u.notice(style, "%s", formatProgress(pr))
+		return
+	}
+	u.changed()
+}
+
+// add добавляет строку в панель чата.
+func (u *ui) add(l tui.Line) {
+	u.mu.Lock()
+	u.chat = append(u.chat, l)
+	if len(u.chat) > chatHistory {
+		u.chat = slices.Delete(u.chat, 0, len(u.chat)-chatHistory)
+	}
+	if u.scroll > 0 {
+		u.scroll++ // Прокрученный назад чат остаётся на месте
+	}
+	u.mu.Unlock()
+	u.changed()
+}
+
+// notice добавляет в панель чата уведомление.
+func (u *ui) notice(style tui.Style, format string, args ...any) {
+	u.add(tui.Line{Text: fmt.Sprintf(format, args...), Style: style})
+}
+
+// lineWriter выводит записанный текст в панель чата построчно.
+type lineWriter struct {
+	u     *ui
+	style tui.Style
+}
+
+// writer возвращает io.Writer, выводящий текст в панель чата стилем style.
+func (u *ui) writer(style tui.Style) lineWriter {
+	return lineWriter{u: u, style: style}
+}
+
+// Write добавляет непустые строки data в панель чата.
+func (w lineWriter) Write(data []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line != "" {
+			w.u.add(tui.Line{Text: line, Style: w.style})
+		}
+	}
+	return len(data), nil
+}
+
+// frame формирует кадр экрана.
+func (u *ui) frame() *tui.Frame {
+	line, cursor := u.editor.Line()
+	f := &tui.Frame{
+		Side:   u.peersPane(),
+		Bottom: tui.Pane{Title: "Передачи", Lines: u.transferLines()},
+		Status: u.status(),
+		Prompt: "> ",
+		Input:  line,
+		Cursor: cursor,
+	}
+	u.mu.Lock()
+	f.Main = tui.Pane{Title: "Чат", Lines: slices.Clone(u.chat)}
+	f.Scroll = u.scroll
+	if u.scroll > 0 {
+		f.Main.Title = fmt.Sprintf("Чат (прокрутка: %d, Esc - к концу)", u.scroll)
+	}
+	u.mu.Unlock()
+	return f
+}
+
+// status формирует строку состояния или подсказку с вариантами дополнения.
+func (u *ui) status() tui.Line {
+	if hint := u.editor.Hint(); len(hint) > 0 {
+		return tui.Line{Text: "Варианты: " + strings.Join(hint, "  "), Style: tui.StyleAccent}
+	}
+	u.mu.Lock()
+	stopping := u.stopping
+	u.mu.Unlock()
+	if stopping {
+		return tui.Line{Text: "Остановка узла…", Style: tui.StyleAccent}
+	}
+	text := fmt.Sprintf(" %s · %s", u.p.Username, u.p.Addr())
+	if dir := u.p.DownloadDir(); dir != "" {
+		text += " · загрузки: " + dir
+	}
+	return tui.Line{Text: text + " · /help - команды", Style: tui.StyleDim}
+}
+
+// connections возвращает активные соединения, упорядоченные по ключу.
+func (u *ui) connections() (keys []string, conns []*connection.Connection) {
+	byKey := make(map[string]*connection.Connection)
+	u.p.Connections.Range(func(key, value any) bool {
+		keys = append(keys, key.(string))
+		byKey[key.(string)] = value.(*connection.Connection)
+		return true
+	})
+	slices.Sort(keys)
+	for _, key := range keys {
+		conns = append(conns, byKey[key])
+	}
+	return keys, conns
+}
+
+// peersPane формирует панель узлов: активные соединения и ожидающие переподключения.
+func (u *ui) peersPane() tui.Pane {
+	var lines []tui.Line
+	_, conns := u.connections()
+	for _, conn := range conns {
+		lines = append(lines, tui.Line{Text: "● " + peerName(conn), Style: tui.StyleAccent})
+		detail := "  " + conn.Addr()
+		if st := conn.Stats(); st.Pongs > 0 {
+			detail += fmt.Sprintf(" %s", st.SRTT.Round(100*time.Microsecond))
+			if loss := st.Loss(); loss > 0 {
+				detail += fmt.Sprintf(" потери %.0f%%", loss*100)
+			}
+		}
+		if q := conn.QueueStats(); q.Depth > 0 {
+			detail += fmt.Sprintf(" очередь %d", q.Depth)
+		}
+		lines = append(lines, tui.Line{Text: detail, Style: tui.StyleDim})
+	}
+	for _, st := range u.p.PeerStates() {
+		if st.State == peer.PeerConnected {
+			continue
+		}
+		text := fmt.Sprintf("○ %s [%s]", st.Address, st.State)
+		if st.State == peer.PeerBackoff {
+			text += fmt.Sprintf(" %s", time.Until(st.NextAttempt).Round(time.Second))
+		}
+		lines = append(lines, tui.Line{Text: text, Style: tui.StyleDim})
+	}
+	return tui.Pane{Title: fmt.Sprintf("Узлы (%d)", len(conns)), Lines: lines}
+}
+
+// transferLines формирует строки панели передач: идущие и последние завершённые.
+func (u *ui) transferLines() []tui.Line {
+	list := u.p.Transfers.List()
+	slices.SortFunc(list, func(a, b transfer.Progress) int { return strings.Compare(a.ID, b.ID) })
+	history := u.p.Transfers.History()
+	history = history[max(len(history)-finishedShown, 0):]
+
+	var lines []tui.Line
+	for _, pr := range list {
+		lines = append(lines, tui.Line{Text: pr.ID[:min(shortID, len(pr.ID))] + " " + formatProgress(pr)})
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		pr := history[i]
+		style := tui.StyleDim
+		if pr.State == transfer.StateFailed {
+			style = tui.StyleError
+		}
+		lines = append(lines, tui.Line{Text: pr.ID[:min(shortID, len(pr.ID))] + " " + formatProgress(pr), Style: style})
+	}
+	return lines
+}
+
+// transferID дополняет префикс до идентификатора идущей передачи, если он однозначен.
+func (u *ui) transferID(prefix string) string {
+	var found []string
+	for _, pr := range u.p.Transfers.List() {
+		if strings.HasPrefix(pr.ID, prefix) {
+			found = append(found, pr.ID)
+		}
+	}
+	if len(found) == 1 {
+		return found[0]
+	}
+	return prefix
+}
+
+// complete дополняет команду, имя узла или идентификатор передачи.
+func (u *ui) complete(args []string, word string) []string {
+	var options []string
+	switch {
+	case len(args) == 0 && strings.HasPrefix(word, "/"):
+		for _, c := range uiCommands {
+			options = append(options, c.name)
+		}
+	case len(args) == 0, !strings.HasPrefix(args[0], "/"):
+		options = u.peerNames() // Обращение к узлу в тексте сообщения
+	case len(args) == 1 && slices.Contains([]string{"/msg", "/send", "/disconnect"}, args[0]),
+		len(args) == 3 && args[0] == "/limit":
+		options = u.peerNames()
+	case len(args) == 1 && slices.Contains([]string{"/pause", "/resume", "/cancel"}, args[0]):
+		for _, pr := range u.p.Transfers.List() {
+			options = append(options, pr.ID)
+		}
+	}
+
+	var matches []string
+	for _, o := range options {
+		if strings.HasPrefix(o, word) && !slices.Contains(matches, o) {
+			matches = append(matches, o)
+		}
+	}
+	slices.Sort(matches)
+	return matches
+}
+
+// peerNames возвращает имена и адреса подключённых узлов.
+func (u *ui) peerNames() []string {
+	var names []string
+	keys, conns := u.connections()
+	for i, conn := range conns {
+		if name := conn.Username(); name != "" {
+			names = append(names, name)
+		}
+		names = append(names, keys[i])
+	}
+	return names
+}
+
+// peerName возвращает имя пользователя узла или, до рукопожатия, его адрес.
+func peerName(conn *connection.Connection) string {
+	if name := conn.Username(); name != "" {
+		return name
+	}
+	return conn.Addr()
+}
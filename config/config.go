@@ -1,28 +1,339 @@
+// Package config загружает настройки узла по слоям: значения по умолчанию,
+// файл YAML или TOML, переменные окружения. Флаги командной строки
+// привязываются к полям Config после загрузки, поэтому имеют наивысший приоритет;
+// после разбора флагов настройки проверяются через Validate.
 package config
 
 import (
-	"log"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/WhiCu/p2pFileShare/peer/ratelimit"
+	"github.com/WhiCu/p2pFileShare/transfer"
 	"github.com/joho/godotenv"
 )
 
-// init loads the .env file in the config directory into the environment, if one exists.
-// This should be called automatically when the package is initialized.
-func init() {
-	if err := godotenv.Load("./config/.env"); err != nil {
-		log.Print("No .env file found ")
+const (
+	// EnvPath - переменная окружения с путём к файлу настроек
+	EnvPath = "P2PFS_CONFIG"
+	// dirName - каталог настроек приложения в каталоге настроек пользователя
+	dirName = "p2pfs"
+)
+
+// defaultFiles - имена файла настроек, которые ищутся в Dir по порядку
+var defaultFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// Config - настройки узла.
+type Config struct {
+	Identity    Identity  `yaml:"identity" toml:"identity"`
+	Listen      Listen    `yaml:"listen" toml:"listen"`
+	Bootstrap   Bootstrap `yaml:"bootstrap" toml:"bootstrap"`
+	DownloadDir string    `yaml:"download_dir" toml:"download_dir" env:"P2PFS_DOWNLOAD_DIR" path:"true"` // Каталог загрузок ("" - файлы не принимаются)
+	Limits      Limits    `yaml:"limits" toml:"limits"`
+	TLS         TLS       `yaml:"tls" toml:"tls"`
+	Log         Log       `yaml:"log" toml:"log"`
+	Control     string    `yaml:"control" toml:"control" env:"P2PFS_CONTROL_ADDR"` // Адрес API управления: "unix:///путь" или "127.0.0.1:порт" (см. ControlAddr)
+	Metrics     string    `yaml:"metrics" toml:"metrics" env:"P2PFS_METRICS_ADDR"` // Адрес HTTP-сервера метрик ("" - не запускать)
+
+	File string `yaml:"-" toml:"-"` // Загруженный файл настроек ("" - файл не найден)
+}
+
+// Identity - как узел представляется другим узлам.
+type Identity struct {
+	Name      string `yaml:"name" toml:"name" env:"P2PFS_USERNAME_PEER"`            // Имя пользователя
+	Host      string `yaml:"host" toml:"host" env:"P2PFS_HOST_PEER"`                // Хост, на котором узел принимает соединения
+	Advertise string `yaml:"advertise" toml:"advertise" env:"P2PFS_ADVERTISE_ADDR"` // Внешний адрес "host[:port]" для ссылок на файлы ("" - адрес слушателя)
+}
+
+// Listen - адреса, на которых узел принимает соединения.
+type Listen struct {
+	Port string `yaml:"port" toml:"port" env:"P2PFS_PORT_PEER"` // Порт TCP ("0" - любой свободный)
+	QUIC bool   `yaml:"quic" toml:"quic" env:"P2PFS_QUIC"`      // Принимать соединения QUIC на том же порту (UDP)
+	WS   string `yaml:"ws" toml:"ws" env:"P2PFS_WS_ADDR"`       // Адрес WebSocket "host:port[/path]" ("" - не принимать)
+	WSS  string `yaml:"wss" toml:"wss" env:"P2PFS_WSS_ADDR"`    // Адрес WebSocket поверх TLS; нужны tls.cert и tls.key
+
+	WSOrigins []string `yaml:"ws_origins" toml:"ws_origins" env:"P2PFS_WS_ORIGINS"` // Источники страниц, которым разрешено подключаться из браузера, кроме страниц того же адреса
+}
+
+// Bootstrap - начальные узлы и Bootstrap-сервер.
+type Bootstrap struct {
+	Peers []string `yaml:"peers" toml:"peers" env:"P2PFS_BOOTSTRAP_PEERS"` // Узлы, к которым подключиться при запуске (в окружении - через запятую)
+	Port  string   `yaml:"port" toml:"port" env:"P2PFS_BOOTSTRAP_PORT"`    // Порт Bootstrap-сервера ("" - не запускать)
+}
+
+// Limits - ограничения скорости и числа передач.
+type Limits struct {
+	Upload       Rate   `yaml:"upload" toml:"upload" env:"P2PFS_LIMIT_UP"`                                               // Общая скорость отправки
+	Download     Rate   `yaml:"download" toml:"download" env:"P2PFS_LIMIT_DOWN"`                                         // Общая скорость приёма
+	MaxTransfers int    `yaml:"max_transfers" toml:"max_transfers" env:"P2PFS_MAX_TRANSFERS"`                            // Одновременных передач (0 - без ограничения)
+	MaxPerPeer   int    `yaml:"max_transfers_per_peer" toml:"max_transfers_per_peer" env:"P2PFS_MAX_TRANSFERS_PER_PEER"` // Одновременных передач с одним узлом в каждом направлении (0 - без ограничения)
+	QueuePolicy  string `yaml:"queue_policy" toml:"queue_policy" env:"P2PFS_QUEUE_POLICY"`                               // Политика очереди отправки: block, drop-oldest, disconnect
+
+	PeerUpload   Rate                 `yaml:"peer_upload" toml:"peer_upload" env:"P2PFS_PEER_LIMIT_UP"`       // Скорость отправки одному узлу, если для него нет собственного лимита
+	PeerDownload Rate                 `yaml:"peer_download" toml:"peer_download" env:"P2PFS_PEER_LIMIT_DOWN"` // Скорость приёма от одного узла, если для него нет собственного лимита
+	Peers        map[string]PeerLimit `yaml:"peers" toml:"peers"`                                             // Собственные лимиты узлов: адрес, идентификатор или имя -> лимиты
+}
+
+// PeerLimit - ограничения скорости обмена с одним узлом.
+type PeerLimit struct {
+	Upload   Rate `yaml:"upload" toml:"upload"`     // Скорость отправки узлу
+	Download Rate `yaml:"download" toml:"download"` // Скорость приёма от узла
+}
+
+// TLS - ключи для WebSocket поверх TLS.
+type TLS struct {
+	Cert string `yaml:"cert" toml:"cert" env:"P2PFS_TLS_CERT" path:"true"` // Сертификат узла в PEM
+	Key  string `yaml:"key" toml:"key" env:"P2PFS_TLS_KEY" path:"true"`    // Закрытый ключ сертификата в PEM
+	CA   string `yaml:"ca" toml:"ca" env:"P2PFS_TLS_CA" path:"true"`       // Корневые сертификаты для проверки узлов ("" - системные)
+}
+
+// Log - настройки журнала.
+type Log struct {
+	Level  string `yaml:"level" toml:"level" env:"P2PFS_LOG_LEVEL"`    // debug, info, warn, error ("" - по умолчанию для команды)
+	Format string `yaml:"format" toml:"format" env:"P2PFS_LOG_FORMAT"` // text или json
+}
+
+// Rate - скорость в байтах в секунду. В файле, окружении и флагах задаётся
+// как "512K", "10M", "1G" или "0" (без ограничения).
+type Rate int64
+
+// UnmarshalText разбирает скорость.
+func (r *Rate) UnmarshalText(text []byte) error {
+	v, err := ratelimit.ParseRate(string(text))
+	if err != nil {
+		return err
+	}
+	*r = Rate(v)
+	return nil
+}
+
+// MarshalText возвращает скорость в виде, принимаемом UnmarshalText.
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// String возвращает скорость вида "512K" (или число байт, если оно не кратно KiB).
+func (r Rate) String() string {
+	for _, u := range []struct {
+		suffix string
+		size   Rate
+	}{{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}} {
+		if r != 0 && r%u.size == 0 {
+			return strconv.FormatInt(int64(r/u.size), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(r), 10)
+}
+
+// Default возвращает настройки по умолчанию.
+func Default() *Config {
+	return &Config{
+		Identity: Identity{Name: "testPeer", Host: "localhost"},
+		Listen:   Listen{Port: "8080", QUIC: true},
+		Limits: Limits{
+			MaxTransfers: transfer.DefaultMaxActive,
+			MaxPerPeer:   transfer.DefaultMaxPerPeer,
+			QueuePolicy:  "block",
+		},
+		DownloadDir: DefaultDownloadDir(),
+		Log:         Log{Format: "text"},
+	}
+}
+
+// DefaultDownloadDir возвращает каталог загрузок по умолчанию: Downloads/p2pfs в домашнем
+// каталоге пользователя, а без него - downloads в Dir. Путь абсолютный, поэтому не зависит
+// от рабочего каталога. Если оба каталога неизвестны - "" (файлы не принимаются).
+func DefaultDownloadDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, "Downloads", dirName)
+	}
+	if dir, err := Dir(); err == nil {
+		return filepath.Join(dir, "downloads")
+	}
+	return ""
+}
+
+// ControlAddr возвращает адрес API управления; если он не задан - Unix-сокет p2pfs.sock
+// в каталоге пользователя: $XDG_RUNTIME_DIR, а без него - Dir. Общий временный каталог
+// не подходит: там сокет мог бы заранее создать другой пользователь.
+// Консольный режим без заданного адреса API не запускает.
+func (c *Config) ControlAddr() string {
+	if c.Control != "" {
+		return c.Control
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = Dir(); err != nil {
+			// Каталог, доступный только пользователю, создаётся при открытии сокета
+			dir = filepath.Join(os.TempDir(), dirName+"-"+strconv.Itoa(os.Getuid()))
+		}
+	}
+	return "unix://" + filepath.Join(dir, "p2pfs.sock")
+}
+
+// Dir возвращает каталог настроек пользователя, например ~/.config/p2pfs.
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, dirName), nil
+}
+
+// Load загружает настройки: значения по умолчанию, затем файл path, затем переменные окружения.
+// Пустой path означает файл из переменной P2PFS_CONFIG, а если она не задана -
+// config.yaml, config.yml или config.toml в Dir (при их наличии). Файл .env рядом
+// с файлом настроек (или в Dir) дополняет окружение, не переопределяя заданные переменные.
+// Относительные пути в файле отсчитываются от его каталога, поэтому результат
+// не зависит от рабочего каталога.
+func Load(path string) (*Config, error) {
+	c := Default()
+	if path == "" {
+		path = os.Getenv(EnvPath)
+	}
+	dir, _ := Dir()
+	if path == "" && dir != "" {
+		path = findFile(dir)
+	}
+
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+		dir = filepath.Dir(path)
+	}
+
+	dotenv := map[string]string{}
+	if dir != "" {
+		envFile := filepath.Join(dir, ".env")
+		if _, err := os.Stat(envFile); err == nil {
+			if dotenv, err = godotenv.Read(envFile); err != nil {
+				return nil, fmt.Errorf("config: %s: %w", envFile, err)
+			}
+		}
+	}
+	err := c.applyEnv(func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		v, ok := dotenv[key]
+		return v, ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// findFile возвращает первый существующий файл настроек в dir или "".
+func findFile(dir string) string {
+	for _, name := range defaultFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadFile накладывает на c настройки из файла YAML или TOML.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	var keys []string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		keys, err = c.decodeYAML(data)
+	case ".toml":
+		keys, err = c.decodeTOML(data)
+	default:
+		err = fmt.Errorf("неизвестный формат %q, ожидается .yaml, .yml или .toml", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	c.File = abs
+	base := filepath.Dir(abs)
+	for _, f := range c.fields() {
+		if !f.path || !slices.Contains(keys, f.key) {
+			continue
+		}
+		if v := f.value.String(); v != "" && !filepath.IsAbs(v) {
+			f.value.SetString(filepath.Join(base, v))
+		}
+	}
+	return nil
+}
+
+// applyEnv накладывает на c значения переменных окружения, найденных через lookup.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for _, f := range c.fields() {
+		if f.env == "" {
+			continue
+		}
+		if v, ok := lookup(f.env); ok {
+			if err := f.setString(v); err != nil {
+				return fmt.Errorf("config: переменная %s: %w", f.env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Config возвращает настройки TLS для WebSocket поверх TLS или nil, если ключи не заданы.
+func (t TLS) Config() (*tls.Config, error) {
+	if t.Cert == "" && t.Key == "" && t.CA == "" {
+		return nil, nil
+	}
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.Cert != "" || t.Key != "" {
+		cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, fmt.Errorf("config: ключи TLS: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if t.CA != "" {
+		pem, err := os.ReadFile(t.CA)
+		if err != nil {
+			return nil, fmt.Errorf("config: корневые сертификаты: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("config: в " + t.CA + " нет сертификатов PEM")
+		}
+		conf.RootCAs = pool
 	}
+	return conf, nil
 }
 
 // Get retrieves the value of the environment variable named by the key.
 // It returns the value and a boolean indicating whether the key was found.
+//
+// Deprecated: use Load and the fields of Config instead.
 func Get(key string) (string, bool) {
 	return os.LookupEnv(key)
 }
 
 // MustGet retrieves the value of the environment variable named by the key.
 // If the key is not found, it will panic with a message indicating the missing key.
+//
+// Deprecated: use Load and Config.Validate instead.
 func MustGet(key string) string {
 	if value, ok := os.LookupEnv(key); !ok {
 		panic("Missing environment variable: " + key)
@@ -31,6 +342,10 @@ func MustGet(key string) string {
 	}
 }
 
+// DefaultGet retrieves the value of the environment variable named by the key,
+// or defaulValue if the key is not found.
+//
+// Deprecated: use Load and the defaults from Default instead.
 func DefaultGet(key string, defaulValue string) string {
 	if value, ok := os.LookupEnv(key); !ok {
 		return defaulValue
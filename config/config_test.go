@@ -0,0 +1,230 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig создаёт во временном каталоге файл настроек name с содержимым data.
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clearEnv снимает на время теста переменные окружения настроек.
+func clearEnv(t *testing.T) {
+	for _, f := range Default().fields() {
+		if f.env != "" {
+			t.Setenv(f.env, "") // Восстанавливает прежнее значение после теста
+			os.Unsetenv(f.env)
+		}
+	}
+}
+
+const yamlConfig = `
+identity:
+  name: alice
+listen:
+  port: "9000"
+  quic: false
+bootstrap:
+  peers: ["b:1", "quic://c:2"]
+download_dir: dl
+limits:
+  upload: 512K
+  max_transfers: 8
+  peers:
+    bob: {upload: 256K, download: 1M}
+    "10.0.0.2:8080": {download: 2M}
+tls:
+  ca: /etc/ssl/ca.pem
+`
+
+const tomlConfig = `
+download_dir = "dl"
+
+[identity]
+name = "alice"
+
+[listen]
+port = "9000"
+quic = false
+
+[bootstrap]
+peers = ["b:1", "quic://c:2"]
+
+[limits]
+upload = "512K"
+max_transfers = 8
+
+[limits.peers.bob]
+upload = "256K"
+download = "1M"
+
+[limits.peers."10.0.0.2:8080"]
+download = "2M"
+
+[tls]
+ca = "/etc/ssl/ca.pem"
+`
+
+func TestLoad(t *testing.T) {
+	clearEnv(t)
+	for _, name := range []string{"config.yaml", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			data := yamlConfig
+			if strings.HasSuffix(name, ".toml") {
+				data = tomlConfig
+			}
+			path := writeConfig(t, name, data)
+			c, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := Default()
+			want.File = path
+			want.Identity.Name = "alice"
+			want.Listen.Port, want.Listen.QUIC = "9000", false
+			want.Bootstrap.Peers = []string{"b:1", "quic://c:2"}
+			want.DownloadDir = filepath.Join(filepath.Dir(path), "dl") // Относительно файла настроек
+			want.Limits.Upload = 512 << 10
+			want.Limits.MaxTransfers = 8
+			want.Limits.Peers = map[string]PeerLimit{
+				"bob":           {Upload: 256 << 10, Download: 1 << 20},
+				"10.0.0.2:8080": {Download: 2 << 20},
+			}
+			want.TLS.CA = "/etc/ssl/ca.pem"
+			if !reflect.DeepEqual(c, want) {
+				t.Fatalf("загружено\n%+v\nожидалось\n%+v", c, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name, file, data string
+		want             string // Фрагмент сообщения об ошибке
+	}{
+		{"неизвестный ключ yaml", "c.yaml", "identity:\n  nmae: alice\n", "nmae"},
+		{"неизвестный ключ toml", "c.toml", "[identity]\nnmae = \"alice\"\n", "identity.nmae"},
+		{"некорректная скорость", "c.yaml", "limits:\n  upload: fast\n", "некорректная скорость"},
+		{"некорректная скорость узла", "c.toml", "[limits.peers.bob]\nupload = \"fast\"\n", "limits.peers.bob.upload"},
+		{"неизвестный формат", "c.json", "{}", ".json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.file, tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load: %v, ожидалась ошибка с %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	clearEnv(t)
+	for _, name := range []string{"config.yaml", "config.toml"} {
+		path := writeConfig(t, name, "")
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if c.DownloadDir != DefaultDownloadDir() || !filepath.IsAbs(c.DownloadDir) || c.File != path {
+			t.Fatalf("%s: %+v", name, c)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"P2PFS_USERNAME_PEER":   "bob",
+		"P2PFS_QUIC":            "false",
+		"P2PFS_MAX_TRANSFERS":   "3",
+		"P2PFS_LIMIT_DOWN":      "2M",
+		"P2PFS_BOOTSTRAP_PEERS": "a:1, ,b:2",
+		"LOG_LEVEL":             "debug", // Без префикса - переменная другой программы
+	}
+	c := Default()
+	err := c.applyEnv(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Identity.Name != "bob" || c.Listen.QUIC || c.Limits.MaxTransfers != 3 || c.Limits.Download != 2<<20 ||
+		!reflect.DeepEqual(c.Bootstrap.Peers, []string{"a:1", "b:2"}) || c.Log.Level != "" {
+		t.Fatalf("после окружения %+v", c)
+	}
+
+	for key, value := range map[string]string{"P2PFS_QUIC": "yes please", "P2PFS_MAX_TRANSFERS": "many", "P2PFS_LIMIT_UP": "fast"} {
+		err := Default().applyEnv(func(k string) (string, bool) { return value, k == key })
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Fatalf("%s=%q: %v", key, value, err)
+		}
+	}
+}
+
+func TestRateString(t *testing.T) {
+	tests := []struct {
+		rate Rate
+		want string
+	}{
+		{0, "0"},
+		{1000, "1000"},
+		{512 << 10, "512K"},
+		{3 << 19, "1536K"},
+		{10 << 20, "10M"},
+		{1 << 30, "1G"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.rate.String(); got != tt.want {
+				t.Fatalf("Rate(%d).String() = %q", tt.rate, got)
+			}
+			var back Rate
+			if err := back.UnmarshalText([]byte(tt.want)); err != nil || back != tt.rate {
+				t.Fatalf("UnmarshalText(%q) = %d, %v", tt.want, back, err)
+			}
+		})
+	}
+}
+
+func TestControlAddr(t *testing.T) {
+	dir, err := Dir()
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name    string
+		control string
+		runtime string // XDG_RUNTIME_DIR ("" - не задан)
+		want    string
+	}{
+		{"задан явно", "127.0.0.1:7070", "/run/user/1000", "127.0.0.1:7070"},
+		{"каталог сеанса", "", "/run/user/1000", "unix:///run/user/1000/p2pfs.sock"},
+		{"каталог настроек", "", "", "unix://" + filepath.Join(dir, "p2pfs.sock")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_RUNTIME_DIR", tt.runtime)
+			if tt.runtime == "" {
+				os.Unsetenv("XDG_RUNTIME_DIR")
+			}
+			c := Default()
+			c.Control = tt.control
+			if got := c.ControlAddr(); got != tt.want {
+				t.Fatalf("ControlAddr() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// field - параметр настроек: ключ в файле, переменная окружения и поле Config.
+type field struct {
+	key   string        // Ключ в файле, например "identity.name"
+	env   string        // Переменная окружения ("" - не задаётся через окружение)
+	path  bool          // Путь к файлу: относительный путь в файле отсчитывается от его каталога
+	value reflect.Value // Поле в Config
+}
+
+// String возвращает ключ параметра и его переменную окружения для сообщений об ошибках.
+func (f field) String() string {
+	if f.env == "" {
+		return f.key
+	}
+	return f.key + " (" + f.env + ")"
+}
+
+// fields возвращает параметры настроек c по тегам yaml, env и path.
+func (c *Config) fields() []field {
+	return collectFields(reflect.ValueOf(c).Elem(), "", nil)
+}
+
+// lookupField возвращает параметр по ключу в файле.
+func (c *Config) lookupField(key string) (field, bool) {
+	for _, f := range c.fields() {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// textUnmarshaler - тип reflect для encoding.TextUnmarshaler
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// collectFields обходит поля структуры v, добавляя к ключам префикс prefix.
+func collectFields(v reflect.Value, prefix string, out []field) []field {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		fv := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct && !reflect.PointerTo(sf.Type).Implements(textUnmarshaler) {
+			out = collectFields(fv, key+".", out)
+			continue
+		}
+		out = append(out, field{key: key, env: sf.Tag.Get("env"), path: sf.Tag.Get("path") == "true", value: fv})
+	}
+	return out
+}
+
+// setString задаёт значение параметра из строки (окружение): числа и логические значения
+// разбираются, списки разделяются запятыми.
+func (f field) setString(s string) error {
+	if u, ok := f.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("ожидается целое число, получено %q", s)
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("ожидается true или false, получено %q", s)
+		}
+		f.value.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", f.value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeYAML накладывает на c настройки из YAML и возвращает заданные в нём ключи.
+// Неизвестные ключи считаются ошибкой.
+func (c *Config) decodeYAML(data []byte) ([]string, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil // Пустой файл
+		}
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var keys []string
+	if len(doc.Content) > 0 {
+		keys = yamlKeys(doc.Content[0], "", keys)
+	}
+	return keys, nil
+}
+
+// yamlKeys собирает ключи значений вложенных отображений YAML.
+func yamlKeys(n *yaml.Node, prefix string, out []string) []string {
+	if n.Kind != yaml.MappingNode {
+		return append(out, strings.TrimSuffix(prefix, "."))
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		out = yamlKeys(n.Content[i+1], prefix+n.Content[i].Value+".", out)
+	}
+	return out
+}
+
+// decodeTOML накладывает на c настройки из TOML и возвращает заданные в нём ключи.
+// Неизвестные ключи считаются ошибкой.
+func (c *Config) decodeTOML(data []byte) ([]string, error) {
+	md, err := toml.Decode(string(data), c)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("неизвестный параметр %q", undecoded[0].String())
+	}
+	keys := make([]string, 0, len(md.Keys()))
+	for _, key := range md.Keys() {
+		keys = append(keys, key.String())
+	}
+	return keys, nil
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/WhiCu/p2pFileShare/logging"
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// ValidationError - ошибки проверки настроек, по одной на параметр.
+type ValidationError struct {
+	Problems []string // Описания вида "listen.port (P2PFS_PORT_PEER): ..."
+}
+
+// Error перечисляет все найденные ошибки.
+func (e *ValidationError) Error() string {
+	return "config: некорректные настройки:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate проверяет настройки и возвращает *ValidationError со всеми найденными ошибками.
+func (c *Config) Validate() error {
+	v := validator{c: c}
+
+	v.check("identity.name", nonEmpty(c.Identity.Name))
+	v.check("identity.host", nonEmpty(c.Identity.Host))
+	if c.Identity.Advertise != "" {
+		v.check("identity.advertise", checkAdvertise(c.Identity.Advertise))
+	}
+	v.check("listen.port", checkPort(c.Listen.Port))
+	if c.Listen.WS != "" {
+		v.check("listen.ws", checkHostPort(wsHostPort(c.Listen.WS)))
+	}
+	if c.Listen.WSS != "" {
+		v.check("listen.wss", checkHostPort(wsHostPort(c.Listen.WSS)))
+		if c.TLS.Cert == "" || c.TLS.Key == "" {
+			v.check("listen.wss", errors.New("для WebSocket поверх TLS нужны tls.cert и tls.key"))
+		}
+	}
+	for _, origin := range c.Listen.WSOrigins {
+		v.check("listen.ws_origins", checkOrigin(origin))
+	}
+
+	for i, peer := range c.Bootstrap.Peers {
+		_, addr := transport.SplitAddr(peer)
+		if err := checkHostPort(addr); err != nil {
+			v.check("bootstrap.peers", fmt.Errorf("узел %d (%q): %w", i+1, peer, err))
+		}
+	}
+	if c.Bootstrap.Port != "" {
+		v.check("bootstrap.port", checkPort(c.Bootstrap.Port))
+	}
+
+	if c.Limits.MaxTransfers < 0 {
+		v.check("limits.max_transfers", errors.New("не может быть отрицательным"))
+	}
+	if c.Limits.MaxPerPeer < 0 {
+		v.check("limits.max_transfers_per_peer", errors.New("не может быть отрицательным"))
+	}
+	for name := range c.Limits.Peers {
+		if strings.TrimSpace(name) == "" {
+			v.check("limits.peers", errors.New("не задан адрес, идентификатор или имя узла"))
+		}
+	}
+	if _, err := connection.ParseQueuePolicy(c.Limits.QueuePolicy); err != nil {
+		v.check("limits.queue_policy", fmt.Errorf("ожидается block, drop-oldest или disconnect, получено %q", c.Limits.QueuePolicy))
+	}
+
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		v.check("tls", errors.New("tls.cert и tls.key задаются вместе"))
+	}
+	v.check("tls.cert", checkFile(c.TLS.Cert))
+	v.check("tls.key", checkFile(c.TLS.Key))
+	v.check("tls.ca", checkFile(c.TLS.CA))
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		v.check("log.level", errors.New("ожидается debug, info, warn или error"))
+	}
+	if f := strings.ToLower(c.Log.Format); f != logging.FormatText && f != logging.FormatJSON {
+		v.check("log.format", fmt.Errorf("ожидается text или json, получено %q", c.Log.Format))
+	}
+
+	if c.Control != "" {
+		v.check("control", checkControl(c.Control))
+	}
+	if c.Metrics != "" {
+		v.check("metrics", checkHostPort(c.Metrics))
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validator собирает ошибки проверки.
+type validator struct {
+	c        *Config
+	problems []string
+}
+
+// check добавляет ошибку err параметра key, если она есть.
+func (v *validator) check(key string, err error) {
+	if err == nil {
+		return
+	}
+	name := key
+	if f, ok := v.c.lookupField(key); ok {
+		name = f.String()
+	}
+	v.problems = append(v.problems, name+": "+err.Error())
+}
+
+// nonEmpty проверяет, что значение задано.
+func nonEmpty(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return errors.New("не задано")
+	}
+	return nil
+}
+
+// checkPort проверяет номер порта (0 - любой свободный).
+func checkPort(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("некорректный порт %q", s)
+	}
+	return nil
+}
+
+// checkHostPort проверяет адрес вида "host:port".
+func checkHostPort(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("ожидается адрес host:port, получено %q", addr)
+	}
+	return checkPort(port)
+}
+
+// checkAdvertise проверяет внешний адрес узла вида "host" или "host:port".
+func checkAdvertise(addr string) error {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return checkHostPort(addr)
+	}
+	if net.ParseIP(addr) == nil && strings.ContainsAny(addr, ":/ []") {
+		return fmt.Errorf("ожидается host или host:port, получено %q", addr)
+	}
+	return nil
+}
+
+// wsHostPort отделяет "host:port" от пути ресурса в адресе WebSocket.
+func wsHostPort(addr string) string {
+	hostport, _, _ := strings.Cut(addr, "/")
+	return hostport
+}
+
+// checkOrigin проверяет источник страницы вида "https://host[:port]".
+func checkOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+		return fmt.Errorf("ожидается источник вида https://host[:port], получено %q", origin)
+	}
+	return nil
+}
+
+// checkFile проверяет, что файл (если задан) существует.
+func checkFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("файл недоступен: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s - каталог, ожидается файл", path)
+	}
+	return nil
+}
+
+// checkControl проверяет адрес API управления: "unix:///путь" или "host:port".
+func checkControl(addr string) error {
+	scheme, address := transport.SplitAddr(addr)
+	switch scheme {
+	case "unix":
+		if address == "" {
+			return errors.New("не задан путь к сокету")
+		}
+		return nil
+	case "tcp":
+		return checkHostPort(address)
+	}
+	return fmt.Errorf("ожидается unix:///путь или host:port, получено %q", addr)
+}
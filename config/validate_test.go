@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cert := writeConfig(t, "cert.pem", "")
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string // Параметры, на которые должна указать проверка ("" - настройки корректны)
+	}{
+		{"по умолчанию", func(c *Config) {}, nil},
+		{"пустое имя", func(c *Config) { c.Identity.Name = " " }, []string{"identity.name (P2PFS_USERNAME_PEER)"}},
+		{"внешний адрес", func(c *Config) { c.Identity.Advertise = "203.0.113.5:9000" }, nil},
+		{"внешний адрес без порта", func(c *Config) { c.Identity.Advertise = "2001:db8::1" }, nil},
+		{"некорректный внешний адрес", func(c *Config) { c.Identity.Advertise = "http://peer.example" }, []string{"identity.advertise (P2PFS_ADVERTISE_ADDR)"}},
+		{"некорректный порт", func(c *Config) { c.Listen.Port = "70000" }, []string{"listen.port (P2PFS_PORT_PEER)"}},
+		{"любой порт", func(c *Config) { c.Listen.Port = "0" }, nil},
+		{"websocket с путём", func(c *Config) { c.Listen.WS = "0.0.0.0:8081/p2p" }, nil},
+		{"источники WebSocket", func(c *Config) { c.Listen.WSOrigins = []string{"https://app.example", "http://127.0.0.1:8081/"} }, nil},
+		{"некорректный источник WebSocket", func(c *Config) { c.Listen.WSOrigins = []string{"app.example"} }, []string{"listen.ws_origins (P2PFS_WS_ORIGINS)"}},
+		{"wss без ключей", func(c *Config) { c.Listen.WSS = "0.0.0.0:8443" }, []string{"listen.wss (P2PFS_WSS_ADDR)"}},
+		{"адреса узлов", func(c *Config) { c.Bootstrap.Peers = []string{"b:1", "quic://c:2", "nohost"} }, []string{"bootstrap.peers (P2PFS_BOOTSTRAP_PEERS)"}},
+		{"отрицательные лимиты", func(c *Config) { c.Limits.MaxTransfers, c.Limits.MaxPerPeer = -1, -1 },
+			[]string{"limits.max_transfers (P2PFS_MAX_TRANSFERS)", "limits.max_transfers_per_peer (P2PFS_MAX_TRANSFERS_PER_PEER)"}},
+		{"лимит без узла", func(c *Config) { c.Limits.Peers = map[string]PeerLimit{"": {Upload: 1}} }, []string{"limits.peers"}},
+		{"политика очереди", func(c *Config) { c.Limits.QueuePolicy = "drop-newest" }, []string{"limits.queue_policy (P2PFS_QUEUE_POLICY)"}},
+		{"сертификат без ключа", func(c *Config) { c.TLS.Cert = cert }, []string{"tls:"}},
+		{"файл не найден", func(c *Config) { c.TLS.CA = filepath.Join(t.TempDir(), "missing.pem") }, []string{"tls.ca (P2PFS_TLS_CA)"}},
+		{"уровень журнала", func(c *Config) { c.Log.Level = "verbose" }, []string{"log.level (P2PFS_LOG_LEVEL)"}},
+		{"формат журнала", func(c *Config) { c.Log.Format = "xml" }, []string{"log.format (P2PFS_LOG_FORMAT)"}},
+		{"API по unix-сокету", func(c *Config) { c.Control = "unix:///tmp/p2pfs.sock" }, nil},
+		{"API по tcp", func(c *Config) { c.Control = "127.0.0.1:7070" }, nil},
+		{"API без пути", func(c *Config) { c.Control = "unix://" }, []string{"control (P2PFS_CONTROL_ADDR)"}},
+		{"API по неизвестной схеме", func(c *Config) { c.Control = "ws://127.0.0.1:7070" }, []string{"control (P2PFS_CONTROL_ADDR)"}},
+		{"адрес метрик", func(c *Config) { c.Metrics = "9090" }, []string{"metrics (P2PFS_METRICS_ADDR)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.change(c)
+			err := c.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate: %v, ожидалась *ValidationError", err)
+			}
+			if len(verr.Problems) != len(tt.want) {
+				t.Fatalf("ошибки %q, ожидались по параметрам %q", verr.Problems, tt.want)
+			}
+			for i, key := range tt.want {
+				if !strings.HasPrefix(verr.Problems[i], key) {
+					t.Errorf("ошибка %q, ожидалась по параметру %s", verr.Problems[i], key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package control
+
+import (
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer"
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+// Status - сведения о локальном узле.
+type Status struct {
+	ID          string            `json:"id"`           // Идентификатор узла
+	Username    string            `json:"username"`     // Имя пользователя
+	Addr        string            `json:"addr"`         // Адрес основного транспорта
+	Listen      map[string]string `json:"listen"`       // Адреса слушателей по транспортам
+	DownloadDir string            `json:"download_dir"` // Каталог загрузок ("" - файлы не принимаются)
+}
+
+// Peer - активное соединение с узлом.
+type Peer struct {
+	Key        string   `json:"key"`                  // Ключ соединения в списке активных
+	Addr       string   `json:"addr"`                 // Адрес удалённого узла
+	PeerID     string   `json:"peer_id,omitempty"`    // Идентификатор узла (после рукопожатия)
+	Username   string   `json:"username,omitempty"`   // Имя пользователя узла
+	Outbound   bool     `json:"outbound"`             // Соединение установлено локальным узлом
+	Transports []string `json:"transports,omitempty"` // Транспорты узла
+	RTT        float64  `json:"rtt_ms,omitempty"`     // Сглаженное время приёма-передачи, мс
+	Loss       float64  `json:"loss"`                 // Доля пингов без ответа
+	QueueDepth int      `json:"queue_depth"`          // Сообщений в очереди отправки
+}
+
+// DesiredPeer - узел, соединение с которым поддерживается переподключениями.
+type DesiredPeer struct {
+	Address     string     `json:"address"`                // Адрес узла
+	State       string     `json:"state"`                  // Состояние подключения
+	Attempts    int        `json:"attempts"`               // Неудачных попыток подряд
+	NextAttempt *time.Time `json:"next_attempt,omitempty"` // Время следующей попытки
+	LastError   string     `json:"last_error,omitempty"`   // Последняя ошибка подключения
+}
+
+// Peers - ответ на запрос списка узлов.
+type Peers struct {
+	Connected []Peer        `json:"connected"` // Активные соединения
+	Desired   []DesiredPeer `json:"desired"`   // Желаемые узлы
+}
+
+// ChatMessage - сообщение чата.
+type ChatMessage struct {
+	Peer    string `json:"peer"`    // Адрес узла, с которым ведётся переписка
+	Sender  string `json:"sender"`  // Отправитель
+	Content string `json:"content"` // Текст сообщения
+}
+
+// Transfer - состояние передачи файла.
+type Transfer struct {
+	ID        string  `json:"id"`              // Идентификатор передачи
+	Filename  string  `json:"filename"`        // Имя файла
+	Peer      string  `json:"peer"`            // Адрес удалённого узла
+	Direction string  `json:"direction"`       // upload или download
+	State     string  `json:"state"`           // Состояние передачи
+	Done      int64   `json:"done"`            // Передано байт
+	Total     int64   `json:"total"`           // Размер файла в байтах
+	Rate      float64 `json:"rate"`            // Скорость, байт/с
+	ETA       float64 `json:"eta_s,omitempty"` // Оценка оставшегося времени, с
+	Finished  bool    `json:"finished"`        // Передача завершена (успешно или нет)
+	Error     string  `json:"error,omitempty"` // Ошибка для состояния failed
+	Path      string  `json:"path,omitempty"`  // Путь сохранённого файла (принятого успешно)
+}
+
+// Event - событие узла в потоке /events.
+type Event struct {
+	Type     string    `json:"type"`               // Тип события (peer-connected, text-received, ...)
+	Time     time.Time `json:"time"`               // Время события
+	Peer     string    `json:"peer,omitempty"`     // Адрес узла
+	Username string    `json:"username,omitempty"` // Имя пользователя узла
+	Sender   string    `json:"sender,omitempty"`   // Отправитель сообщения
+	Content  string    `json:"content,omitempty"`  // Текст сообщения
+	Filename string    `json:"filename,omitempty"` // Имя предложенного файла
+	Transfer string    `json:"transfer,omitempty"` // Идентификатор передачи
+}
+
+// ConnectRequest - запрос на подключение к узлу.
+type ConnectRequest struct {
+	Address string `json:"address"` // Адрес узла, например "host:port" или "quic://host:port"
+}
+
+// MessageRequest - запрос на отправку текстового сообщения.
+type MessageRequest struct {
+	Peer string `json:"peer,omitempty"` // Адрес, идентификатор или имя узла ("" - всем узлам)
+	Text string `json:"text"`           // Текст сообщения
+}
+
+// SendFileRequest - запрос на отправку файла.
+type SendFileRequest struct {
+	Peer     string `json:"peer,omitempty"`     // Адрес, идентификатор или имя узла ("" - всем узлам)
+	Path     string `json:"path"`               // Путь к файлу на машине узла
+	Priority int    `json:"priority,omitempty"` // Приоритет в очереди передач
+}
+
+// Share - файл, открытый узлом по ссылке.
+type Share struct {
+	ID   string `json:"id"`             // Идентификатор открытого файла
+	Path string `json:"path"`           // Путь к файлу на машине узла
+	Link string `json:"link,omitempty"` // Ссылка для команды get
+}
+
+// ShareFileRequest - запрос на открытие файла по ссылке.
+type ShareFileRequest struct {
+	Path string `json:"path"` // Путь к файлу на машине узла
+}
+
+// DownloadRequest - запрос файла по ссылке.
+type DownloadRequest struct {
+	Link string `json:"link"` // Ссылка вида "p2pfs://host:port/id"
+}
+
+// Download - запрошенный по ссылке файл.
+type Download struct {
+	ID          string `json:"id"`           // Идентификатор передачи, под которым файл будет принят
+	Peer        string `json:"peer"`         // Адрес узла-источника
+	DownloadDir string `json:"download_dir"` // Каталог, в который будет сохранён файл
+}
+
+// Error - ответ с ошибкой.
+type Error struct {
+	Error     string     `json:"error"`
+	Transfers []Transfer `json:"transfers,omitempty"` // Передачи, запущенные до ошибки
+}
+
+// newPeer формирует описание соединения.
+func newPeer(key string, conn *connection.Connection) Peer {
+	st := conn.Stats()
+	return Peer{
+		Key:        key,
+		Addr:       conn.Addr(),
+		PeerID:     conn.PeerID(),
+		Username:   conn.Username(),
+		Outbound:   conn.Outbound,
+		Transports: conn.Transports(),
+		RTT:        float64(st.SRTT) / float64(time.Millisecond),
+		Loss:       st.Loss(),
+		QueueDepth: conn.QueueStats().Depth,
+	}
+}
+
+// newDesiredPeer формирует описание желаемого узла.
+func newDesiredPeer(st peer.PeerStatus) DesiredPeer {
+	d := DesiredPeer{
+		Address:  st.Address,
+		State:    st.State.String(),
+		Attempts: st.Attempts,
+	}
+	if !st.NextAttempt.IsZero() {
+		next := st.NextAttempt
+		d.NextAttempt = &next
+	}
+	if st.LastError != nil {
+		d.LastError = st.LastError.Error()
+	}
+	return d
+}
+
+// newChatMessage формирует сообщение чата.
+func newChatMessage(peer string, msg message.Message) ChatMessage {
+	return ChatMessage{Peer: peer, Sender: msg.Sender, Content: msg.Content}
+}
+
+// newTransfer формирует описание передачи.
+func newTransfer(p transfer.Progress) Transfer {
+	t := Transfer{
+		ID:        p.ID,
+		Filename:  p.Filename,
+		Peer:      p.Peer,
+		Direction: p.Direction.String(),
+		State:     p.State.String(),
+		Done:      p.Done,
+		Total:     p.Total,
+		Rate:      p.Rate,
+		ETA:       p.ETA.Seconds(),
+		Finished:  p.Finished(),
+		Path:      p.Path,
+	}
+	if p.Err != nil {
+		t.Error = p.Err.Error()
+	}
+	return t
+}
+
+// newEvent формирует событие потока /events.
+func newEvent(e peer.Event) Event {
+	ev := Event{Type: e.Type.String(), Time: e.Time}
+	if e.Conn != nil {
+		ev.Peer = e.Conn.Addr()
+		ev.Username = e.Conn.Username()
+	}
+	if e.Message != nil {
+		ev.Sender = e.Message.Sender
+		ev.Content = e.Message.Content
+		ev.Filename = e.Message.Filename
+	}
+	if e.Task != nil {
+		ev.Transfer = e.Task.ID
+	}
+	return ev
+}
@@ -0,0 +1,310 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// pollInterval - интервал опроса состояния передачи в WaitTransfer
+const pollInterval = 200 * time.Millisecond
+
+// ErrNotFound - объект (узел, передача) не найден
+var ErrNotFound = errors.New("control: не найдено")
+
+// ErrForeignSocket - Unix-сокет API принадлежит другому пользователю
+var ErrForeignSocket = errors.New("control: сокет принадлежит другому пользователю")
+
+// unixHost - имя хоста в URL запросов к Unix-сокету; соединение всегда идёт в сокет
+const unixHost = "control"
+
+// Client - клиент API управления узлом.
+type Client struct {
+	http  *http.Client
+	base  string
+	token string // Ключ доступа ("" - не передаётся)
+}
+
+// NewClient создаёт клиента API по адресу вида "unix:///path/to.sock" или "127.0.0.1:port".
+func NewClient(addr string) (*Client, error) {
+	scheme, address := transport.SplitAddr(addr)
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	switch scheme {
+	case "unix":
+		path := address
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			if err := checkSocketOwner(path); err != nil {
+				return nil, err
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		address = unixHost
+	case "tcp":
+		dial = (&net.Dialer{}).DialContext
+	default:
+		return nil, fmt.Errorf("control: схема %q не поддерживается", scheme)
+	}
+	return &Client{
+		http: &http.Client{Transport: &http.Transport{DialContext: dial}},
+		base: "http://" + address,
+	}, nil
+}
+
+// SetToken задаёт ключ доступа, который передаётся в заголовке Authorization (см. Server.SetToken).
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Ping проверяет, что узел доступен по адресу клиента.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Status(ctx)
+	return err
+}
+
+// Status возвращает сведения об узле.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var st Status
+	err := c.do(ctx, http.MethodGet, "/status", nil, &st)
+	return st, err
+}
+
+// Peers возвращает активные соединения и желаемые узлы.
+func (c *Client) Peers(ctx context.Context) (Peers, error) {
+	var peers Peers
+	err := c.do(ctx, http.MethodGet, "/peers", nil, &peers)
+	return peers, err
+}
+
+// Connect подключает узел к address и ждёт установки соединения.
+func (c *Client) Connect(ctx context.Context, address string) (Peer, error) {
+	var p Peer
+	err := c.do(ctx, http.MethodPost, "/peers", ConnectRequest{Address: address}, &p)
+	return p, err
+}
+
+// Disconnect отключает узел от peer (адрес, идентификатор или имя).
+func (c *Client) Disconnect(ctx context.Context, peer string) error {
+	return c.do(ctx, http.MethodDelete, "/peers/"+url.PathEscape(peer), nil, nil)
+}
+
+// Messages возвращает историю чата с узлом peer ("" - со всеми узлами).
+func (c *Client) Messages(ctx context.Context, peer string) ([]ChatMessage, error) {
+	path := "/messages"
+	if peer != "" {
+		path += "?" + url.Values{"peer": {peer}}.Encode()
+	}
+	var msgs []ChatMessage
+	err := c.do(ctx, http.MethodGet, path, nil, &msgs)
+	return msgs, err
+}
+
+// SendMessage отправляет текстовое сообщение узлу peer ("" - всем узлам).
+func (c *Client) SendMessage(ctx context.Context, peer, text string) error {
+	return c.do(ctx, http.MethodPost, "/messages", MessageRequest{Peer: peer, Text: text}, nil)
+}
+
+// SendFile ставит в очередь отправку файла и возвращает начатые передачи.
+// Если файл не удалось отправить части узлов, возвращаются и передачи, запущенные
+// до ошибки, и *PartialError.
+func (c *Client) SendFile(ctx context.Context, req SendFileRequest) ([]Transfer, error) {
+	var started []Transfer
+	err := c.do(ctx, http.MethodPost, "/files", req, &started)
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		started = partial.Transfers
+	}
+	return started, err
+}
+
+// Shares возвращает файлы, открытые по ссылке.
+func (c *Client) Shares(ctx context.Context) ([]Share, error) {
+	var shares []Share
+	err := c.do(ctx, http.MethodGet, "/shares", nil, &shares)
+	return shares, err
+}
+
+// Share открывает файл по ссылке.
+func (c *Client) Share(ctx context.Context, path string) (Share, error) {
+	var share Share
+	err := c.do(ctx, http.MethodPost, "/shares", ShareFileRequest{Path: path}, &share)
+	return share, err
+}
+
+// Unshare закрывает доступ к файлу.
+func (c *Client) Unshare(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/shares/"+url.PathEscape(id), nil, nil)
+}
+
+// Download запрашивает файл по ссылке.
+func (c *Client) Download(ctx context.Context, link string) (Download, error) {
+	var d Download
+	err := c.do(ctx, http.MethodPost, "/downloads", DownloadRequest{Link: link}, &d)
+	return d, err
+}
+
+// Transfers возвращает идущие и недавно завершённые передачи.
+func (c *Client) Transfers(ctx context.Context) ([]Transfer, error) {
+	var list []Transfer
+	err := c.do(ctx, http.MethodGet, "/transfers", nil, &list)
+	return list, err
+}
+
+// Transfer возвращает состояние передачи. Неизвестная передача - ErrNotFound.
+func (c *Client) Transfer(ctx context.Context, id string) (Transfer, error) {
+	var t Transfer
+	err := c.do(ctx, http.MethodGet, "/transfers/"+url.PathEscape(id), nil, &t)
+	return t, err
+}
+
+// ControlTransfer выполняет действие над передачей: "pause", "resume" или "cancel".
+func (c *Client) ControlTransfer(ctx context.Context, id, action string) error {
+	return c.do(ctx, http.MethodPost, "/transfers/"+url.PathEscape(id)+"/"+url.PathEscape(action), nil, nil)
+}
+
+// WaitTransfer ждёт завершения передачи, вызывая progress (может быть nil) при каждом опросе.
+// Передача, которой ещё нет (например, узел-источник пока не начал отправку), ожидается до отмены ctx.
+// Возвращает итоговое состояние; ошибка передачи возвращается как error.
+func (c *Client) WaitTransfer(ctx context.Context, id string, progress func(Transfer)) (Transfer, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		t, err := c.Transfer(ctx, id)
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			return t, err
+		default:
+			if progress != nil {
+				progress(t)
+			}
+			if t.Finished {
+				if t.State != "completed" {
+					if t.Error == "" {
+						t.Error = t.State
+					}
+					return t, fmt.Errorf("передача %s: %s", t.Filename, t.Error)
+				}
+				return t, nil
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return t, ctx.Err()
+		}
+	}
+}
+
+// Events передаёт события узла в fn, пока не отменён ctx или узел не остановлен.
+func (c *Client) Events(ctx context.Context, fn func(Event)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/events", nil)
+	if err != nil {
+		return err
+	}
+	c.authorize(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("control: некорректное событие: %w", err)
+		}
+		fn(e)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+// Shutdown останавливает узел.
+func (c *Client) Shutdown(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/shutdown", nil, nil)
+}
+
+// do выполняет запрос с телом in (nil - без тела) и разбирает ответ в out (nil - без ответа).
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil || method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authorize(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return readError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// authorize добавляет к запросу ключ доступа, если он задан.
+func (c *Client) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// readError формирует ошибку из ответа с кодом ошибки.
+func readError(resp *http.Response) error {
+	var e Error
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+		e.Error = resp.Status
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, e.Error)
+	}
+	if len(e.Transfers) > 0 {
+		return &PartialError{Err: errors.New(e.Error), Transfers: e.Transfers}
+	}
+	return errors.New(e.Error)
+}
+
+// PartialError - ошибка запроса, часть которого уже выполнена:
+// Transfers содержит передачи, запущенные до ошибки.
+type PartialError struct {
+	Err       error
+	Transfers []Transfer
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%v (запущено передач: %d)", e.Err, len(e.Transfers))
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,582 @@
+// Пакет control предоставляет локальный API управления узлом (HTTP/JSON поверх
+// Unix-сокета или localhost), чтобы скрипты и другие программы могли управлять
+// узлом, работающим в фоне.
+//
+// Методы API:
+//
+//	GET    /status                  - сведения об узле
+//	GET    /peers                   - активные соединения и желаемые узлы
+//	POST   /peers                   - подключиться к узлу (ConnectRequest)
+//	DELETE /peers/{peer}            - отключиться от узла
+//	GET    /messages                - история чата
+//	POST   /messages                - отправить сообщение (MessageRequest)
+//	POST   /files                   - отправить файл (SendFileRequest)
+//	GET    /shares                  - файлы, открытые по ссылке
+//	POST   /shares                  - открыть файл по ссылке (ShareFileRequest)
+//	DELETE /shares/{id}             - закрыть доступ к файлу
+//	POST   /downloads               - запросить файл по ссылке (DownloadRequest)
+//	GET    /transfers               - идущие и недавно завершённые передачи
+//	GET    /transfers/{id}          - состояние передачи
+//	POST   /transfers/{id}/{action} - pause, resume или cancel
+//	GET    /events                  - поток событий, по одному JSON-объекту на строку
+//	POST   /shutdown                - остановить узел
+//
+// Запросы с заголовком Host, отличным от локального, отклоняются (защита от DNS rebinding),
+// а запросы, кроме GET, должны иметь тип application/json, чтобы страница в браузере
+// не могла отправить их без CORS-проверки. Для TCP-адреса следует задать ключ доступа
+// (Server.SetToken): любой локальный процесс может подключиться к порту.
+// Unix-сокет доступен только владельцу, а клиент подключается только к сокету,
+// принадлежащему текущему пользователю.
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer"
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+const (
+	// connectTimeout - время ожидания подключения к узлу по запросу POST /peers
+	connectTimeout = 30 * time.Second
+	// eventBuffer - число событий, ожидающих отправки клиенту /events; лишние отбрасываются
+	eventBuffer = 64
+)
+
+// Server - локальный API управления узлом.
+type Server struct {
+	peer  *peer.Peer
+	mux   *http.ServeMux
+	srv   *http.Server
+	token string // Ключ доступа ("" - не требуется)
+}
+
+// NewServer создаёт API управления узлом p.
+func NewServer(p *peer.Peer) *Server {
+	s := &Server{peer: p, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /status", s.status)
+	s.mux.HandleFunc("GET /peers", s.peers)
+	s.mux.HandleFunc("POST /peers", s.connect)
+	s.mux.HandleFunc("DELETE /peers/{peer}", s.disconnect)
+	s.mux.HandleFunc("GET /messages", s.messages)
+	s.mux.HandleFunc("POST /messages", s.send)
+	s.mux.HandleFunc("POST /files", s.sendFile)
+	s.mux.HandleFunc("GET /shares", s.shares)
+	s.mux.HandleFunc("POST /shares", s.openShare)
+	s.mux.HandleFunc("DELETE /shares/{id}", s.closeShare)
+	s.mux.HandleFunc("POST /downloads", s.download)
+	s.mux.HandleFunc("GET /transfers", s.transfers)
+	s.mux.HandleFunc("GET /transfers/{id}", s.transfer)
+	s.mux.HandleFunc("POST /transfers/{id}/{action}", s.control)
+	s.mux.HandleFunc("GET /events", s.events)
+	s.mux.HandleFunc("POST /shutdown", s.shutdown)
+	s.srv = &http.Server{Handler: s.Handler()}
+	return s
+}
+
+// SetToken задаёт ключ доступа: запросы без заголовка "Authorization: Bearer <token>"
+// отклоняются. Вызывается до Serve.
+func (s *Server) SetToken(token string) {
+	s.token = token
+}
+
+// NewToken создаёт случайный ключ доступа к API.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Handler возвращает HTTP-обработчик API с проверкой Host, типа содержимого и ключа доступа.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !localHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("недопустимый заголовок Host %q", r.Host))
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, errors.New("ожидается Content-Type: application/json"))
+				return
+			}
+		}
+		if s.token != "" {
+			auth := r.Header.Get("Authorization")
+			if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+s.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, errors.New("неверный ключ доступа"))
+				return
+			}
+		}
+		s.mux.ServeHTTP(w, r)
+	})
+}
+
+// localHost сообщает, что заголовок Host указывает на локальный узел:
+// loopback-адрес, "localhost" или имя "control", которое клиент использует для Unix-сокета.
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "localhost" || host == unixHost {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Listen открывает слушатель API по адресу вида "unix:///path/to.sock" или "127.0.0.1:port".
+// TCP-адрес должен указывать на loopback-интерфейс; к нему может подключиться любой
+// локальный процесс, поэтому для TCP задайте ключ доступа (Server.SetToken).
+// Сокет доступен только владельцу; недостающий каталог сокета создаётся с доступом
+// только для владельца. Оставшийся от прежнего запуска сокет,
+// который никто не слушает, удаляется; другие файлы по этому пути не трогаются.
+func Listen(addr string) (net.Listener, error) {
+	scheme, address := transport.SplitAddr(addr)
+	switch scheme {
+	case "unix":
+		if err := os.MkdirAll(filepath.Dir(address), 0o700); err != nil {
+			return nil, fmt.Errorf("control: не удалось создать каталог сокета: %w", err)
+		}
+		l, err := listenUnix(address)
+		if err != nil {
+			if conn, dialErr := net.Dial("unix", address); dialErr == nil {
+				conn.Close()
+				return nil, fmt.Errorf("control: сокет %s уже используется", address)
+			}
+			// Ошибка в адресе не должна стоить пользователю обычного файла
+			if info, statErr := os.Lstat(address); statErr != nil || info.Mode()&os.ModeSocket == 0 {
+				return nil, fmt.Errorf("control: не удалось открыть сокет %s: %w", address, err)
+			}
+			if rmErr := os.Remove(address); rmErr != nil {
+				return nil, fmt.Errorf("control: не удалось открыть сокет %s: %w", address, err)
+			}
+			if l, err = listenUnix(address); err != nil {
+				return nil, err
+			}
+		}
+		if err := os.Chmod(address, 0o600); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("control: не удалось ограничить доступ к сокету %s: %w", address, err)
+		}
+		return l, nil
+	case "tcp":
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("control: некорректный адрес %q: %w", addr, err)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("control: адрес %q не является локальным", addr)
+		}
+		return net.Listen("tcp", address)
+	}
+	return nil, fmt.Errorf("control: схема %q не поддерживается", scheme)
+}
+
+// Serve обслуживает запросы на слушателе l до вызова Close.
+func (s *Server) Serve(l net.Listener) error {
+	err := s.srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Close останавливает сервер и закрывает открытые соединения, включая потоки /events.
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// status отвечает сведениями об узле.
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	listen := make(map[string]string)
+	for name, addr := range s.peer.ListenAddrs() {
+		listen[name] = addr.String()
+	}
+	writeJSON(w, http.StatusOK, Status{
+		ID:          s.peer.ID,
+		Username:    s.peer.Username,
+		Addr:        s.peer.Addr(),
+		Listen:      listen,
+		DownloadDir: s.peer.DownloadDir(),
+	})
+}
+
+// peers отвечает списком соединений и желаемых узлов.
+func (s *Server) peers(w http.ResponseWriter, r *http.Request) {
+	resp := Peers{Connected: []Peer{}, Desired: []DesiredPeer{}}
+	s.peer.Connections.Range(func(key, value any) bool {
+		resp.Connected = append(resp.Connected, newPeer(key.(string), value.(*connection.Connection)))
+		return true
+	})
+	for _, st := range s.peer.PeerStates() {
+		resp.Desired = append(resp.Desired, newDesiredPeer(st))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// connect подключается к узлу и ждёт первого успешного подключения.
+func (s *Server) connect(w http.ResponseWriter, r *http.Request) {
+	var req ConnectRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Address == "" {
+		writeError(w, http.StatusBadRequest, errors.New("не указан адрес узла"))
+		return
+	}
+	if err := s.connectPeer(r.Context(), req.Address); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	conn, ok := s.peer.Connection(req.Address)
+	if !ok {
+		writeError(w, http.StatusBadGateway, errors.New("соединение закрыто сразу после установки"))
+		return
+	}
+	writeJSON(w, http.StatusOK, newPeer(req.Address, conn))
+}
+
+// connectPeer добавляет узел в желаемые и ждёт первого подключения не дольше connectTimeout.
+// Переподключения живут столько же, сколько узел: ctx запроса ограничивает только ожидание,
+// и после ответа клиенту узел не переходит в PeerFailed.
+func (s *Server) connectPeer(ctx context.Context, address string) error {
+	first := make(chan error, 1)
+	go func() { first <- s.peer.ConnectToPeer(context.Background(), address) }()
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	select {
+	case err := <-first:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("узел %s пока не подключён, попытки продолжаются: %w", address, ctx.Err())
+	}
+}
+
+// disconnect отключается от узла и прекращает переподключения к нему.
+func (s *Server) disconnect(w http.ResponseWriter, r *http.Request) {
+	if err := s.peer.DisconnectPeer(r.Context(), r.PathValue("peer")); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// messages отвечает историей чата со всеми узлами или с узлом из параметра peer.
+func (s *Server) messages(w http.ResponseWriter, r *http.Request) {
+	msgs := []ChatMessage{}
+	if name := r.URL.Query().Get("peer"); name != "" {
+		conn, ok := s.peer.Connection(name)
+		if !ok {
+			writeError(w, http.StatusNotFound, peer.ErrUnknownPeer)
+			return
+		}
+		for _, msg := range conn.Chat() {
+			msgs = append(msgs, newChatMessage(conn.Addr(), msg))
+		}
+	} else {
+		s.peer.Connections.Range(func(_, value any) bool {
+			conn := value.(*connection.Connection)
+			for _, msg := range conn.Chat() {
+				msgs = append(msgs, newChatMessage(conn.Addr(), msg))
+			}
+			return true
+		})
+	}
+	writeJSON(w, http.StatusOK, msgs)
+}
+
+// send отправляет текстовое сообщение одному или всем узлам.
+func (s *Server) send(w http.ResponseWriter, r *http.Request) {
+	var req MessageRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, errors.New("пустое сообщение"))
+		return
+	}
+	if req.Peer == "" {
+		s.peer.SendMessageToPeers(req.Text)
+	} else {
+		conn, ok := s.peer.Connection(req.Peer)
+		if !ok {
+			writeError(w, http.StatusNotFound, peer.ErrUnknownPeer)
+			return
+		}
+		s.peer.SendMessageToPeer(conn, req.Text, nil)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendFile ставит в очередь отправку файла одному или всем узлам и отвечает списком передач.
+// Если файл не удалось отправить одному из узлов, ответ с ошибкой содержит передачи,
+// уже поставленные в очередь.
+func (s *Server) sendFile(w http.ResponseWriter, r *http.Request) {
+	var req SendFileRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, errors.New("не указан путь к файлу"))
+		return
+	}
+	var conns []*connection.Connection
+	if req.Peer == "" {
+		s.peer.Connections.Range(func(_, value any) bool {
+			conns = append(conns, value.(*connection.Connection))
+			return true
+		})
+	} else if conn, ok := s.peer.Connection(req.Peer); ok {
+		conns = append(conns, conn)
+	} else {
+		writeError(w, http.StatusNotFound, peer.ErrUnknownPeer)
+		return
+	}
+	if len(conns) == 0 {
+		writeError(w, http.StatusConflict, errors.New("нет подключённых узлов"))
+		return
+	}
+
+	// Файл проверяется до постановки в очередь, чтобы ошибка в пути не оставляла
+	// часть отправок запущенными
+	if err := checkFile(req.Path); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	started := []Transfer{}
+	for _, conn := range conns {
+		task, err := s.peer.SendFile(conn, req.Path, req.Priority)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, Error{Error: err.Error(), Transfers: started})
+			return
+		}
+		started = append(started, newTransfer(task.Progress()))
+	}
+	writeJSON(w, http.StatusAccepted, started)
+}
+
+// checkFile проверяет, что path - обычный файл, доступный для чтения.
+func checkFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть файл: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("не удалось получить информацию о файле: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s не является обычным файлом", path)
+	}
+	return nil
+}
+
+// shares отвечает списком файлов, открытых по ссылке.
+func (s *Server) shares(w http.ResponseWriter, r *http.Request) {
+	list := []Share{}
+	for id, path := range s.peer.Shares() {
+		list = append(list, Share{ID: id, Path: path})
+	}
+	slices.SortFunc(list, func(a, b Share) int { return strings.Compare(a.Path, b.Path) })
+	writeJSON(w, http.StatusOK, list)
+}
+
+// openShare открывает файл по ссылке.
+func (s *Server) openShare(w http.ResponseWriter, r *http.Request) {
+	var req ShareFileRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	link, err := s.peer.Share(req.Path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, Share{ID: link.ID, Path: req.Path, Link: link.String()})
+}
+
+// closeShare закрывает доступ к файлу.
+func (s *Server) closeShare(w http.ResponseWriter, r *http.Request) {
+	s.peer.Unshare(r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// download подключается к узлу из ссылки и запрашивает у него файл.
+func (s *Server) download(w http.ResponseWriter, r *http.Request) {
+	var req DownloadRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	link, err := peer.ParseLink(req.Link)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	conn, ok := s.peer.Connection(link.Address)
+	if !ok {
+		if err := s.connectPeer(r.Context(), link.Address); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		if conn, ok = s.peer.Connection(link.Address); !ok {
+			writeError(w, http.StatusBadGateway, errors.New("соединение закрыто сразу после установки"))
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), connectTimeout)
+	defer cancel()
+	id, err := s.peer.RequestFile(ctx, conn, link.ID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, Download{ID: id, Peer: conn.Addr(), DownloadDir: s.peer.DownloadDir()})
+}
+
+// transfers отвечает списком идущих и недавно завершённых передач.
+func (s *Server) transfers(w http.ResponseWriter, r *http.Request) {
+	list := []Transfer{}
+	for _, p := range s.peer.Transfers.History() {
+		list = append(list, newTransfer(p))
+	}
+	for _, p := range s.peer.Transfers.List() {
+		list = append(list, newTransfer(p))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// transfer отвечает состоянием передачи по идентификатору.
+func (s *Server) transfer(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.find(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, transfer.ErrUnknownTask)
+		return
+	}
+	writeJSON(w, http.StatusOK, newTransfer(p))
+}
+
+// find ищет передачу среди идущих и недавно завершённых.
+func (s *Server) find(id string) (transfer.Progress, bool) {
+	if t, ok := s.peer.Transfers.Get(id); ok {
+		return t.Progress(), true
+	}
+	history := s.peer.Transfers.History()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ID == id {
+			return history[i], true
+		}
+	}
+	return transfer.Progress{}, false
+}
+
+// control приостанавливает, возобновляет или отменяет передачу.
+func (s *Server) control(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var err error
+	switch r.PathValue("action") {
+	case "pause":
+		err = s.peer.Transfers.Pause(id)
+	case "resume":
+		err = s.peer.Transfers.Resume(id)
+	case "cancel":
+		err = s.peer.Transfers.Cancel(id)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("неизвестное действие %q", r.PathValue("action")))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// events передаёт события узла, по одному JSON-объекту на строку, пока клиент не отключится.
+// Если клиент не успевает читать, события отбрасываются.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("поток событий не поддерживается"))
+		return
+	}
+	ch := make(chan peer.Event, eventBuffer)
+	unsubscribe := s.peer.Subscribe(func(e peer.Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case e := <-ch:
+			if err := enc.Encode(newEvent(e)); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-s.peer.Done():
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// shutdown останавливает узел после отправки ответа.
+func (s *Server) shutdown(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusAccepted)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), peer.ShutdownTimeout)
+		defer cancel()
+		s.peer.Shutdown(ctx)
+	}()
+}
+
+// readJSON разбирает тело запроса в v. При ошибке отвечает 400 и возвращает false.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("некорректный запрос: %w", err))
+		return false
+	}
+	return true
+}
+
+// writeJSON отвечает объектом v в формате JSON.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError отвечает ошибкой в формате Error.
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, Error{Error: err.Error()})
+}
@@ -0,0 +1,283 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer"
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// shortTempDir создаёт временный каталог с коротким путём: путь к сокету ограничен по длине.
+func shortTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "p2pfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// serve запускает API управления узлом p на слушателе addr и возвращает клиента к нему.
+func serve(t *testing.T, p *peer.Peer, addr, token string) *Client {
+	t.Helper()
+	l, err := Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(p)
+	s.SetToken(token)
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	if !strings.HasPrefix(addr, "unix://") {
+		addr = l.Addr().String() // Порт, выбранный системой
+	}
+	c, err := NewClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestHandlerGuard(t *testing.T) {
+	s := NewServer(peer.NewPeer("a", "a", "1", transport.NewMemory()))
+	const token = "secret"
+	s.SetToken(token)
+
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		contentType string
+		auth        string
+		want        int
+	}{
+		{"loopback", "GET", "127.0.0.1:7070", "", "Bearer " + token, http.StatusOK},
+		{"localhost", "GET", "localhost:7070", "", "Bearer " + token, http.StatusOK},
+		{"ipv6 loopback", "GET", "[::1]:7070", "", "Bearer " + token, http.StatusOK},
+		{"unix-сокет", "GET", unixHost, "", "Bearer " + token, http.StatusOK},
+		{"чужой Host", "GET", "evil.example:7070", "", "Bearer " + token, http.StatusForbidden},
+		{"внешний адрес", "GET", "192.168.1.10:7070", "", "Bearer " + token, http.StatusForbidden},
+		{"без ключа", "GET", "127.0.0.1:7070", "", "", http.StatusUnauthorized},
+		{"неверный ключ", "GET", "127.0.0.1:7070", "", "Bearer wrong", http.StatusUnauthorized},
+		{"POST без типа", "POST", "127.0.0.1:7070", "", "Bearer " + token, http.StatusUnsupportedMediaType},
+		{"POST формы", "POST", "127.0.0.1:7070", "text/plain", "Bearer " + token, http.StatusUnsupportedMediaType},
+		{"POST json", "POST", "127.0.0.1:7070", "application/json; charset=utf-8", "Bearer " + token, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/status"
+			if tt.method == "POST" {
+				path = "/peers" // Пустое тело запроса - некорректный JSON
+			}
+			req := httptest.NewRequest(tt.method, path, nil)
+			req.Host = tt.host
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("код %d, ожидался %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	a := peertest.Start(t, peer.NewPeer("a", "a", "1", transport.NewMemory()))
+	ctx := context.Background()
+
+	t.Run("tcp", func(t *testing.T) {
+		c := serve(t, a, "127.0.0.1:0", "secret")
+		if err := c.Ping(ctx); err == nil {
+			t.Fatal("запрос без ключа принят")
+		}
+		c.SetToken("secret")
+		st, err := c.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.ID != a.ID || st.Username != "a" {
+			t.Fatalf("сведения об узле %+v", st)
+		}
+	})
+
+	t.Run("unix", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "p2pfs") // Путь к сокету ограничен по длине
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		sock := filepath.Join(dir, "c.sock")
+		c := serve(t, a, "unix://"+sock, "")
+		info, err := os.Stat(sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Fatalf("права сокета %o, ожидались 600", perm)
+		}
+		if err := c.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("не локальный адрес", func(t *testing.T) {
+		if _, err := Listen("0.0.0.0:0"); err == nil {
+			t.Fatal("API открыт на внешнем интерфейсе")
+		}
+	})
+}
+
+func TestListenUnix(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, path string) // Что лежит по пути сокета до Listen
+		wantErr bool
+	}{
+		{"пустой путь", func(t *testing.T, path string) {}, false},
+		{"оставшийся сокет", func(t *testing.T, path string) {
+			l, err := net.Listen("unix", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+			l.Close()
+		}, false},
+		{"сокет занят", func(t *testing.T, path string) {
+			l, err := net.Listen("unix", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { l.Close() })
+		}, true},
+		{"обычный файл", func(t *testing.T, path string) {
+			if err := os.WriteFile(path, []byte("заметки"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(shortTempDir(t), "c.sock")
+			tt.prepare(t, path)
+			l, err := Listen("unix://" + path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Listen: %v", err)
+			}
+			if err != nil {
+				if _, statErr := os.Lstat(path); statErr != nil {
+					t.Fatalf("файл по пути сокета удалён: %v", statErr)
+				}
+				return
+			}
+			defer l.Close()
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o600 {
+				t.Fatalf("права сокета %o, ожидались 600", perm)
+			}
+		})
+	}
+}
+
+func TestConnectOutlivesRequest(t *testing.T) {
+	mem := transport.NewMemory()
+	a := peertest.Start(t, peer.NewPeer("a", "a", "1", mem))
+	b := peertest.Start(t, peer.NewPeer("b", "b", "1", mem))
+	s := NewServer(a)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("POST", "/peers", strings.NewReader(`{"address":"mem://b:1"}`)).WithContext(ctx)
+	req.Host = "127.0.0.1"
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	cancel() // Запрос завершён, как после ответа клиенту
+	if rec.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", rec.Code, rec.Body)
+	}
+	var p Peer
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil || p.Key != "mem://b:1" || !p.Outbound {
+		t.Fatalf("ответ %+v (%v)", p, err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	states := a.PeerStates()
+	if len(states) != 1 || states[0].State != peer.PeerConnected {
+		t.Fatalf("после ответа на запрос состояния %+v", states)
+	}
+	if _, ok := a.Connection(b.ID); !ok {
+		t.Fatal("соединение с b закрыто")
+	}
+}
+
+func TestSendFileValidation(t *testing.T) {
+	mem := transport.NewMemory()
+	a := peertest.Start(t, peer.NewPeer("a", "a", "1", mem))
+	for _, name := range []string{"b", "c"} {
+		peertest.Start(t, peer.NewPeer(name, name, "1", mem))
+		if err := a.ConnectToPeer(context.Background(), "mem://"+name+":1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := serve(t, a, "unix://"+filepath.Join(shortTempDir(t), "c.sock"), "")
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	for _, path := range []string{filepath.Join(dir, "missing.txt"), dir} {
+		started, err := c.SendFile(ctx, SendFileRequest{Path: path})
+		if err == nil || len(started) != 0 {
+			t.Fatalf("отправка %s: %v, передачи %+v", path, err, started)
+		}
+		if list := a.Transfers.List(); len(list) != 0 {
+			t.Fatalf("после ошибки в очереди передачи %+v", list)
+		}
+	}
+
+	file := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(file, []byte("данные"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	started, err := c.SendFile(ctx, SendFileRequest{Path: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(started) != 2 {
+		t.Fatalf("запущено передач %d, ожидалось 2", len(started))
+	}
+}
+
+func TestSendFilePartial(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusBadRequest, Error{Error: "узел недоступен", Transfers: []Transfer{{ID: "1"}}})
+	}))
+	t.Cleanup(srv.Close)
+	c, err := NewClient(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, err := c.SendFile(context.Background(), SendFileRequest{Path: "f.txt"})
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(started) != 1 || started[0].ID != "1" {
+		t.Fatalf("SendFile = %+v, %v", started, err)
+	}
+}
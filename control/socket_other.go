@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package control
+
+import "net"
+
+// listenUnix открывает Unix-сокет path; права на него задаются после создания.
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
+
+// checkSocketOwner не поддерживается: владелец файла в этой ОС не проверяется.
+func checkSocketOwner(string) error {
+	return nil
+}
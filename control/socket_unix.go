@@ -0,0 +1,43 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package control
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// umaskMu не даёт одновременным listenUnix восстановить чужую маску прав
+var umaskMu sync.Mutex
+
+// listenUnix открывает Unix-сокет path. Маска прав процесса на время создания
+// не даёт сокету хотя бы на мгновение стать доступным другим пользователям.
+func listenUnix(path string) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+	old := unix.Umask(0o177)
+	defer unix.Umask(old)
+	return net.Listen("unix", path)
+}
+
+// checkSocketOwner проверяет, что сокет path создан текущим пользователем, а не подменён
+// другим: иначе команды и пути к файлам ушли бы чужому процессу.
+func checkSocketOwner(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if uid := os.Getuid(); int(st.Uid) != uid {
+		return fmt.Errorf("%w: %s (uid %d)", ErrForeignSocket, path, st.Uid)
+	}
+	return nil
+}
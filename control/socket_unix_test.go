@@ -0,0 +1,46 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package control
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WhiCu/p2pFileShare/peer"
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+func TestListenCreatesPrivateDir(t *testing.T) {
+	dir := filepath.Join(shortTempDir(t), "run")
+	l, err := Listen("unix://" + filepath.Join(dir, "c.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Fatalf("права каталога сокета %o, ожидались 700", perm)
+	}
+}
+
+func TestClientForeignSocket(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("сменить владельца сокета может только root")
+	}
+	a := peertest.Start(t, peer.NewPeer("a", "a", "1", transport.NewMemory()))
+	sock := filepath.Join(shortTempDir(t), "c.sock")
+	c := serve(t, a, "unix://"+sock, "")
+	if err := os.Lchown(sock, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(context.Background()); !errors.Is(err, ErrForeignSocket) {
+		t.Fatalf("подключение к чужому сокету: %v", err)
+	}
+}
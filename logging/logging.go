@@ -0,0 +1,46 @@
+// Пакет logging создаёт структурированные журналы slog с настраиваемым
+// уровнем и форматом вывода.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Форматы вывода журнала.
+const (
+	FormatText = "text" // Строки вида key=value
+	FormatJSON = "json" // Одна JSON-запись на строку
+)
+
+// ParseLevel разбирает уровень журнала: "debug", "info", "warn" или "error"
+// (допускается смещение, например "info+2"). Пустая строка означает "info".
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("неизвестный уровень журнала %q", s)
+	}
+	return level, nil
+}
+
+// New создаёт журнал, пишущий в w записи уровня level и выше в формате format
+// (FormatText или FormatJSON; пустая строка - FormatText).
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("неизвестный формат журнала %q", format)
+}
@@ -1,23 +1,36 @@
 package bootstrap
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"sync"
+
+	"github.com/WhiCu/p2pFileShare/peer/transport"
 )
 
+// ErrServerClosed - сервер уже остановлен методом Close
+var ErrServerClosed = errors.New("bootstrap: сервер остановлен")
+
 type BootstrapServer struct {
-	Host  string
-	Port  string
-	Peers *sync.Map
+	Host      string
+	Port      string
+	Peers     *sync.Map
+	Transport transport.Transport // Транспорт, на котором работает сервер (по умолчанию TCP)
+	Logger    *slog.Logger        // Журнал сервера (nil - slog.Default())
+
+	mu       sync.Mutex
+	listener net.Listener // Слушатель запущенного сервера
+	closed   bool         // Сервер остановлен методом Close
 }
 
 func NewBootstrapServer(host string, port string, peerMap *sync.Map) *BootstrapServer {
 	return &BootstrapServer{
-		Host:  host,
-		Port:  port,
-		Peers: peerMap,
+		Host:      host,
+		Port:      port,
+		Peers:     peerMap,
+		Transport: transport.TCP(),
 	}
 }
 
@@ -26,53 +39,100 @@ func (p *BootstrapServer) Addr() string {
 	return net.JoinHostPort(p.Host, p.Port)
 }
 
-// TCPAddr возвращает TCP-адрес узла, который можно использовать для подключения или прослушивания
-func (p *BootstrapServer) TCPAddr() *net.TCPAddr {
-	address, err := net.ResolveTCPAddr("tcp", p.Addr())
+// logger возвращает журнал сервера.
+func (b *BootstrapServer) logger() *slog.Logger {
+	if b.Logger == nil {
+		return slog.Default()
+	}
+	return b.Logger
+}
+
+// TCPAddr возвращает адрес узла, который можно использовать для подключения или прослушивания
+func (p *BootstrapServer) TCPAddr() (net.Addr, error) {
+	address, err := p.Transport.ResolveAddr(p.Addr())
 	if err != nil {
-		log.Fatalf("%s.TCPAddr: не удалось разрешить TCP-адрес: %v", p.Addr(), err)
+		return nil, fmt.Errorf("bootstrap: не удалось разрешить адрес %s: %w", p.Addr(), err)
 	}
-	return address
+	return address, nil
 }
 
-// Start запускает сервер для приёма входящих соединений
-func (b *BootstrapServer) Start() {
-	listener, err := net.ListenTCP("tcp", b.TCPAddr())
+// Start запускает сервер для приёма входящих соединений.
+// Ошибка возвращается, если адрес не удалось разрешить или занять; после успешного
+// запуска соединения принимаются в фоне до вызова Close.
+func (b *BootstrapServer) Start() error {
+	address, err := b.TCPAddr()
+	if err != nil {
+		return err
+	}
+	listener, err := b.Transport.Listen(address.String())
 	if err != nil {
-		log.Fatalf("Ошибка при запуске Bootstrap-сервера: %v", err)
+		return fmt.Errorf("bootstrap: не удалось запустить сервер на %s: %w", address, err)
 	}
-	log.Printf("Bootstrap-сервер запущен на порту %s", b.Port)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	b.listener = listener
+	b.mu.Unlock()
+	b.logger().Info("Bootstrap-сервер запущен", "addr", listener.Addr().String())
+
+	go b.serve(listener)
+	return nil
+}
 
+// serve принимает соединения, пока слушатель не закрыт.
+func (b *BootstrapServer) serve(listener net.Listener) {
 	for {
 		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			b.logger().Info("Bootstrap-сервер остановлен", "addr", listener.Addr().String())
+			return
+		}
 		if err != nil {
-			log.Printf("Ошибка при приёме соединения: %v", err)
+			b.logger().Error("ошибка при приёме соединения", "err", err)
 			continue
 		}
 		go b.handleConnection(conn)
 	}
 }
 
+// Close останавливает сервер. Повторный вызов ничего не делает.
+func (b *BootstrapServer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.listener != nil {
+		return b.listener.Close()
+	}
+	return nil
+}
+
 // handleConnection обрабатывает подключение нового узла
 func (b *BootstrapServer) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
 	remoteAddr := conn.RemoteAddr().String()
-	log.Printf("Новое подключение от %s", remoteAddr)
+	log := b.logger().With("remote_addr", remoteAddr)
+	log.Debug("новое подключение")
 
 	if _, exists := b.Peers.Load(remoteAddr); exists {
-		log.Printf("Узел %s уже зарегистрирован", remoteAddr) // Как так вообще? Уже должен был подключен к данному Peer
+		log.Warn("узел уже зарегистрирован") // Как так вообще? Уже должен был подключен к данному Peer
 		return
 	}
-	b.sendPeerList(conn)
+	b.sendPeerList(conn, log)
 }
 
 // sendPeerList отправляет список всех известных узлов клиенту
-func (b *BootstrapServer) sendPeerList(conn net.Conn) {
+func (b *BootstrapServer) sendPeerList(conn net.Conn, log *slog.Logger) {
 
 	b.Peers.Range(func(key, value interface{}) bool {
 		if _, err := fmt.Fprintln(conn, key); err != nil {
-			log.Printf("Ошибка при отправке списка узлов: %v", err)
+			log.Error("ошибка при отправке списка узлов", "err", err)
 		}
 		return true
 	})
@@ -0,0 +1,87 @@
+package connection
+
+import (
+	"compress/gzip"
+	"io"
+	"slices"
+)
+
+// SupportedCompressions - алгоритмы сжатия потоков в порядке предпочтения
+var SupportedCompressions = []string{"gzip"}
+
+// compressor - сжимающая обёртка над записью в поток.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// newCompressor возвращает сжимающую обёртку над w для алгоритма name.
+func newCompressor(name string, w io.Writer) (compressor, bool) {
+	switch name {
+	case "gzip":
+		zw, _ := gzip.NewWriterLevel(w, gzip.BestSpeed)
+		return zw, true
+	}
+	return nil, false
+}
+
+// newDecompressor возвращает распаковывающую обёртку над r для алгоритма name.
+func newDecompressor(name string, r io.Reader) (io.Reader, bool) {
+	switch name {
+	case "gzip":
+		return &gzipReader{r: r}, true
+	}
+	return nil, false
+}
+
+// gzipReader откладывает чтение заголовка gzip до первого Read,
+// чтобы приём потока не ждал первых данных отправителя.
+type gzipReader struct {
+	r  io.Reader
+	zr *gzip.Reader
+}
+
+func (g *gzipReader) Read(p []byte) (int, error) {
+	if g.zr == nil {
+		zr, err := gzip.NewReader(g.r)
+		if err != nil {
+			return 0, err
+		}
+		zr.Multistream(false)
+		g.zr = zr
+	}
+	return g.zr.Read(p)
+}
+
+// negotiateCompression выбирает первый из поддерживаемых локально алгоритмов,
+// который поддерживает и удалённый узел. Пустая строка - без сжатия.
+func negotiateCompression(remote []string) string {
+	for _, name := range SupportedCompressions {
+		if slices.Contains(remote, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// SetCompression выбирает алгоритм сжатия по списку алгоритмов удалённого узла
+// (из сообщения "info"). Пустой список отключает сжатие.
+func (c *Connection) SetCompression(remote []string) {
+	name := negotiateCompression(remote)
+	c.mu.Lock()
+	c.compression = name
+	c.mu.Unlock()
+}
+
+// Compression возвращает согласованный алгоритм сжатия ("" - без сжатия).
+func (c *Connection) Compression() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.compression
+}
+
+// OpenCompressedStream открывает исходящий поток вида kind, данные которого сжимаются
+// согласованным алгоритмом. Если алгоритм не согласован, поток открывается без сжатия.
+func (c *Connection) OpenCompressedStream(kind string) (*Stream, error) {
+	return c.openStream(kind, c.Compression())
+}
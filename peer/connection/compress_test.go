@@ -0,0 +1,95 @@
+package connection
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/peer/ratelimit"
+)
+
+// received возвращает обработчик, передающий содержимое сообщений типа typ в канал.
+func received(typ string) (func(c *Connection, msg *message.Message), <-chan string) {
+	ch := make(chan string, 16)
+	return func(c *Connection, msg *message.Message) {
+		if msg.Type == typ {
+			ch <- msg.Content
+		}
+	}, ch
+}
+
+func TestCompressedStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote []string // Алгоритмы удалённого узла из рукопожатия
+		want   string   // Согласованный алгоритм
+	}{
+		{"без сжатия", nil, ""},
+		{"gzip", []string{"zstd", "gzip"}, "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle, got := received("data")
+			client, _ := pair(t, handle)
+			client.SetCompression(tt.remote)
+			s, err := client.OpenCompressedStream(StreamTransfer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if s.Encoding != tt.want {
+				t.Fatalf("сжатие %q, ожидалось %q", s.Encoding, tt.want)
+			}
+			for _, content := range []string{"первое", strings.Repeat("строка журнала\n", 1000)} {
+				if err := s.Send(message.Message{Type: "data", Content: content}); err != nil {
+					t.Fatal(err)
+				}
+				select {
+				case c := <-got:
+					if c != content {
+						t.Fatalf("принято %d байт вместо %d", len(c), len(content))
+					}
+				case <-time.After(waitTimeout):
+					t.Fatal("сообщение не принято")
+				}
+			}
+		})
+	}
+}
+
+func TestCompressedStreamThrottle(t *testing.T) {
+	const rate = 64 * 1024
+	handle, got := received("file-chunk")
+	client, server := pair(t, handle)
+	for _, c := range []*Connection{client, server} {
+		th := ratelimit.NewThrottle()
+		th.SetGlobal(rate, rate)
+		c.SetThrottle(th)
+	}
+	client.SetCompression(SupportedCompressions)
+	s, err := client.OpenCompressedStream(StreamTransfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Без сжатия 4 МБ шли бы через лимиты около минуты; в сжатом виде - несколько КБ
+	const chunks = 8
+	chunk := message.Message{Type: "file-chunk", Content: strings.Repeat("a", 512*1024)}
+	go func() {
+		for i := 0; i < chunks; i++ {
+			if s.Send(chunk) != nil {
+				return
+			}
+		}
+	}()
+	deadline := time.After(waitTimeout)
+	for i := 0; i < chunks; i++ {
+		select {
+		case <-got:
+		case <-deadline:
+			t.Fatalf("принято %d блоков из %d: лимит учитывает несжатый объём", i, chunks)
+		}
+	}
+}
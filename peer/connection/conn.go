@@ -3,89 +3,149 @@
 package connection
 
 import (
+	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/peer/ratelimit"
 )
 
 const (
 	// HeartbeatTimer - интервал между отправками пинга
 	HeartbeatTimer = 10 * time.Second
-	// HeartbeatDeadline - время ожидания отклика перед закрытием соединения
+	// HeartbeatDeadline - время ожидания ответа на пинг
 	HeartbeatDeadline = 15 * time.Second
+	// MaxMissedPongs - число пингов подряд без ответа, после которого узел считается недоступным
+	MaxMissedPongs = 3
 )
 
 // Connection представляет соединение между узлами P2P-сети.
+// Методы Connection безопасны для вызова из нескольких горутин; изменяемое
+// состояние доступно через методы, возвращающие копии.
 type Connection struct {
-	Conn       net.Conn          // Низкоуровневое сетевое соединение
-	LastActive time.Time         // Время последней активности
-	Username   string            // Имя пользователя узла
-	Save       bool              // Флаг сохранения истории чата
-	Chat       []message.Message // История чата
+	Conn     net.Conn // Низкоуровневое сетевое соединение
+	Outbound bool     // Соединение установлено локальным узлом
 
-	closeOnce sync.Once     // Гарантирует, что соединение закрывается один раз
-	closed    chan struct{} // Канал для завершения работы соединения
-	IsClosed  bool          // Флаг, указывающий, что соединение закрыто
+	mu          sync.RWMutex      // Защищает поля ниже
+	lastActive  time.Time         // Время последнего ответа узла на пинг
+	username    string            // Имя пользователя узла
+	chat        []message.Message // История чата
+	transports  []string          // Транспорты, поддерживаемые удалённым узлом
+	peerID      string            // Идентификатор удалённого узла (после рукопожатия)
+	compression string            // Согласованный алгоритм сжатия потоков передачи
+	log         *slog.Logger      // Журнал соединения с полями remote_addr и peer_id
+
+	save      atomic.Bool                        // Флаг сохранения истории чата
+	closeOnce sync.Once                          // Гарантирует, что соединение закрывается один раз
+	closed    chan struct{}                      // Канал для завершения работы соединения
+	ctx       context.Context                    // Контекст соединения, отменяется при закрытии
+	cancel    context.CancelFunc                 // Отменяет ctx
+	throttle  atomic.Pointer[ratelimit.Throttle] // Ограничение скорости массовых сообщений
+
+	session    session // Мультиплексор логических потоков поверх Conn
+	control    *Stream // Исходящий управляющий поток (info, heartbeat, управление передачами)
+	chatStream *Stream // Исходящий поток чата
+
+	reqMu   sync.Mutex                      // Защищает pending
+	pending map[string]chan message.Message // Ожидающие ответа запросы: RequestID -> канал ответа
+	reqSeq  atomic.Uint64                   // Счётчик идентификаторов запросов
+
+	stats   statsTracker   // Показатели качества соединения
+	traffic trafficCounter // Объём трафика
+
+	controlQueue *sendQueue    // Очередь исходящих сообщений управляющего потока
+	chatQueue    *sendQueue    // Очередь исходящих сообщений чата
+	policy       atomic.Int32  // Политика при заполненной очереди (QueuePolicy)
+	sent         atomic.Uint64 // Записано сообщений из очередей
+	dropped      atomic.Uint64 // Отброшено сообщений из очередей
 }
 
-// NewConnection создаёт новое соединение и запускает Heartbeat для проверки активности.
-func NewConnection(conn net.Conn, info message.Message) *Connection {
+// NewConnection создаёт новое соединение поверх conn и запускает Heartbeat для проверки активности.
+// Поверх conn поднимается мультиплексор потоков; outbound указывает, что соединение
+// установлено локальным узлом (определяет роль в мультиплексоре).
+// Сообщение info отправляется первым по управляющему потоку.
+// Записи журнала соединения идут в logger (nil - slog.Default()) с полем remote_addr.
+func NewConnection(conn net.Conn, outbound bool, info message.Message, logger *slog.Logger) *Connection {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	c := &Connection{
-		Username:   conn.RemoteAddr().String(),
+		log:        logger.With("remote_addr", conn.RemoteAddr().String()),
+		username:   conn.RemoteAddr().String(),
 		Conn:       conn,
-		LastActive: time.Now(),
-		Save:       true,
-		Chat:       make([]message.Message, 0),
+		lastActive: time.Now(),
+		chat:       make([]message.Message, 0),
+		Outbound:   outbound,
 		closed:     make(chan struct{}),
-		IsClosed:   false,
+		ctx:        ctx,
+		cancel:     cancel,
+		pending:    make(map[string]chan message.Message),
+	}
+	c.save.Store(true)
+	if err := c.openSession(outbound); err != nil {
+		c.log.Error("не удалось открыть потоки", "err", err)
+		c.Close()
+		return c
 	}
-	if !c.sendInfo(info) {
-		log.Printf("%s.NewConnection: не удалось отправить информацию о соединении", c.Addr())
+	if err := c.control.Send(info); err != nil {
+		c.log.Error("не удалось отправить информацию о соединении", "err", err)
 		c.Close()
+		return c
 	}
+	c.controlQueue = newSendQueue(c.control, QueueSize)
+	c.chatQueue = newSendQueue(c.chatStream, QueueSize)
+	go c.writer(c.controlQueue)
+	go c.writer(c.chatQueue)
 	go c.heartbeat()
 	return c
 }
 
-// sendInfo отправляет сообщение о соединении.
-func (c *Connection) sendInfo(messageInfo message.Message) bool {
-	data, err := messageInfo.Bytes()
-	if err != nil {
-		log.Printf("%s.sendInfo: не удалось преобразовать сообщение в строку: %v", c.Addr(), err)
-		return false
-	}
-	_, err = c.Conn.Write([]byte(data + "\n"))
-	if err != nil {
-		log.Printf("%s.sendInfo: не удалось отправить сообщение: %v", c.Addr(), err)
-		return false
-	}
-	return true
+// SetThrottle задаёт ограничение скорости для соединения.
+// Ограничиваются только массовые сообщения (Message.Bulk); служебные и текстовые
+// сообщения отправляются без ожидания и тем самым получают приоритет.
+func (c *Connection) SetThrottle(t *ratelimit.Throttle) {
+	c.throttle.Store(t)
 }
 
-// Send отправляет сообщение на удалённый узел.
+// SetSave включает или отключает сохранение истории чата.
+func (c *Connection) SetSave(save bool) {
+	c.save.Store(save)
+}
+
+// Send ставит сообщение в очередь отправки на удалённый узел.
+// Текстовые сообщения идут в поток чата, остальные - в управляющий поток.
+// Если очередь заполнена, поведение определяется политикой SetQueuePolicy.
 func (c *Connection) Send(msg message.Message) error {
-	data, err := msg.Bytes()
-	if err != nil {
-		log.Printf("%s.Send: не удалось преобразовать сообщение в строку: %v", c.Addr(), err)
-		return errors.New("не удалось преобразовать сообщение в строку")
+	return c.send(c.ctx, msg)
+}
+
+// send ставит сообщение в очередь; ожидание места в очереди прерывается отменой ctx.
+func (c *Connection) send(ctx context.Context, msg message.Message) error {
+	q := c.controlQueue
+	if msg.Type == "text" {
+		q = c.chatQueue
 	}
-	_, err = c.Conn.Write([]byte(data + "\n"))
-	if err != nil {
-		log.Printf("%s.Send: не удалось отправить сообщение: %v", c.Addr(), err)
-		c.Close()
-		return errors.New("не удалось отправить сообщение")
+	if q == nil {
+		return errors.New("соединение не установлено")
+	}
+	if err := c.enqueue(ctx, q, msg); err != nil {
+		return err
 	}
-	if msg.Type == "text" && c.Save {
+	if msg.Type == "text" && c.save.Load() {
 		c.AddMessages(msg)
 	}
 	return nil
 }
 
-// heartbeat отправляет сообщение каждые HeartbeatTimer секунд для проверки активности узла.
+// heartbeat отправляет пинг каждые HeartbeatTimer и ждёт ответа не дольше HeartbeatDeadline.
+// Соединение закрывается, если MaxMissedPongs пингов подряд остались без ответа.
 func (c *Connection) heartbeat() {
 	ticker := time.NewTicker(HeartbeatTimer)
 	defer ticker.Stop()
@@ -93,55 +153,170 @@ func (c *Connection) heartbeat() {
 	for {
 		select {
 		case <-ticker.C:
-			msg := message.Message{
-				Type:   "heartbeat",
-				Sender: c.Addr(),
-			}
-
-			// Устанавливаем таймаут на отправку пинга
-			if err := c.Conn.SetWriteDeadline(time.Now().Add(HeartbeatDeadline)); err != nil {
-				log.Printf("Ошибка установки дедлайна записи для узла %s: %v", c.Addr(), err)
-				c.Close()
-				return
-			}
-
-			if err := c.Send(msg); err != nil {
-				log.Printf("Ошибка при отправке пинга узлу %s: %v", c.Addr(), err)
+			if !c.ping() {
 				c.Close()
 				return
 			}
 
-			c.LastActive = time.Now()
-
 		case <-c.closed:
-			log.Printf("Heartbeat остановлен для узла %s", c.Addr())
+			c.Logger().Debug("heartbeat остановлен")
 			return
 		}
 	}
 }
 
+// ping отправляет пинг и учитывает ответ в статистике соединения.
+// Возвращает false, если узел следует считать недоступным.
+func (c *Connection) ping() bool {
+	ctx, cancel := context.WithTimeout(c.ctx, HeartbeatDeadline)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.Request(ctx, message.Message{
+		Type:   "ping",
+		Sender: c.Addr(),
+	})
+	switch {
+	case err == nil || errors.Is(err, ErrRemote): // Ответ с ошибкой тоже подтверждает, что узел жив
+		c.stats.pong(time.Since(start))
+		c.mu.Lock()
+		c.lastActive = time.Now()
+		c.mu.Unlock()
+		return true
+	case errors.Is(err, context.DeadlineExceeded):
+		missed := c.stats.miss()
+		c.Logger().Warn("узел не ответил на пинг", "missed", missed, "max_missed", MaxMissedPongs)
+		return missed < MaxMissedPongs
+	default:
+		c.Logger().Warn("ошибка при отправке пинга", "err", err)
+		return false
+	}
+}
+
+// Stats возвращает показатели качества соединения.
+func (c *Connection) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// Username возвращает имя пользователя удалённого узла.
+func (c *Connection) Username() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.username
+}
+
 // SetUsername устанавливает имя пользователя для соединения.
 func (c *Connection) SetUsername(username string) {
-	c.Username = username
+	c.mu.Lock()
+	c.username = username
+	c.mu.Unlock()
+}
+
+// Transports возвращает копию списка транспортов, поддерживаемых удалённым узлом.
+func (c *Connection) Transports() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Clone(c.transports)
+}
+
+// SetTransports сохраняет список транспортов, поддерживаемых удалённым узлом.
+func (c *Connection) SetTransports(transports []string) {
+	c.mu.Lock()
+	c.transports = slices.Clone(transports)
+	c.mu.Unlock()
+}
+
+// PeerID возвращает идентификатор удалённого узла ("" до рукопожатия).
+func (c *Connection) PeerID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.peerID
+}
+
+// SetPeerID сохраняет идентификатор удалённого узла и добавляет его в журнал соединения.
+func (c *Connection) SetPeerID(id string) {
+	c.mu.Lock()
+	if c.peerID == "" {
+		c.log = c.log.With("peer_id", id)
+	}
+	c.peerID = id
+	c.mu.Unlock()
+}
+
+// ThrottleKey возвращает ключ ограничений скорости удалённого узла: его идентификатор,
+// а до рукопожатия - адрес соединения. Так лимит узла не зависит от эфемерного порта
+// входящего соединения и сохраняется при переподключении.
+func (c *Connection) ThrottleKey() string {
+	if id := c.PeerID(); id != "" {
+		return id
+	}
+	return c.Addr()
+}
+
+// Logger возвращает журнал соединения с полями remote_addr и peer_id (после рукопожатия).
+func (c *Connection) Logger() *slog.Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.log
+}
+
+// LastActive возвращает время последнего ответа узла на пинг.
+func (c *Connection) LastActive() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastActive
+}
+
+// Chat возвращает копию истории чата.
+func (c *Connection) Chat() []message.Message {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Clone(c.chat)
 }
 
 // Close завершает соединение и останавливает Heartbeat.
 func (c *Connection) Close() {
 	c.closeOnce.Do(func() {
-		log.Printf("Закрытие соединения с узлом %s", c.Addr())
+		c.Logger().Debug("закрытие соединения")
 		close(c.closed) // Закрываем канал для остановки Heartbeat
-		if err := c.Conn.Close(); err != nil {
-			log.Printf("Ошибка при закрытии соединения с узлом %s: %v", c.Addr(), err)
+		c.cancel()
+		if c.session != nil {
+			c.session.Close()
+		}
+		if err := c.Conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			c.Logger().Warn("ошибка при закрытии соединения", "err", err)
 		}
-		c.IsClosed = true
 	})
 }
 
+// IsClosed сообщает, закрыто ли соединение.
+func (c *Connection) IsClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Context возвращает контекст соединения, который отменяется при его закрытии.
+func (c *Connection) Context() context.Context {
+	return c.ctx
+}
+
+// Done возвращает канал, который закрывается при закрытии соединения.
+func (c *Connection) Done() <-chan struct{} {
+	return c.closed
+}
+
 // Addr возвращает адрес удалённого узла в формате "host:port".
 func (c *Connection) Addr() string {
 	return c.Conn.RemoteAddr().String()
 }
 
+// AddMessages добавляет сообщения в историю чата.
 func (c *Connection) AddMessages(messages ...message.Message) {
-	c.Chat = append(c.Chat, messages...)
+	c.mu.Lock()
+	c.chat = append(c.chat, messages...)
+	c.mu.Unlock()
 }
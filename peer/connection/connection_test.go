@@ -0,0 +1,96 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// waitTimeout - предельное время ожидания событий в тестах
+const waitTimeout = 5 * time.Second
+
+// discard - журнал, отбрасывающий записи
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// pair соединяет два узла через транспорт в памяти. Входящие сообщения каждой
+// стороны передаются handle; ответы на запросы сопоставляются через Resolve.
+func pair(t *testing.T, handle func(c *Connection, msg *message.Message)) (client, server *Connection) {
+	t.Helper()
+	mem := transport.NewMemory()
+	l, err := mem.Listen("server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	accepted := make(chan *Connection, 1)
+	go func() {
+		raw, err := l.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- NewConnection(raw, false, message.Message{Type: "info", Sender: "server"}, discard)
+	}()
+	raw, err := mem.Dial(context.Background(), "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = NewConnection(raw, true, message.Message{Type: "info", Sender: "client"}, discard)
+	server = <-accepted
+	if server == nil {
+		t.Fatal("соединение не принято")
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	for _, c := range []*Connection{client, server} {
+		go serve(c, handle)
+	}
+	return client, server
+}
+
+// serve принимает потоки соединения и читает из них сообщения, пока соединение открыто.
+func serve(c *Connection, handle func(c *Connection, msg *message.Message)) {
+	for {
+		s, err := c.AcceptStream()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer s.Close()
+			for {
+				msg, err := s.Receive()
+				if errors.Is(err, ErrBadMessage) {
+					continue
+				}
+				if err != nil {
+					return
+				}
+				if c.Resolve(msg) || handle == nil {
+					continue
+				}
+				handle(c, msg)
+			}
+		}()
+	}
+}
+
+// echo отвечает на запросы: "ping" - "pong", "fail" - ошибкой, "echo" - тем же содержимым.
+func echo(c *Connection, msg *message.Message) {
+	switch msg.Type {
+	case "ping":
+		c.Reply(msg, message.Message{Type: "pong"})
+	case "fail":
+		c.ReplyError(msg, errors.New("не получилось"))
+	case "echo":
+		c.Reply(msg, message.Message{Type: "echo", Content: msg.Content})
+	}
+}
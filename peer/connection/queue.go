@@ -0,0 +1,159 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+)
+
+const (
+	// QueueSize - ёмкость очереди исходящих сообщений одного потока
+	QueueSize = 256
+	// WriteTimeout - время на запись одного сообщения, после которого соединение закрывается
+	WriteTimeout = 15 * time.Second
+)
+
+// ErrQueueFull - очередь исходящих сообщений заполнена (политика QueueDisconnect)
+var ErrQueueFull = errors.New("connection: очередь отправки заполнена")
+
+// QueuePolicy определяет поведение Send при заполненной очереди исходящих сообщений.
+type QueuePolicy int
+
+const (
+	QueueBlock      QueuePolicy = iota // Ждать освобождения места
+	QueueDropOldest                    // Отбросить самое старое сообщение очереди
+	QueueDisconnect                    // Закрыть соединение с медленным узлом
+)
+
+// String возвращает название политики.
+func (p QueuePolicy) String() string {
+	switch p {
+	case QueueBlock:
+		return "block"
+	case QueueDropOldest:
+		return "drop-oldest"
+	case QueueDisconnect:
+		return "disconnect"
+	}
+	return "unknown"
+}
+
+// ParseQueuePolicy разбирает название политики ("block", "drop-oldest", "disconnect").
+func ParseQueuePolicy(s string) (QueuePolicy, error) {
+	for _, p := range []QueuePolicy{QueueBlock, QueueDropOldest, QueueDisconnect} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("connection: неизвестная политика очереди %q", s)
+}
+
+// QueueStats - показатели очередей исходящих сообщений соединения (управляющего потока и чата).
+type QueueStats struct {
+	Policy   QueuePolicy // Политика при заполненной очереди
+	Depth    int         // Сообщений в очередях
+	Capacity int         // Общая ёмкость очередей
+	Sent     uint64      // Записано сообщений
+	Dropped  uint64      // Отброшено сообщений (QueueDropOldest)
+}
+
+// sendQueue - очередь исходящих сообщений одного потока. У каждого потока своя очередь
+// и горутина записи: запись, остановленная управлением потоком у медленного узла,
+// задерживает только свой поток, и служебные сообщения не задерживают чат.
+type sendQueue struct {
+	stream *Stream
+	ch     chan message.Message
+}
+
+func newSendQueue(s *Stream, size int) *sendQueue {
+	return &sendQueue{stream: s, ch: make(chan message.Message, size)}
+}
+
+// SetQueuePolicy задаёт поведение Send при заполненной очереди исходящих сообщений.
+// При QueueDropOldest могут теряться и служебные сообщения, поэтому она подходит
+// узлам, для которых важнее свежесть данных, чем их полнота.
+func (c *Connection) SetQueuePolicy(p QueuePolicy) {
+	c.policy.Store(int32(p))
+}
+
+// QueueStats возвращает показатели очередей исходящих сообщений.
+func (c *Connection) QueueStats() QueueStats {
+	st := QueueStats{
+		Policy:  QueuePolicy(c.policy.Load()),
+		Sent:    c.sent.Load(),
+		Dropped: c.dropped.Load(),
+	}
+	for _, q := range []*sendQueue{c.controlQueue, c.chatQueue} {
+		if q != nil {
+			st.Depth += len(q.ch)
+			st.Capacity += cap(q.ch)
+		}
+	}
+	return st
+}
+
+// enqueue ставит сообщение в очередь q согласно политике соединения.
+// При политике QueueBlock ожидание прерывается отменой ctx или закрытием соединения.
+func (c *Connection) enqueue(ctx context.Context, q *sendQueue, msg message.Message) error {
+	switch QueuePolicy(c.policy.Load()) {
+	case QueueDropOldest:
+		for {
+			select {
+			case q.ch <- msg:
+				return nil
+			case <-c.closed:
+				return ErrClosed
+			default:
+			}
+			select {
+			case <-q.ch:
+				c.dropped.Add(1)
+			default:
+			}
+		}
+
+	case QueueDisconnect:
+		select {
+		case q.ch <- msg:
+			return nil
+		case <-c.closed:
+			return ErrClosed
+		default:
+			c.Logger().Warn("очередь отправки заполнена, соединение закрывается", "msg_type", msg.Type, "stream", q.stream.Kind)
+			c.Close()
+			return ErrQueueFull
+		}
+
+	default:
+		select {
+		case q.ch <- msg:
+			return nil
+		case <-c.closed:
+			return ErrClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// writer записывает сообщения из очереди q в её поток, пока соединение не закрыто.
+// Ошибка или превышение WriteTimeout при записи закрывают соединение.
+func (c *Connection) writer(q *sendQueue) {
+	for {
+		select {
+		case msg := <-q.ch:
+			q.stream.raw.SetWriteDeadline(time.Now().Add(WriteTimeout))
+			if err := q.stream.Send(msg); err != nil {
+				c.Logger().Warn("не удалось отправить сообщение", "msg_type", msg.Type, "stream", q.stream.Kind, "err", err)
+				c.Close()
+				return
+			}
+			c.sent.Add(1)
+		case <-c.closed:
+			return
+		}
+	}
+}
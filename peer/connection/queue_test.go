@@ -0,0 +1,132 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+)
+
+// queued создаёт соединение с очередью управляющего потока без горутины записи,
+// чтобы очередь не разбиралась.
+func queued(t *testing.T, policy QueuePolicy, size int) *Connection {
+	t.Helper()
+	local, remote := net.Pipe()
+	t.Cleanup(func() { remote.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Connection{
+		Conn:   local,
+		log:    discard,
+		closed: make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	c.controlQueue = newSendQueue(&Stream{Kind: StreamControl}, size)
+	c.SetQueuePolicy(policy)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestQueuePolicy(t *testing.T) {
+	tests := []struct {
+		policy    QueuePolicy
+		wantErr   error // Ошибка постановки сообщения в заполненную очередь
+		wantQueue []string
+		dropped   uint64
+		closed    bool
+	}{
+		{QueueBlock, context.DeadlineExceeded, []string{"1", "2"}, 0, false},
+		{QueueDropOldest, nil, []string{"2", "3"}, 1, false},
+		{QueueDisconnect, ErrQueueFull, []string{"1", "2"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			c := queued(t, tt.policy, 2)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			for _, content := range []string{"1", "2"} {
+				if err := c.enqueue(ctx, c.controlQueue, message.Message{Content: content}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := c.enqueue(ctx, c.controlQueue, message.Message{Content: "3"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("enqueue в заполненную очередь: %v, ожидалась %v", err, tt.wantErr)
+			}
+
+			st := c.QueueStats()
+			if st.Policy != tt.policy || st.Depth != len(tt.wantQueue) || st.Capacity != 2 || st.Dropped != tt.dropped {
+				t.Fatalf("QueueStats = %+v", st)
+			}
+			if c.IsClosed() != tt.closed {
+				t.Fatalf("соединение закрыто: %v, ожидалось %v", c.IsClosed(), tt.closed)
+			}
+			for _, want := range tt.wantQueue {
+				if o := <-c.controlQueue.ch; o.Content != want {
+					t.Fatalf("в очереди %q, ожидалось %q", o.Content, want)
+				}
+			}
+		})
+	}
+}
+
+func TestQueueClosed(t *testing.T) {
+	for _, policy := range []QueuePolicy{QueueBlock, QueueDropOldest, QueueDisconnect} {
+		t.Run(policy.String(), func(t *testing.T) {
+			c := queued(t, policy, 0)
+			c.Close()
+			if err := c.enqueue(context.Background(), c.controlQueue, message.Message{}); !errors.Is(err, ErrClosed) {
+				t.Fatalf("enqueue после закрытия: %v", err)
+			}
+		})
+	}
+}
+
+func TestQueueStreamsIndependent(t *testing.T) {
+	release := make(chan struct{})
+	chat := make(chan string, 1)
+	client, _ := pair(t, func(c *Connection, msg *message.Message) {
+		switch msg.Type {
+		case "blob":
+			<-release // Узел не читает управляющий поток: окно потока заполняется
+		case "text":
+			chat <- msg.Content
+		}
+	})
+	t.Cleanup(func() { close(release) })
+
+	// Больше окна управления потоком: запись управляющего потока останавливается
+	blob := message.Message{Type: "blob", Content: strings.Repeat("x", 64*1024)}
+	for i := 0; i < 2*streamWindowSize/len(blob.Content); i++ {
+		if err := client.Send(blob); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Send(message.Message{Type: "text", Content: "привет"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-chat:
+		if got != "привет" {
+			t.Fatalf("принято %q", got)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("сообщение чата задержано заблокированным управляющим потоком")
+	}
+}
+
+func TestParseQueuePolicy(t *testing.T) {
+	for _, p := range []QueuePolicy{QueueBlock, QueueDropOldest, QueueDisconnect} {
+		got, err := ParseQueuePolicy(p.String())
+		if err != nil || got != p {
+			t.Fatalf("ParseQueuePolicy(%q) = %v, %v", p, got, err)
+		}
+	}
+	if _, err := ParseQueuePolicy("drop-newest"); err == nil {
+		t.Fatal("неизвестная политика принята")
+	}
+}
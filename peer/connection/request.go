@@ -0,0 +1,96 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+)
+
+// DefaultRequestTimeout - время ожидания ответа, если у контекста запроса нет дедлайна
+const DefaultRequestTimeout = 30 * time.Second
+
+var (
+	// ErrClosed - соединение закрыто
+	ErrClosed = errors.New("connection: соединение закрыто")
+	// ErrRemote - удалённый узел не смог обработать запрос
+	ErrRemote = errors.New("connection: ошибка на удалённом узле")
+)
+
+// Request отправляет запрос и ждёт ответа на него.
+// Сообщению присваивается новый RequestID; ответ сопоставляется по ReplyTo.
+// Ожидание прерывается отменой ctx, закрытием соединения или по истечении
+// DefaultRequestTimeout, если у ctx нет дедлайна. Ответ с непустым полем Error
+// возвращается вместе с ошибкой, оборачивающей ErrRemote.
+func (c *Connection) Request(ctx context.Context, msg message.Message) (message.Message, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultRequestTimeout)
+		defer cancel()
+	}
+
+	id := strconv.FormatUint(c.reqSeq.Add(1), 10)
+	msg.RequestID = id
+	msg.ReplyTo = ""
+	reply := make(chan message.Message, 1)
+
+	c.reqMu.Lock()
+	c.pending[id] = reply
+	c.reqMu.Unlock()
+	defer func() {
+		c.reqMu.Lock()
+		delete(c.pending, id)
+		c.reqMu.Unlock()
+	}()
+
+	if err := c.send(ctx, msg); err != nil {
+		return message.Message{}, err
+	}
+
+	select {
+	case r := <-reply:
+		if r.Error != "" {
+			return r, fmt.Errorf("%w: %s", ErrRemote, r.Error)
+		}
+		return r, nil
+	case <-ctx.Done():
+		return message.Message{}, ctx.Err()
+	case <-c.closed:
+		return message.Message{}, ErrClosed
+	}
+}
+
+// Reply отправляет ответ на запрос req.
+func (c *Connection) Reply(req *message.Message, reply message.Message) error {
+	reply.ReplyTo = req.RequestID
+	reply.RequestID = ""
+	return c.Send(reply)
+}
+
+// ReplyError отправляет ответ с ошибкой обработки запроса req.
+func (c *Connection) ReplyError(req *message.Message, err error) error {
+	return c.Reply(req, message.Message{
+		Type:  req.Type,
+		Error: err.Error(),
+	})
+}
+
+// Resolve передаёт ответ ожидающему вызову Request.
+// Возвращает false, если сообщение не является ответом на запрос этого соединения;
+// ответы на уже завершённые запросы отбрасываются, и Resolve возвращает true.
+func (c *Connection) Resolve(msg *message.Message) bool {
+	if !msg.IsReply() {
+		return false
+	}
+	c.reqMu.Lock()
+	reply, ok := c.pending[msg.ReplyTo]
+	delete(c.pending, msg.ReplyTo)
+	c.reqMu.Unlock()
+	if ok {
+		reply <- *msg
+	}
+	return true
+}
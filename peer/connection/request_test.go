@@ -0,0 +1,115 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+)
+
+func TestRequestReply(t *testing.T) {
+	client, _ := pair(t, echo)
+
+	tests := []struct {
+		name    string
+		req     message.Message
+		want    string
+		wantErr error
+	}{
+		{"ответ", message.Message{Type: "echo", Content: "привет"}, "привет", nil},
+		{"ответ с ошибкой", message.Message{Type: "fail"}, "", ErrRemote},
+		{"нет ответа", message.Message{Type: "silence"}, "", context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			reply, err := client.Request(ctx, tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Request: %v, ожидалась %v", err, tt.wantErr)
+			}
+			if reply.Content != tt.want {
+				t.Fatalf("ответ %q, ожидался %q", reply.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestConcurrentReplies(t *testing.T) {
+	client, _ := pair(t, echo)
+
+	const n = 32
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(content string) {
+			ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+			defer cancel()
+			reply, err := client.Request(ctx, message.Message{Type: "echo", Content: content})
+			if err == nil && reply.Content != content {
+				err = errors.New("ответ " + reply.Content + " пришёл на запрос " + content)
+			}
+			errs <- err
+		}(string(rune('A' + i)))
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c := &Connection{pending: make(map[string]chan message.Message)}
+	waiting := make(chan message.Message, 1)
+	c.pending["1"] = waiting
+
+	tests := []struct {
+		name      string
+		msg       message.Message
+		want      bool
+		delivered bool
+	}{
+		{"не ответ", message.Message{Type: "text"}, false, false},
+		{"запрос", message.Message{Type: "ping", RequestID: "1"}, false, false},
+		{"ответ на завершённый запрос", message.Message{Type: "pong", ReplyTo: "7"}, true, false},
+		{"ответ ожидающему запросу", message.Message{Type: "pong", ReplyTo: "1"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Resolve(&tt.msg); got != tt.want {
+				t.Fatalf("Resolve = %v, ожидалось %v", got, tt.want)
+			}
+			select {
+			case <-waiting:
+				if !tt.delivered {
+					t.Fatal("ответ передан не тому запросу")
+				}
+			default:
+				if tt.delivered {
+					t.Fatal("ответ не передан ожидающему запросу")
+				}
+			}
+		})
+	}
+}
+
+func TestRequestClosed(t *testing.T) {
+	client, _ := pair(t, nil)
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Request(context.Background(), message.Message{Type: "silence"})
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	client.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("Request: %v, ожидалась ErrClosed", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("закрытие соединения не прервало запрос")
+	}
+}
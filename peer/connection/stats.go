@@ -0,0 +1,92 @@
+package connection
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// rttSmoothing - коэффициент сглаживания RTT (RFC 6298)
+	rttSmoothing = 0.125
+	// jitterSmoothing - коэффициент сглаживания разброса RTT (RFC 3550)
+	jitterSmoothing = 0.0625
+)
+
+// Stats - показатели качества соединения по результатам пингов.
+type Stats struct {
+	RTT      time.Duration // Время приёма-передачи последнего пинга
+	SRTT     time.Duration // Сглаженное время приёма-передачи
+	Jitter   time.Duration // Сглаженный разброс времени приёма-передачи
+	Pings    int           // Пингов с известным исходом (ответ или таймаут)
+	Pongs    int           // Получено ответов
+	Missed   int           // Пропущено ответов подряд
+	LastPong time.Time     // Время последнего ответа
+}
+
+// Loss возвращает долю пингов, оставшихся без ответа, от 0 до 1.
+func (s Stats) Loss() float64 {
+	if s.Pings == 0 {
+		return 0
+	}
+	return float64(s.Pings-s.Pongs) / float64(s.Pings)
+}
+
+// Better сообщает, лучше ли соединение с показателями s, чем с показателями o:
+// меньше пропусков подряд, затем меньше потерь, затем меньше SRTT.
+// Соединение без измерений считается хуже измеренного.
+func (s Stats) Better(o Stats) bool {
+	if s.Missed != o.Missed {
+		return s.Missed < o.Missed
+	}
+	if (s.Pongs == 0) != (o.Pongs == 0) {
+		return s.Pongs > 0
+	}
+	if sl, ol := s.Loss(), o.Loss(); sl != ol {
+		return sl < ol
+	}
+	return s.SRTT < o.SRTT
+}
+
+// statsTracker накапливает показатели качества соединения.
+type statsTracker struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+// pong учитывает ответ, полученный через rtt после пинга.
+func (t *statsTracker) pong(rtt time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &t.stats
+	if s.Pongs == 0 {
+		s.SRTT = rtt
+	} else {
+		diff := rtt - s.RTT
+		if diff < 0 {
+			diff = -diff
+		}
+		s.Jitter += time.Duration(jitterSmoothing * float64(diff-s.Jitter))
+		s.SRTT += time.Duration(rttSmoothing * float64(rtt-s.SRTT))
+	}
+	s.RTT = rtt
+	s.Pings++
+	s.Pongs++
+	s.Missed = 0
+	s.LastPong = time.Now()
+}
+
+// miss учитывает пинг без ответа и возвращает число пропусков подряд.
+func (t *statsTracker) miss() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Pings++
+	t.stats.Missed++
+	return t.stats.Missed
+}
+
+// snapshot возвращает копию показателей.
+func (t *statsTracker) snapshot() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
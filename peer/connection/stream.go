@@ -0,0 +1,319 @@
+package connection
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+	"github.com/hashicorp/yamux"
+)
+
+// Виды логических потоков внутри соединения.
+// Каждая сторона пишет только в открытые ею потоки и читает принятые.
+const (
+	StreamControl  = "control"  // Служебные сообщения: info, heartbeat, управление передачами
+	StreamChat     = "chat"     // Текстовые сообщения
+	StreamTransfer = "transfer" // Данные одной передачи файла
+)
+
+const (
+	// ReadDeadline - таймаут ожидания очередного сообщения в потоке
+	ReadDeadline = 20 * time.Minute
+	// MaxMessageSize - максимальный размер одного сообщения (блоки файлов больше 64 КБ)
+	MaxMessageSize = 1 << 20
+	// streamWindowSize - окно управления потоком для каждого логического потока
+	streamWindowSize = 256 * 1024
+	// headerDeadline - время ожидания заголовка нового потока
+	headerDeadline = 10 * time.Second
+	// maxHeaderSize - максимальный размер заголовка потока
+	maxHeaderSize = 4096
+)
+
+// ErrBadMessage - сообщение в потоке не удалось разобрать; поток остаётся пригодным для чтения
+var ErrBadMessage = errors.New("connection: некорректное сообщение")
+
+// Stream - логический поток сообщений внутри соединения.
+// Потоки независимы: запись большого блока в один поток не задерживает другие.
+type Stream struct {
+	Kind     string // Вид потока (StreamControl, StreamChat, StreamTransfer)
+	Encoding string // Алгоритм сжатия данных потока ("" - без сжатия)
+
+	conn    *Connection
+	raw     net.Conn
+	in      *wireReader // Чтение из сети: считает принятые байты до распаковки
+	charged int64       // Принятые байты, уже учтённые ограничителем или пропущенные
+	scanner *bufio.Scanner
+	writeMu sync.Mutex  // Сообщения записываются в поток целиком, по одному
+	out     *wireWriter // Запись в сеть: считает отправленные байты после сжатия
+	w       io.Writer   // Запись данных потока (out или сжимающая обёртка)
+	zw      compressor  // Сжимающая обёртка (nil - без сжатия)
+}
+
+// wireReader считает байты, прочитанные из сети.
+type wireReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *wireReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// wireWriter считает байты, записанные в сеть.
+type wireWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *wireWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// session - мультиплексор логических потоков соединения: yamux.Session
+// или соединение транспорта со встроенными потоками.
+type session interface {
+	transport.Multiplexer
+	Close() error
+}
+
+// openSession поднимает мультиплексор и открывает исходящие управляющий поток и поток чата.
+// Если соединение транспорта само поддерживает потоки (transport.Multiplexer), yamux не используется.
+func (c *Connection) openSession(outbound bool) error {
+	if m, ok := c.Conn.(transport.Multiplexer); ok {
+		c.session = &countingSession{Multiplexer: m, close: c.Conn.Close, t: &c.traffic}
+		return c.openStreams()
+	}
+
+	cfg := yamux.DefaultConfig()
+	cfg.EnableKeepAlive = false // Активность проверяет heartbeat
+	cfg.MaxStreamWindowSize = streamWindowSize
+	cfg.LogOutput = nil
+	cfg.Logger = slog.NewLogLogger(c.log.Handler(), slog.LevelWarn) // Сообщения мультиплексора - в журнал соединения
+
+	var (
+		ys  *yamux.Session
+		err error
+	)
+	conn := &countingConn{Conn: c.Conn, t: &c.traffic}
+	if outbound {
+		ys, err = yamux.Client(conn, cfg)
+	} else {
+		ys, err = yamux.Server(conn, cfg)
+	}
+	if err != nil {
+		return err
+	}
+	c.session = ys
+	return c.openStreams()
+}
+
+// openStreams открывает исходящие управляющий поток и поток чата.
+func (c *Connection) openStreams() error {
+	var err error
+	if c.control, err = c.OpenStream(StreamControl); err != nil {
+		return err
+	}
+	if c.chatStream, err = c.OpenStream(StreamChat); err != nil {
+		return err
+	}
+	return nil
+}
+
+// OpenStream открывает новый исходящий поток вида kind.
+func (c *Connection) OpenStream(kind string) (*Stream, error) {
+	return c.openStream(kind, "")
+}
+
+// openStream открывает исходящий поток и отправляет его заголовок.
+// Данные после заголовка сжимаются алгоритмом encoding, если он задан.
+func (c *Connection) openStream(kind, encoding string) (*Stream, error) {
+	if c.session == nil {
+		return nil, errors.New("connection: мультиплексор не запущен")
+	}
+	raw, err := c.session.Open()
+	if err != nil {
+		return nil, fmt.Errorf("connection: не удалось открыть поток: %w", err)
+	}
+	s := newStream(c, raw, kind)
+	if err := s.Send(message.Message{Type: "stream", Content: kind, Encoding: encoding}); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	if encoding != "" {
+		zw, ok := newCompressor(encoding, s.out)
+		if !ok {
+			raw.Close()
+			return nil, fmt.Errorf("connection: неизвестный алгоритм сжатия %q", encoding)
+		}
+		s.Encoding, s.w, s.zw = encoding, zw, zw
+	}
+	return s, nil
+}
+
+// AcceptStream ожидает новый входящий поток и читает его заголовок.
+func (c *Connection) AcceptStream() (*Stream, error) {
+	if c.session == nil {
+		return nil, errors.New("connection: мультиплексор не запущен")
+	}
+	for {
+		raw, err := c.session.Accept()
+		if err != nil {
+			return nil, err
+		}
+		raw.SetReadDeadline(time.Now().Add(headerDeadline))
+		header, err := readHeader(raw)
+		if err != nil || header.Type != "stream" || header.Content == "" {
+			c.Logger().Warn("поток без корректного заголовка отклонён")
+			raw.Close()
+			continue
+		}
+		s := newStream(c, raw, header.Content)
+		if header.Encoding != "" {
+			r, ok := newDecompressor(header.Encoding, s.in)
+			if !ok {
+				c.Logger().Warn("поток с неподдерживаемым сжатием отклонён", "stream", header.Content, "encoding", header.Encoding)
+				raw.Close()
+				continue
+			}
+			s.Encoding = header.Encoding
+			s.setReader(r)
+		}
+		return s, nil
+	}
+}
+
+// readHeader читает заголовок потока побайтно, чтобы не захватить следующие за ним
+// (возможно, сжатые) данные.
+func readHeader(raw net.Conn) (*message.Message, error) {
+	line := make([]byte, 0, 64)
+	b := make([]byte, 1)
+	for len(line) < maxHeaderSize {
+		if _, err := io.ReadFull(raw, b); err != nil {
+			return nil, err
+		}
+		if b[0] == '\n' {
+			return message.MessageFromBytes(line)
+		}
+		line = append(line, b[0])
+	}
+	return nil, fmt.Errorf("%w: слишком длинный заголовок потока", ErrBadMessage)
+}
+
+func newStream(c *Connection, raw net.Conn, kind string) *Stream {
+	s := &Stream{
+		Kind: kind,
+		conn: c,
+		raw:  raw,
+		in:   &wireReader{r: raw},
+		out:  &wireWriter{w: raw},
+	}
+	s.w = s.out
+	s.setReader(s.in)
+	return s
+}
+
+// setReader задаёт источник сообщений потока (in или распаковывающая обёртка над ним).
+func (s *Stream) setReader(r io.Reader) {
+	s.scanner = bufio.NewScanner(r)
+	s.scanner.Buffer(make([]byte, 0, 64*1024), MaxMessageSize)
+}
+
+// Send отправляет сообщение в поток.
+// Массовые сообщения учитываются ограничителем скорости соединения по числу байт,
+// ушедших в сеть (после сжатия): следующая отправка ждёт, пока не наберётся лимит.
+func (s *Stream) Send(msg message.Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return fmt.Errorf("не удалось преобразовать сообщение в строку: %w", err)
+	}
+	s.writeMu.Lock()
+	before := s.out.n
+	if _, err = s.w.Write([]byte(data + "\n")); err != nil {
+		s.writeMu.Unlock()
+		return err
+	}
+	if s.zw != nil {
+		if err := s.zw.Flush(); err != nil { // Сообщение должно дойти сразу, а не по заполнении буфера
+			s.writeMu.Unlock()
+			return err
+		}
+	}
+	wire := s.out.n - before
+	s.writeMu.Unlock()
+
+	s.conn.traffic.message(msg.Type, false)
+	if t := s.conn.throttle.Load(); msg.Bulk() && t != nil {
+		return t.WaitUpload(s.conn.ctx, s.conn.ThrottleKey(), int(wire))
+	}
+	return nil
+}
+
+// Receive читает очередное сообщение из потока. Вызывается из одной горутины.
+// Возвращает ErrBadMessage для неразборчивых сообщений и io.EOF при закрытии потока.
+// Массовые сообщения учитываются ограничителем скорости приёма по числу байт,
+// прочитанных из сети (до распаковки).
+func (s *Stream) Receive() (*message.Message, error) {
+	// Устанавливаем дедлайн для предотвращения бесконечного ожидания
+	s.raw.SetReadDeadline(time.Now().Add(ReadDeadline))
+
+	msg, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	s.conn.traffic.message(msg.Type, true)
+	// Чтение идёт блоками, поэтому на сообщение приходятся байты, прочитанные с прошлого вызова
+	wire := s.in.n - s.charged
+	s.charged = s.in.n
+	// Задерживая чтение, замедляем отправителя
+	if t := s.conn.throttle.Load(); msg.Bulk() && t != nil && wire > 0 {
+		if err := t.WaitDownload(s.conn.ctx, s.conn.ThrottleKey(), int(wire)); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// read читает и разбирает одну строку потока.
+func (s *Stream) read() (*message.Message, error) {
+	if !s.scanner.Scan() {
+		err := s.scanner.Err()
+		if err == nil || isClosedErr(err) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	msg, err := message.MessageFromBytes(s.scanner.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadMessage, err)
+	}
+	return msg, nil
+}
+
+// Close закрывает поток. Удалённая сторона получит io.EOF после всех отправленных данных.
+func (s *Stream) Close() error {
+	if s.zw != nil {
+		s.writeMu.Lock()
+		s.zw.Close()
+		s.writeMu.Unlock()
+	}
+	return s.raw.Close()
+}
+
+// isClosedErr сообщает, что ошибка вызвана штатным закрытием потока или соединения.
+func isClosedErr(err error) bool {
+	return errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, yamux.ErrConnectionReset) ||
+		errors.Is(err, yamux.ErrSessionShutdown) ||
+		errors.Is(err, yamux.ErrStreamClosed)
+}
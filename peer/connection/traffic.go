@@ -0,0 +1,141 @@
+package connection
+
+import (
+	"maps"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// Traffic - объём данных и число сообщений по типам, переданных через соединение.
+type Traffic struct {
+	BytesIn     uint64            // Принято байт (с учётом служебных данных мультиплексора)
+	BytesOut    uint64            // Отправлено байт
+	MessagesIn  map[string]uint64 // Принято сообщений по типам
+	MessagesOut map[string]uint64 // Отправлено сообщений по типам
+}
+
+// Add прибавляет к t показатели o.
+func (t *Traffic) Add(o Traffic) {
+	t.BytesIn += o.BytesIn
+	t.BytesOut += o.BytesOut
+	if t.MessagesIn == nil {
+		t.MessagesIn = make(map[string]uint64)
+	}
+	if t.MessagesOut == nil {
+		t.MessagesOut = make(map[string]uint64)
+	}
+	for typ, n := range o.MessagesIn {
+		t.MessagesIn[typ] += n
+	}
+	for typ, n := range o.MessagesOut {
+		t.MessagesOut[typ] += n
+	}
+}
+
+// OtherMessageType - тип, под которым учитываются сообщения неизвестных типов (см. SetMessageTypes)
+const OtherMessageType = "other"
+
+// trafficCounter считает трафик соединения.
+type trafficCounter struct {
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
+
+	known atomic.Pointer[func(string) bool] // Учитываемые по отдельности типы (nil - все)
+
+	mu  sync.Mutex
+	in  map[string]uint64
+	out map[string]uint64
+}
+
+// message учитывает сообщение типа typ; in - входящее.
+func (t *trafficCounter) message(typ string, in bool) {
+	if known := t.known.Load(); known != nil && !(*known)(typ) {
+		typ = OtherMessageType
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.in == nil {
+		t.in, t.out = make(map[string]uint64), make(map[string]uint64)
+	}
+	if in {
+		t.in[typ]++
+	} else {
+		t.out[typ]++
+	}
+}
+
+// snapshot возвращает копию показателей.
+func (t *trafficCounter) snapshot() Traffic {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Traffic{
+		BytesIn:     t.bytesIn.Load(),
+		BytesOut:    t.bytesOut.Load(),
+		MessagesIn:  maps.Clone(t.in),
+		MessagesOut: maps.Clone(t.out),
+	}
+}
+
+// SetMessageTypes задаёт типы сообщений, которые учитываются в Traffic по отдельности.
+// Сообщения остальных типов учитываются как OtherMessageType, поэтому удалённый узел
+// не может неограниченно умножать счётчики. nil - учитывать все типы.
+func (c *Connection) SetMessageTypes(known func(typ string) bool) {
+	if known == nil {
+		c.traffic.known.Store(nil)
+		return
+	}
+	c.traffic.known.Store(&known)
+}
+
+// Traffic возвращает объём трафика соединения.
+func (c *Connection) Traffic() Traffic {
+	return c.traffic.snapshot()
+}
+
+// countingConn учитывает байты, проходящие через соединение.
+type countingConn struct {
+	net.Conn
+	t *trafficCounter
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.t.bytesIn.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.t.bytesOut.Add(uint64(n))
+	return n, err
+}
+
+// countingSession учитывает байты потоков соединения со встроенными потоками.
+type countingSession struct {
+	transport.Multiplexer
+	close func() error // Закрывает соединение транспорта
+	t     *trafficCounter
+}
+
+func (s *countingSession) Open() (net.Conn, error) {
+	conn, err := s.Multiplexer.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, t: s.t}, nil
+}
+
+func (s *countingSession) Accept() (net.Conn, error) {
+	conn, err := s.Multiplexer.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, t: s.t}, nil
+}
+
+func (s *countingSession) Close() error {
+	return s.close()
+}
@@ -0,0 +1,39 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+)
+
+func TestTrafficMessageTypes(t *testing.T) {
+	received := make(chan struct{}, 8)
+	client, server := pair(t, func(_ *Connection, msg *message.Message) {
+		if msg.Type != "info" {
+			received <- struct{}{}
+		}
+	})
+	server.SetMessageTypes(func(typ string) bool { return typ == "known" || typ == "info" })
+
+	for _, typ := range []string{"known", "junk-1", "junk-2", "known"} {
+		if err := client.Send(message.Message{Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case <-received:
+		case <-time.After(waitTimeout):
+			t.Fatal("сообщения не доставлены")
+		}
+	}
+
+	in := server.Traffic().MessagesIn
+	if in["known"] != 2 || in[OtherMessageType] != 2 || in["junk-1"] != 0 {
+		t.Fatalf("счётчики входящих сообщений %v", in)
+	}
+	if server.Traffic().BytesIn == 0 || client.Traffic().BytesOut == 0 {
+		t.Fatal("байты не учтены")
+	}
+}
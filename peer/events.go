@@ -0,0 +1,114 @@
+package peer
+
+import (
+	"slices"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+// EventType - тип события узла.
+type EventType int
+
+const (
+	EventPeerConnected    EventType = iota // Соединение с узлом установлено и прошло рукопожатие
+	EventPeerDisconnected                  // Соединение с узлом закрыто
+	EventTextReceived                      // Получено текстовое сообщение
+	EventFileOffered                       // Узел предложил файл, приём поставлен в очередь
+)
+
+// String возвращает название типа события.
+func (t EventType) String() string {
+	switch t {
+	case EventPeerConnected:
+		return "peer-connected"
+	case EventPeerDisconnected:
+		return "peer-disconnected"
+	case EventTextReceived:
+		return "text-received"
+	case EventFileOffered:
+		return "file-offered"
+	}
+	return "unknown"
+}
+
+// Event - событие узла.
+type Event struct {
+	Type    EventType              // Тип события
+	Time    time.Time              // Время события
+	Conn    *connection.Connection // Соединение, к которому относится событие
+	Message *message.Message       // Полученное сообщение (для EventTextReceived и EventFileOffered)
+	Task    *transfer.Task         // Задача приёма файла (для EventFileOffered)
+}
+
+// EventFunc получает события узла.
+// Вызывается синхронно из горутины соединения, поэтому не должна блокироваться надолго.
+type EventFunc func(Event)
+
+// EventChan возвращает EventFunc, отправляющую события в канал ch.
+// События доставляются всегда: пока канал заполнен, обработка сообщений соединения приостанавливается.
+func EventChan(ch chan<- Event) EventFunc {
+	return func(e Event) {
+		ch <- e
+	}
+}
+
+// subscriber - подписчик на события узла.
+type subscriber struct {
+	fn    EventFunc
+	types []EventType // Пустой список - все события
+}
+
+// Subscribe подписывает fn на события указанных типов (без типов - на все события).
+// Возвращает функцию отмены подписки.
+func (p *Peer) Subscribe(fn EventFunc, types ...EventType) (unsubscribe func()) {
+	p.eventsMu.Lock()
+	defer p.eventsMu.Unlock()
+	p.nextSub++
+	id := p.nextSub
+	p.subscribers[id] = subscriber{fn: fn, types: types}
+	return func() {
+		p.eventsMu.Lock()
+		delete(p.subscribers, id)
+		p.eventsMu.Unlock()
+	}
+}
+
+// emit передаёт событие подписчикам.
+func (p *Peer) emit(e Event) {
+	e.Time = time.Now()
+
+	p.eventsMu.RLock()
+	subs := make([]subscriber, 0, len(p.subscribers))
+	for _, s := range p.subscribers {
+		subs = append(subs, s)
+	}
+	p.eventsMu.RUnlock()
+
+	for _, s := range subs {
+		if len(s.types) == 0 || slices.Contains(s.types, e.Type) {
+			s.fn(e)
+		}
+	}
+}
+
+// announceConnected сообщает о подключении узла. Если соединение успело закрыться,
+// сразу сообщает и об отключении, чтобы каждое подключение имело пару.
+func (p *Peer) announceConnected(conn *connection.Connection) {
+	p.announced.Store(conn, struct{}{})
+	p.emit(Event{Type: EventPeerConnected, Conn: conn})
+	select {
+	case <-conn.Done():
+		p.announceDisconnected(conn)
+	default:
+	}
+}
+
+// announceDisconnected сообщает об отключении узла, если о его подключении уже сообщалось.
+func (p *Peer) announceDisconnected(conn *connection.Connection) {
+	if _, ok := p.announced.LoadAndDelete(conn); ok {
+		p.emit(Event{Type: EventPeerDisconnected, Conn: conn})
+	}
+}
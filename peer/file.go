@@ -0,0 +1,515 @@
+package peer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+const (
+	// DefaultAcceptTimeout - время ожидания согласия получателя принять файл по умолчанию
+	DefaultAcceptTimeout = 5 * time.Minute
+	// maxTransferIDLen - максимальная длина идентификатора передачи, выбранного отправителем
+	maxTransferIDLen = 64
+)
+
+var (
+	errConnClosed     = errors.New("соединение закрыто")
+	errDownloadClosed = errors.New("приём файла уже завершён")
+	errNotAccepted    = errors.New("получатель не принял файл")
+	errBadTransferID  = errors.New("некорректный идентификатор передачи")
+)
+
+// SetDownloadDir задаёт каталог, в который сохраняются принятые файлы.
+// Пока каталог не задан, входящие файлы отклоняются.
+func (p *Peer) SetDownloadDir(dir string) error {
+	receiver, err := transfer.NewReceiver(dir)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.downloads = receiver
+	p.mu.Unlock()
+	return nil
+}
+
+// DownloadDir возвращает каталог принятых файлов ("" - файлы не принимаются).
+func (p *Peer) DownloadDir() string {
+	if r := p.receiver(); r != nil {
+		return r.Dir
+	}
+	return ""
+}
+
+// receiver возвращает приёмник входящих файлов.
+func (p *Peer) receiver() *transfer.Receiver {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.downloads
+}
+
+// SetAcceptTimeout задаёт, сколько отправка ждёт согласия получателя принять файл,
+// прежде чем завершиться ошибкой и освободить слот. Значение <= 0 снимает ограничение.
+func (p *Peer) SetAcceptTimeout(d time.Duration) {
+	p.mu.Lock()
+	p.acceptTimeout = d
+	p.mu.Unlock()
+}
+
+// OnProgress задаёт обработчик событий о ходе передачи файлов.
+func (p *Peer) OnProgress(fn transfer.ProgressFunc) {
+	p.mu.Lock()
+	p.progress = fn
+	p.mu.Unlock()
+}
+
+// notifyProgress передаёт событие о ходе передачи обработчику, если он задан.
+func (p *Peer) notifyProgress(pr transfer.Progress) {
+	p.mu.RLock()
+	fn := p.progress
+	p.mu.RUnlock()
+	if fn != nil {
+		fn(pr)
+	}
+}
+
+// outgoingFile - файл, отправляемый удалённому узлу.
+type outgoingFile struct {
+	upload         *transfer.Upload
+	conn           *connection.Connection
+	task           *transfer.Task
+	accepted       chan struct{} // Закрывается, когда получатель готов принимать данные
+	acceptOnce     sync.Once
+	offered        atomic.Bool // Предложение уже отправлено получателю
+	remoteCanceled atomic.Bool // Передача отменена удалённым узлом
+}
+
+// incomingKey - ключ принимаемого файла: идентификаторы выбирают отправители,
+// поэтому они уникальны только в пределах соединения.
+type incomingKey struct {
+	conn *connection.Connection
+	id   string // Идентификатор передачи у отправителя
+}
+
+// incomingFile - файл, принимаемый от удалённого узла.
+type incomingFile struct {
+	conn           *connection.Connection
+	remoteID       string // Идентификатор передачи у отправителя
+	task           *transfer.Task
+	done           chan error  // Результат приёма после сообщения "file-end"
+	remoteCanceled atomic.Bool // Передача отменена удалённым узлом
+
+	mu       sync.Mutex
+	download *transfer.Download
+	finished bool
+}
+
+// write записывает блок данных, если приём ещё не завершён.
+func (f *incomingFile) write(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.finished {
+		return errDownloadClosed
+	}
+	_, err := f.download.Write(data)
+	return err
+}
+
+// commit проверяет и сохраняет принятый файл.
+func (f *incomingFile) commit() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.finished {
+		return "", errDownloadClosed
+	}
+	f.finished = true
+	return f.download.Commit()
+}
+
+// abort прерывает приём, если он ещё не завершён.
+func (f *incomingFile) abort() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.finished {
+		f.finished = true
+		f.download.Abort()
+	}
+}
+
+// complete передаёт результат приёма ожидающей задаче. Повторные результаты отбрасываются.
+func (f *incomingFile) complete(err error) {
+	select {
+	case f.done <- err:
+	default:
+	}
+}
+
+// SendFileToPeers ставит в очередь отправку файла всем подключённым узлам.
+func (p *Peer) SendFileToPeers(path string, priority int) {
+	p.Connections.Range(func(_, value any) bool {
+		conn := value.(*connection.Connection)
+		if _, err := p.SendFile(conn, path, priority); err != nil {
+			conn.Logger().Warn("не удалось отправить файл", "path", path, "err", err)
+		}
+		return true
+	})
+}
+
+// SendFile ставит в очередь отправку файла конкретному узлу.
+// Возвращённой задачей можно приостановить, возобновить или отменить передачу.
+func (p *Peer) SendFile(conn *connection.Connection, path string, priority int) (*transfer.Task, error) {
+	return p.sendFile(conn, path, priority, "")
+}
+
+// sendFile ставит в очередь отправку файла под идентификатором id ("" - новый идентификатор).
+func (p *Peer) sendFile(conn *connection.Connection, path string, priority int, id string) (*transfer.Task, error) {
+	upload, err := transfer.OpenUpload(path)
+	if err != nil {
+		return nil, err
+	}
+	if id != "" {
+		upload.ID = id
+	}
+
+	f := &outgoingFile{
+		upload:   upload,
+		conn:     conn,
+		accepted: make(chan struct{}),
+	}
+	f.task = transfer.NewTask(upload.ID, upload.Filename, conn.Addr(), transfer.DirectionUpload, upload.Size, priority,
+		func(ctx context.Context, t *transfer.Task) error {
+			return p.runUpload(ctx, f)
+		})
+	f.task.OnCancel(func() {
+		if f.offered.Load() && !f.remoteCanceled.Load() {
+			p.sendFileControl(conn, "file-cancel", upload.ID)
+		}
+	})
+
+	p.outgoing.Store(upload.ID, f)
+	if err := p.Transfers.Enqueue(f.task); err != nil {
+		p.outgoing.Delete(upload.ID)
+		upload.Close()
+		return nil, err
+	}
+	go func() {
+		<-f.task.Done()
+		p.outgoing.Delete(upload.ID)
+		upload.Close()
+		// Получатель, согласившийся на файл, иначе ждал бы данных до закрытия соединения
+		err := f.task.Err()
+		if err != nil && !errors.Is(err, context.Canceled) && f.offered.Load() && !f.remoteCanceled.Load() && !conn.IsClosed() {
+			p.sendFileControl(conn, "file-cancel", upload.ID)
+		}
+	}()
+	return f.task, nil
+}
+
+// runUpload отправляет предложение, дожидается согласия получателя и передаёт блоки файла.
+func (p *Peer) runUpload(ctx context.Context, f *outgoingFile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	offer := message.Message{
+		Type:     "file",
+		Sender:   p.Username,
+		Filename: f.upload.Filename,
+		ID:       f.upload.ID,
+		Size:     f.upload.Size,
+		Hash:     f.upload.Hash,
+	}
+	if err := f.conn.Send(offer); err != nil {
+		return err
+	}
+	f.offered.Store(true)
+
+	// Получатель может держать файл в своей очереди; без ограничения ожидания
+	// отправка, которой так и не ответили, занимала бы слот бесконечно
+	p.mu.RLock()
+	timeout := p.acceptTimeout
+	p.mu.RUnlock()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-f.accepted:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-f.conn.Done():
+		return errConnClosed
+	case <-expired:
+		return fmt.Errorf("%w за %s", errNotAccepted, timeout)
+	}
+
+	// Данные передаются в отдельном потоке, чтобы не задерживать чат и heartbeat.
+	// Уже сжатые файлы (архивы, медиа) повторно не сжимаются.
+	open := f.conn.OpenStream
+	if f.upload.Compressible {
+		open = f.conn.OpenCompressedStream
+	}
+	stream, err := open(connection.StreamTransfer)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	buf := make([]byte, transfer.ChunkSize)
+	for {
+		if err := f.task.Wait(ctx); err != nil {
+			return err
+		}
+		n, err := f.upload.Read(buf)
+		if n > 0 {
+			chunk := message.Message{
+				Type:    "file-chunk",
+				Sender:  p.Username,
+				ID:      f.upload.ID,
+				Content: base64.StdEncoding.EncodeToString(buf[:n]),
+			}
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			f.task.Add(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать файл: %w", err)
+		}
+	}
+
+	return stream.Send(message.Message{
+		Type:   "file-end",
+		Sender: p.Username,
+		ID:     f.upload.ID,
+	})
+}
+
+// runDownload сообщает отправителю о готовности принимать данные и ждёт завершения приёма.
+// Сами блоки записываются в цикле чтения соединения.
+func (p *Peer) runDownload(ctx context.Context, f *incomingFile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := p.sendFileControl(f.conn, "file-accept", f.remoteID); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-f.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-f.conn.Done():
+		return errConnClosed
+	}
+}
+
+// sendFileControl отправляет узлу управляющее сообщение передачи файла с идентификатором id.
+func (p *Peer) sendFileControl(conn *connection.Connection, msgType, id string) error {
+	err := conn.Send(message.Message{
+		Type:   msgType,
+		Sender: p.Username,
+		ID:     id,
+	})
+	if err != nil {
+		conn.Logger().Warn("не удалось отправить управляющее сообщение передачи", "msg_type", msgType, "transfer_id", id, "err", err)
+	}
+	return err
+}
+
+// lookupIncoming возвращает файл, который conn отправляет под идентификатором id.
+func (p *Peer) lookupIncoming(conn *connection.Connection, id string) (*incomingFile, bool) {
+	v, ok := p.incoming.Load(incomingKey{conn, id})
+	if !ok {
+		return nil, false
+	}
+	return v.(*incomingFile), true
+}
+
+// downloadID возвращает локальный идентификатор приёма файла, который conn отправляет
+// под идентификатором remoteID. Локальный идентификатор не совпадает с идентификаторами
+// отправок и приёмов от других узлов в общем менеджере передач.
+func downloadID(conn *connection.Connection, remoteID string) string {
+	sum := sha256.Sum256([]byte(conn.PeerID() + "\x00" + conn.Addr() + "\x00" + remoteID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// validTransferID проверяет идентификатор передачи, выбранный удалённым узлом.
+func validTransferID(id string) bool {
+	if id == "" || len(id) > maxTransferIDLen {
+		return false
+	}
+	return strings.IndexFunc(id, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '_')
+	}) < 0
+}
+
+// lookupOutgoing возвращает отправляемый файл с идентификатором id, если его принимает conn.
+func (p *Peer) lookupOutgoing(conn *connection.Connection, id string) (*outgoingFile, bool) {
+	v, ok := p.outgoing.Load(id)
+	if !ok || v.(*outgoingFile).conn != conn {
+		return nil, false
+	}
+	return v.(*outgoingFile), true
+}
+
+// handleFileOffer обрабатывает предложение файла: создаёт загрузку и ставит её в очередь.
+// Отклонённое предложение отменяется сообщением "file-cancel", чтобы отправитель не ждал согласия.
+func (p *Peer) handleFileOffer(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	reject := func(reason string, err error) {
+		conn.Logger().Warn(reason, "msg_type", msg.Type, "transfer_id", msg.ID, "filename", msg.Filename, "err", err)
+		p.sendFileControl(conn, "file-cancel", msg.ID)
+	}
+	if !validTransferID(msg.ID) {
+		reject("входящий файл отклонён", errBadTransferID)
+		return
+	}
+	receiver := p.receiver()
+	if receiver == nil {
+		reject("входящий файл отклонён: каталог загрузок не задан", nil)
+		return
+	}
+	key := incomingKey{conn, msg.ID}
+	if existing, ok := p.lookupIncoming(conn, msg.ID); ok {
+		// Отправитель повторно использовал идентификатор: отменяется и прежний приём,
+		// так как его "file-cancel" отменит у отправителя обе передачи
+		reject("повторное предложение файла отклонено", transfer.ErrDuplicateTask)
+		existing.remoteCanceled.Store(true)
+		p.Transfers.Cancel(existing.task.ID)
+		return
+	}
+	d, err := receiver.Begin(transfer.Offer{
+		ID:       msg.ID,
+		Filename: msg.Filename,
+		Size:     msg.Size,
+		Hash:     msg.Hash,
+	})
+	if err != nil {
+		reject("входящий файл отклонён", err)
+		return
+	}
+
+	f := &incomingFile{
+		conn:     conn,
+		remoteID: msg.ID,
+		download: d,
+		done:     make(chan error, 1),
+	}
+	f.task = transfer.NewTask(downloadID(conn, msg.ID), d.Name, conn.Addr(), transfer.DirectionDownload, d.Size, transfer.PriorityNormal,
+		func(ctx context.Context, t *transfer.Task) error {
+			return p.runDownload(ctx, f)
+		})
+	f.task.OnPause(func(paused bool) {
+		if paused {
+			p.sendFileControl(conn, "file-pause", f.remoteID)
+		} else {
+			p.sendFileControl(conn, "file-resume", f.remoteID)
+		}
+	})
+	f.task.OnCancel(func() {
+		if !f.remoteCanceled.Load() {
+			p.sendFileControl(conn, "file-cancel", f.remoteID)
+		}
+	})
+
+	p.incoming.Store(key, f)
+	if err := p.Transfers.Enqueue(f.task); err != nil {
+		p.incoming.Delete(key)
+		d.Abort()
+		reject("входящий файл отклонён", err)
+		return
+	}
+	go func() {
+		<-f.task.Done()
+		p.incoming.Delete(key)
+		f.abort()
+	}()
+	conn.Logger().Info("входящий файл поставлен в очередь", "transfer_id", f.task.ID, "filename", d.Name, "size", d.Size)
+	p.emit(Event{Type: EventFileOffered, Conn: conn, Message: msg, Task: f.task})
+}
+
+// handleFileChunk записывает полученный блок данных принимаемого файла.
+func (p *Peer) handleFileChunk(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	f, ok := p.lookupIncoming(conn, msg.ID)
+	if !ok {
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(msg.Content)
+	if err == nil {
+		err = f.write(data)
+	}
+	if err != nil {
+		conn.Logger().Warn("приём файла прерван", "msg_type", msg.Type, "transfer_id", f.task.ID, "filename", f.task.Filename, "err", err)
+		f.abort()
+		f.complete(err)
+		p.sendFileControl(conn, "file-cancel", msg.ID)
+		return
+	}
+	f.task.Add(len(data))
+}
+
+// handleFileEnd проверяет и сохраняет полностью принятый файл.
+func (p *Peer) handleFileEnd(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	f, ok := p.lookupIncoming(conn, msg.ID)
+	if !ok {
+		return
+	}
+	path, err := f.commit()
+	if err == nil {
+		f.task.SetPath(path)
+	}
+	f.complete(err)
+	if err != nil {
+		conn.Logger().Error("файл не сохранён", "transfer_id", f.task.ID, "filename", f.task.Filename, "err", err)
+		return
+	}
+	conn.Logger().Info("файл сохранён", "transfer_id", f.task.ID, "path", path)
+}
+
+// handleFileAccept разрешает отправку данных файла, который получатель готов принять.
+func (p *Peer) handleFileAccept(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	if f, ok := p.lookupOutgoing(conn, msg.ID); ok {
+		f.acceptOnce.Do(func() { close(f.accepted) })
+	}
+}
+
+// handleFilePause приостанавливает или возобновляет отправку файла по просьбе получателя.
+func (p *Peer) handleFilePause(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	if f, ok := p.lookupOutgoing(conn, msg.ID); ok {
+		if msg.Type == "file-pause" {
+			f.task.Pause()
+		} else {
+			f.task.Resume()
+		}
+	}
+}
+
+// handleFileCancel отменяет передачу, отменённую удалённым узлом.
+func (p *Peer) handleFileCancel(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	if f, ok := p.lookupIncoming(conn, msg.ID); ok {
+		f.remoteCanceled.Store(true)
+		p.Transfers.Cancel(f.task.ID)
+	}
+	if f, ok := p.lookupOutgoing(conn, msg.ID); ok {
+		f.remoteCanceled.Store(true)
+		p.Transfers.Cancel(msg.ID)
+	}
+}
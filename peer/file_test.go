@@ -0,0 +1,225 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+// writeFile создаёт во временном каталоге файл name с содержимым data.
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// waitTask ждёт завершения передачи.
+func waitTask(t *testing.T, task *transfer.Task) {
+	t.Helper()
+	select {
+	case <-task.Done():
+	case <-time.After(peertest.WaitTimeout):
+		t.Fatalf("передача %s не завершилась", task.ID)
+	}
+}
+
+// downloaded ждёт завершения приёма id и возвращает содержимое сохранённого файла.
+func downloaded(t *testing.T, p *Peer, id string) string {
+	t.Helper()
+	var pr transfer.Progress
+	peertest.Eventually(t, "завершение приёма "+id, func() bool {
+		for _, h := range p.Transfers.History() {
+			if h.ID == id {
+				pr = h
+				return true
+			}
+		}
+		return false
+	})
+	if pr.State != transfer.StateCompleted || pr.Direction != transfer.DirectionDownload {
+		t.Fatalf("приём %s: %s (%v)", id, pr.State, pr.Err)
+	}
+	if filepath.Dir(pr.Path) != p.DownloadDir() {
+		t.Fatalf("файл сохранён в %s вне каталога загрузок", pr.Path)
+	}
+	data, err := os.ReadFile(pr.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestValidTransferID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"3f2a9c", true},
+		{"Upload_1-a", true},
+		{strings.Repeat("a", maxTransferIDLen), true},
+		{"", false},
+		{strings.Repeat("a", maxTransferIDLen+1), false},
+		{"../x", false},
+		{"a b", false},
+		{"идентификатор", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := validTransferID(tt.id); got != tt.want {
+				t.Fatalf("validTransferID(%q) = %v", tt.id, got)
+			}
+		})
+	}
+}
+
+func TestSendFile(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	if err := a.SetDownloadDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	connect(t, a, b)
+
+	data := strings.Repeat("данные файла ", 10000) // Несколько блоков
+	task, err := b.SendFile(b.liveConnection(a.ID), writeFile(t, "report.txt", data), transfer.PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitTask(t, task)
+	if err := task.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := downloaded(t, a, downloadID(a.liveConnection(b.ID), task.ID)); got != data {
+		t.Fatalf("принято %d байт, отправлено %d", len(got), len(data))
+	}
+}
+
+func TestIncomingTransferIDs(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b, c := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b"), newTestPeer(t, mem, "c")
+	for _, p := range []*Peer{a, b} {
+		if err := p.SetDownloadDir(t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ab, ac := connect(t, a, b), connect(t, a, c)
+
+	// Отправители выбирают идентификаторы сами: b и c используют тот же, что и
+	// собственная отправка a, но приёмы у a не должны заменить друг друга или её
+	const id = "same"
+	var uploads []*transfer.Task
+	for _, tt := range []struct {
+		from *Peer
+		to   *connection.Connection
+		data string
+	}{
+		{b, b.liveConnection(a.ID), "от b"},
+		{c, c.liveConnection(a.ID), "от c"},
+		{a, ab, "от a"},
+	} {
+		task, err := tt.from.sendFile(tt.to, writeFile(t, "f.txt", tt.data), transfer.PriorityNormal, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploads = append(uploads, task)
+	}
+	for _, task := range uploads {
+		waitTask(t, task)
+		if err := task.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fromB, fromC := downloadID(ab, id), downloadID(ac, id)
+	if fromB == fromC || fromB == id || fromC == id {
+		t.Fatalf("идентификаторы приёмов не разделены: %s, %s", fromB, fromC)
+	}
+	if got := downloaded(t, a, fromB); got != "от b" {
+		t.Fatalf("от b принято %q", got)
+	}
+	if got := downloaded(t, a, fromC); got != "от c" {
+		t.Fatalf("от c принято %q", got)
+	}
+	if got := downloaded(t, b, downloadID(b.liveConnection(a.ID), id)); got != "от a" {
+		t.Fatalf("от a принято %q", got)
+	}
+}
+
+func TestRejectedOffer(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  bool   // Задан ли каталог загрузок получателя
+		id   string // Идентификатор передачи у отправителя
+	}{
+		{"каталог загрузок не задан", false, "ok"},
+		{"некорректный идентификатор", true, "bad/id"},
+		{"слишком длинный идентификатор", true, strings.Repeat("a", maxTransferIDLen+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := transport.NewMemory()
+			a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+			if tt.dir {
+				if err := a.SetDownloadDir(t.TempDir()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			connect(t, a, b)
+
+			task, err := b.sendFile(b.liveConnection(a.ID), writeFile(t, "f.txt", "data"), transfer.PriorityNormal, tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			waitTask(t, task)
+			if st := task.Progress().State; st != transfer.StateCanceled {
+				t.Fatalf("отправка в состоянии %s, ожидалась отмена получателем", st)
+			}
+			if dir := a.DownloadDir(); dir != "" {
+				if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+					t.Fatalf("в каталоге загрузок появились файлы: %v", entries)
+				}
+			}
+		})
+	}
+}
+
+func TestAcceptTimeout(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	offered := make(chan struct{}, 1)
+	a.Handle("file", func(context.Context, *connection.Connection, *message.Message) {
+		offered <- struct{}{} // Получатель молчит, не принимая и не отклоняя файл
+	})
+	b.SetAcceptTimeout(50 * time.Millisecond)
+	connect(t, a, b)
+
+	task, err := b.SendFile(b.liveConnection(a.ID), writeFile(t, "f.txt", "data"), transfer.PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitTask(t, task)
+	if !errors.Is(task.Err(), errNotAccepted) {
+		t.Fatalf("отправка завершилась с %v, ожидалась errNotAccepted", task.Err())
+	}
+	if st := b.Transfers.Stats(); st.Active != 0 || st.Failed[transfer.DirectionUpload] != 1 {
+		t.Fatalf("слот отправки не освобождён: %+v", st)
+	}
+	select {
+	case <-offered:
+	default:
+		t.Fatal("предложение файла не получено")
+	}
+}
@@ -0,0 +1,135 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+)
+
+// HandlerFunc обрабатывает сообщение, полученное от удалённого узла.
+// ctx отменяется при закрытии соединения. Вызывается из горутины чтения потока,
+// поэтому долгую работу следует выносить в отдельную горутину.
+type HandlerFunc func(ctx context.Context, conn *connection.Connection, msg *message.Message)
+
+// Middleware оборачивает обработчик сообщений, например для журналирования,
+// проверки прав или ограничения частоты. Чтобы отбросить сообщение, не вызывает next.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Handle регистрирует обработчик сообщений типа msgType, заменяя прежний.
+// Так можно добавить собственный протокол или переопределить встроенный обработчик.
+// nil удаляет обработчик, и сообщения этого типа передаются обработчику неизвестных типов.
+func (p *Peer) Handle(msgType string, h HandlerFunc) {
+	p.handlersMu.Lock()
+	defer p.handlersMu.Unlock()
+	if h == nil {
+		delete(p.handlers, msgType)
+		return
+	}
+	p.handlers[msgType] = h
+}
+
+// HandleUnknown задаёт обработчик сообщений, для типа которых нет обработчика.
+// По умолчанию такие сообщения записываются в журнал.
+func (p *Peer) HandleUnknown(h HandlerFunc) {
+	p.handlersMu.Lock()
+	defer p.handlersMu.Unlock()
+	if h == nil {
+		h = p.handleUnknown
+	}
+	p.unknown = h
+}
+
+// Use добавляет промежуточные обработчики всех входящих сообщений.
+// Первый добавленный обработчик вызывается первым.
+func (p *Peer) Use(mw ...Middleware) {
+	p.handlersMu.Lock()
+	defer p.handlersMu.Unlock()
+	p.middleware = append(p.middleware, mw...)
+}
+
+// dispatch передаёт сообщение зарегистрированному обработчику через цепочку Middleware.
+func (p *Peer) dispatch(conn *connection.Connection, msg *message.Message) {
+	p.handlersMu.RLock()
+	h, ok := p.handlers[msg.Type]
+	if !ok {
+		h = p.unknown
+	}
+	for i := len(p.middleware) - 1; i >= 0; i-- {
+		h = p.middleware[i](h)
+	}
+	p.handlersMu.RUnlock()
+
+	conn.Logger().Debug("получено сообщение", "msg_type", msg.Type)
+	h(conn.Context(), conn, msg)
+}
+
+// knownMessageType сообщает, что сообщения типа typ обрабатывает зарегистрированный
+// обработчик или само соединение (ответы на пинг и заголовки потоков).
+func (p *Peer) knownMessageType(typ string) bool {
+	switch typ {
+	case "pong", "stream":
+		return true
+	}
+	p.handlersMu.RLock()
+	defer p.handlersMu.RUnlock()
+	_, ok := p.handlers[typ]
+	return ok
+}
+
+// registerHandlers регистрирует обработчики встроенного протокола.
+func (p *Peer) registerHandlers() {
+	p.unknown = p.handleUnknown
+	p.handlers["info"] = p.handleInfo
+	p.handlers["heartbeat"] = func(context.Context, *connection.Connection, *message.Message) {} // Узлы прежних версий
+	p.handlers["ping"] = p.handlePing
+	p.handlers["bye"] = p.handleBye
+	p.handlers["text"] = p.handleText
+	p.handlers["log"] = p.handleLog
+	p.handlers["file"] = p.handleFileOffer
+	p.handlers["file-chunk"] = p.handleFileChunk
+	p.handlers["file-end"] = p.handleFileEnd
+	p.handlers["file-accept"] = p.handleFileAccept
+	p.handlers["file-pause"] = p.handleFilePause
+	p.handlers["file-resume"] = p.handleFilePause
+	p.handlers["file-cancel"] = p.handleFileCancel
+	p.handlers["file-request"] = p.handleFileRequest
+}
+
+// handleUnknown записывает в журнал сообщение неизвестного типа.
+// На запрос неизвестного типа отвечает ошибкой, чтобы отправитель не ждал таймаута.
+func (p *Peer) handleUnknown(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	conn.Logger().Warn("сообщение неизвестного типа отброшено", "msg_type", msg.Type)
+	if msg.RequestID != "" {
+		conn.ReplyError(msg, fmt.Errorf("неизвестный тип сообщения %q", msg.Type))
+	}
+}
+
+// handlePing отвечает на пинг, позволяя узлу измерить время приёма-передачи.
+func (p *Peer) handlePing(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	conn.Reply(msg, message.Message{
+		Type:   "pong",
+		Sender: p.Username,
+	})
+}
+
+// handleBye закрывает соединение с узлом, который завершает работу.
+func (p *Peer) handleBye(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	conn.Logger().Info("узел завершает работу", "msg_type", msg.Type)
+	conn.Close()
+}
+
+// handleText сохраняет текстовое сообщение в истории чата и сообщает о нём подписчикам.
+func (p *Peer) handleText(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	conn.Logger().Info("текстовое сообщение", "msg_type", msg.Type, "sender", msg.Sender, "content", msg.Content)
+	conn.AddMessages(*msg)
+	p.emit(Event{Type: EventTextReceived, Conn: conn, Message: msg})
+}
+
+// handleLog добавляет сообщение в журнал узла.
+func (p *Peer) handleLog(_ context.Context, _ *connection.Connection, msg *message.Message) {
+	p.logMu.Lock()
+	p.log = append(p.log, *msg)
+	p.logMu.Unlock()
+}
@@ -0,0 +1,86 @@
+package peer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+)
+
+// newPeerID возвращает случайный идентификатор узла.
+func newPeerID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("peer: не удалось сгенерировать идентификатор: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// handleInfo обрабатывает рукопожатие: запоминает сведения об удалённом узле
+// и оставляет не более одного соединения с каждым узлом.
+func (p *Peer) handleInfo(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	conn.Logger().Debug("информация о соединении", "msg_type", msg.Type, "username", msg.Sender)
+	conn.SetUsername(msg.Sender)
+	conn.SetTransports(msg.Transports)
+	p.mu.RLock()
+	if len(p.compression) > 0 {
+		conn.SetCompression(msg.Compression)
+	}
+	p.mu.RUnlock()
+	if msg.PeerID != "" { // Без идентификатора дубликаты определить нельзя
+		conn.SetPeerID(msg.PeerID)
+		if !p.bindPeer(conn) {
+			conn.Close()
+			return
+		}
+	}
+	p.applyPeerLimits(conn)
+	p.announceConnected(conn)
+}
+
+// bindPeer закрепляет соединение за удалённым узлом.
+// Если A и B подключились друг к другу одновременно, обе стороны оставляют одно и то же
+// соединение: установленное узлом с меньшим идентификатором. Возвращает false,
+// если соединение conn лишнее и должно быть закрыто.
+func (p *Peer) bindPeer(conn *connection.Connection) bool {
+	if conn.PeerID() == p.ID {
+		conn.Logger().Info("соединение с самим собой отклонено")
+		return false
+	}
+
+	p.peersMu.Lock()
+	existing, ok := p.peers[conn.PeerID()]
+	if !ok || existing == conn || existing.IsClosed() {
+		p.peers[conn.PeerID()] = conn
+		p.peersMu.Unlock()
+		return true
+	}
+	if !p.preferred(conn) || p.preferred(existing) {
+		p.peersMu.Unlock()
+		conn.Logger().Info("дублирующее соединение закрыто")
+		return false
+	}
+	p.peers[conn.PeerID()] = conn
+	p.peersMu.Unlock()
+
+	existing.Logger().Info("дублирующее соединение закрыто")
+	existing.Close()
+	return true
+}
+
+// unbindPeer снимает закрепление соединения за удалённым узлом.
+func (p *Peer) unbindPeer(conn *connection.Connection) {
+	p.peersMu.Lock()
+	defer p.peersMu.Unlock()
+	if p.peers[conn.PeerID()] == conn {
+		delete(p.peers, conn.PeerID())
+	}
+}
+
+// preferred сообщает, должно ли соединение остаться при дублировании:
+// сохраняется соединение, установленное узлом с меньшим идентификатором.
+func (p *Peer) preferred(conn *connection.Connection) bool {
+	return (p.ID < conn.PeerID()) == conn.Outbound
+}
@@ -0,0 +1,164 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+func TestDuplicateConnections(t *testing.T) {
+	tests := []struct {
+		name    string
+		connect func(a, b *Peer) // Подключения, после которых должно остаться одно соединение
+	}{
+		{"a подключается к b", func(a, b *Peer) {
+			a.ConnectToPeer(context.Background(), memAddr(b))
+		}},
+		{"подключения в обе стороны по очереди", func(a, b *Peer) {
+			a.ConnectToPeer(context.Background(), memAddr(b))
+			b.ConnectToPeer(context.Background(), memAddr(a))
+		}},
+		{"одновременные подключения", func(a, b *Peer) {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				b.ConnectToPeer(context.Background(), memAddr(a))
+			}()
+			a.ConnectToPeer(context.Background(), memAddr(b))
+			<-done
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := transport.NewMemory()
+			a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+			// Дубликат может закрыться удалённой стороной раньше, чем локальная закрепит
+			// оставшееся соединение; тогда узел переподключается после задержки
+			for _, p := range []*Peer{a, b} {
+				p.SetReconnectPolicy(ReconnectPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 1})
+			}
+			tt.connect(a, b)
+
+			// Обе стороны оставляют одно и то же соединение: исходящее для одной, входящее для другой
+			peertest.Eventually(t, "одно общее соединение", func() bool {
+				for _, st := range append(a.PeerStates(), b.PeerStates()...) {
+					if st.State != PeerConnected {
+						return false
+					}
+				}
+				ab, ba := a.liveConnection(b.ID), b.liveConnection(a.ID)
+				return openConnections(a) == 1 && openConnections(b) == 1 &&
+					ab != nil && ba != nil && ab.Outbound != ba.Outbound
+			})
+		})
+	}
+}
+
+// pipeConnection создаёт соединение с узлом remoteID поверх транспорта в памяти.
+// Противоположный конец закрывается по завершении теста.
+func pipeConnection(t *testing.T, outbound bool, remoteID string) *connection.Connection {
+	t.Helper()
+	mem := transport.NewMemory()
+	l, err := mem.Listen("remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	remote := make(chan *connection.Connection, 1)
+	go func() {
+		raw, err := l.Accept()
+		if err != nil {
+			remote <- nil
+			return
+		}
+		remote <- connection.NewConnection(raw, !outbound, message.Message{Type: "info"}, peertest.Discard)
+	}()
+	raw, err := mem.Dial(context.Background(), "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := connection.NewConnection(raw, outbound, message.Message{Type: "info"}, peertest.Discard)
+	other := <-remote
+	t.Cleanup(func() {
+		c.Close()
+		if other != nil {
+			other.Close()
+		}
+	})
+	c.SetPeerID(remoteID)
+	return c
+}
+
+func TestBindPeer(t *testing.T) {
+	const local = "m"
+	tests := []struct {
+		name         string
+		existing     func(t *testing.T) *connection.Connection // Уже закреплённое соединение (nil - нет)
+		outbound     bool                                      // Направление нового соединения
+		remote       string                                    // Идентификатор удалённого узла
+		want         bool                                      // Новое соединение закреплено
+		closeCurrent bool                                      // Прежнее соединение закрыто
+	}{
+		{"первое соединение", nil, false, "z", true, false},
+		{"соединение с самим собой", nil, true, local, false, false},
+		{"меньший id у нас: исходящее вытесняет входящее", func(t *testing.T) *connection.Connection {
+			return pipeConnection(t, false, "z")
+		}, true, "z", true, true},
+		{"меньший id у нас: входящее лишнее", func(t *testing.T) *connection.Connection {
+			return pipeConnection(t, true, "z")
+		}, false, "z", false, false},
+		{"меньший id у узла: входящее вытесняет исходящее", func(t *testing.T) *connection.Connection {
+			return pipeConnection(t, true, "a")
+		}, false, "a", true, true},
+		{"меньший id у узла: исходящее лишнее", func(t *testing.T) *connection.Connection {
+			return pipeConnection(t, false, "a")
+		}, true, "a", false, false},
+		{"прежнее соединение закрыто", func(t *testing.T) *connection.Connection {
+			c := pipeConnection(t, true, "z")
+			c.Close()
+			return c
+		}, false, "z", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPeer("m", "m", "1", transport.NewMemory())
+			p.ID = local
+			var existing *connection.Connection
+			if tt.existing != nil {
+				existing = tt.existing(t)
+				p.peers[existing.PeerID()] = existing
+			}
+			conn := pipeConnection(t, tt.outbound, tt.remote)
+
+			if got := p.bindPeer(conn); got != tt.want {
+				t.Fatalf("bindPeer = %v, ожидалось %v", got, tt.want)
+			}
+			if bound := p.peers[tt.remote] == conn; bound != tt.want {
+				t.Fatalf("новое соединение закреплено: %v", bound)
+			}
+			if existing != nil && existing.IsClosed() != tt.closeCurrent {
+				t.Fatalf("прежнее соединение закрыто: %v, ожидалось %v", existing.IsClosed(), tt.closeCurrent)
+			}
+		})
+	}
+}
+
+func TestConnectionLookup(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	ab := connect(t, a, b)
+
+	for _, name := range []string{memAddr(b), b.ID, "b"} {
+		if conn, ok := a.Connection(name); !ok || conn != ab {
+			t.Errorf("Connection(%q) не нашёл соединение с b", name)
+		}
+	}
+	if _, ok := a.Connection("c"); ok {
+		t.Error("найдено соединение с неизвестным узлом")
+	}
+}
@@ -0,0 +1,153 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+)
+
+const (
+	// ShutdownTimeout - время на остановку узла после отмены контекста Start
+	ShutdownTimeout = 10 * time.Second
+	// byeTimeout - время ожидания, пока удалённый узел закроет соединение после "bye"
+	byeTimeout = 2 * time.Second
+)
+
+// ErrPeerClosed - узел остановлен или останавливается
+var ErrPeerClosed = errors.New("peer: узел остановлен")
+
+// Start запускает приём входящих соединений по основному транспорту.
+// Отмена ctx останавливает узел так же, как Shutdown, но не дольше ShutdownTimeout.
+func (p *Peer) Start(ctx context.Context) error {
+	if err := p.StartListener(p.transport.Name()); err != nil {
+		return err
+	}
+	context.AfterFunc(ctx, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := p.Shutdown(ctx); err != nil {
+			p.Logger().Error("остановка узла не завершена", "err", err)
+		}
+	})
+	return nil
+}
+
+// Shutdown останавливает узел: закрывает слушатели, Bootstrap-сервер и сервер метрик, прекращает
+// переподключения, дожидается завершения передач файлов, отправляет "bye" каждому
+// соединению и ждёт завершения всех горутин узла.
+// Если ctx завершается раньше, незавершённые передачи отменяются, соединения
+// закрываются без ожидания и возвращается ошибка ctx. Повторный вызов ждёт
+// завершения уже идущей остановки.
+func (p *Peer) Shutdown(ctx context.Context) error {
+	first := false
+	p.stopOnce.Do(func() { first = true })
+	if !first {
+		select {
+		case <-p.stopped:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer close(p.stopped)
+	p.Logger().Info("остановка узла", "addr", p.Addr())
+
+	p.mu.Lock()
+	p.stopping = true
+	listeners := make([]net.Listener, 0, len(p.listeners))
+	for _, l := range p.listeners {
+		listeners = append(listeners, l)
+	}
+	b := p.Bootstrap
+	p.mu.Unlock()
+
+	p.cancel() // Останавливает переподключения и регистрацию новых соединений
+	for _, l := range listeners {
+		l.Close()
+	}
+	if b != nil {
+		b.Close()
+	}
+	p.closeMetrics()
+
+	err := p.Transfers.Drain(ctx)
+	if err != nil {
+		p.Logger().Warn("передачи файлов прерваны", "err", err)
+	}
+
+	var wg sync.WaitGroup
+	p.Connections.Range(func(_, value any) bool {
+		wg.Add(1)
+		go func(conn *connection.Connection) {
+			defer wg.Done()
+			p.sayBye(ctx, conn)
+		}(value.(*connection.Connection))
+		return true
+	})
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.Logger().Info("узел остановлен", "addr", p.Addr())
+	return err
+}
+
+// Done возвращает канал, который закрывается после остановки узла.
+func (p *Peer) Done() <-chan struct{} {
+	return p.stopped
+}
+
+// sayBye сообщает узлу о завершении работы и закрывает соединение,
+// дав удалённой стороне время закрыть его первой.
+func (p *Peer) sayBye(ctx context.Context, conn *connection.Connection) {
+	defer conn.Close()
+	bye := message.Message{
+		Type:   "bye",
+		Sender: p.Username,
+	}
+	if err := conn.Send(bye); err != nil {
+		return
+	}
+	timer := time.NewTimer(byeTimeout)
+	defer timer.Stop()
+	select {
+	case <-conn.Done():
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// spawn запускает fn в горутине, завершения которой ждёт Shutdown.
+// Возвращает false, если узел уже останавливается.
+func (p *Peer) spawn(fn func()) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopping {
+		return false
+	}
+	p.track(fn)
+	return true
+}
+
+// track запускает fn в горутине, завершения которой ждёт Shutdown.
+// Вызывается только из горутин, уже учтённых через spawn или track.
+func (p *Peer) track(fn func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		fn()
+	}()
+}
@@ -0,0 +1,58 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+func TestShutdownDuringHandshake(t *testing.T) {
+	// Соединение, принятое одновременно с остановкой, не должно задерживать Shutdown
+	for i := 0; i < 20; i++ {
+		mem := transport.NewMemory()
+		a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+		a.ConnectToPeer(context.Background(), memAddr(b))
+
+		ctx, cancel := context.WithTimeout(context.Background(), peertest.WaitTimeout)
+		start := time.Now()
+		for _, p := range []*Peer{b, a} {
+			if err := p.Shutdown(ctx); err != nil {
+				t.Fatalf("итерация %d: остановка %s: %v", i, p.Username, err)
+			}
+		}
+		cancel()
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("итерация %d: остановка заняла %s", i, d)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	connect(t, a, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), peertest.WaitTimeout)
+	defer cancel()
+	if err := a.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-a.Done():
+	default:
+		t.Fatal("Done не закрыт после Shutdown")
+	}
+	// Удалённый узел получает "bye" и закрывает соединение
+	peertest.Eventually(t, "закрытие соединения удалённым узлом", func() bool { return openConnections(b) == 0 })
+
+	if err := a.ConnectToPeer(ctx, memAddr(b)); !errors.Is(err, ErrPeerClosed) {
+		t.Fatalf("ConnectToPeer после остановки: %v", err)
+	}
+	if err := a.Shutdown(ctx); err != nil {
+		t.Fatalf("повторный Shutdown: %v", err)
+	}
+}
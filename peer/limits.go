@@ -0,0 +1,94 @@
+package peer
+
+import (
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+)
+
+// PeerLimit - ограничения скорости обмена с одним узлом в байтах в секунду (0 - без ограничения).
+type PeerLimit struct {
+	Upload   int64 // Скорость отправки узлу
+	Download int64 // Скорость приёма от узла
+}
+
+// pendingLimit - лимит, заданный для ещё не подключавшегося узла.
+type pendingLimit struct {
+	name  string // Адрес, идентификатор или имя пользователя узла
+	limit PeerLimit
+}
+
+// SetPeerLimit задаёт ограничения скорости для узла name - его адреса, идентификатора
+// или имени пользователя. Лимиты применяются к текущему соединению с узлом и к соединениям,
+// установленным позже. Для подключённого узла лимит сохраняется по его идентификатору,
+// заменяя заданный раньше; для остальных ждёт рукопожатия узла, совпавшего с name.
+// Возвращает false, если узел сейчас не подключён.
+func (p *Peer) SetPeerLimit(name string, up, down int64) bool {
+	l := PeerLimit{Upload: up, Download: down}
+	_, conn, ok := p.lookup(name)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dropPendingLimit(name)
+	if ok && conn.PeerID() != "" {
+		p.peerLimits[conn.PeerID()] = l
+	} else {
+		p.pendingLimits = append(p.pendingLimits, pendingLimit{name: name, limit: l})
+	}
+	if ok {
+		p.Throttle.SetPeer(conn.ThrottleKey(), up, down)
+	}
+	return ok
+}
+
+// PeerLimits возвращает лимиты, заданные через SetPeerLimit: по идентификаторам
+// подключавшихся узлов и по именам, ещё не сопоставленным с узлом.
+func (p *Peer) PeerLimits() map[string]PeerLimit {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	limits := make(map[string]PeerLimit, len(p.peerLimits)+len(p.pendingLimits))
+	for id, l := range p.peerLimits {
+		limits[id] = l
+	}
+	for _, pl := range p.pendingLimits {
+		limits[pl.name] = pl.limit
+	}
+	return limits
+}
+
+// applyPeerLimits применяет к соединению после рукопожатия лимиты, заданные для узла.
+// Ожидающие лимиты, совпавшие с соединением, переносятся на идентификатор узла;
+// из нескольких совпавших действует заданный последним.
+func (p *Peer) applyPeerLimits(conn *connection.Connection) {
+	id := conn.PeerID()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.peerLimits[id]
+	pending := p.pendingLimits[:0]
+	for _, pl := range p.pendingLimits {
+		if _, c, found := p.lookup(pl.name); !found || c != conn {
+			pending = append(pending, pl)
+			continue
+		}
+		l, ok = pl.limit, true
+		if id != "" {
+			p.peerLimits[id] = pl.limit
+		} else {
+			pending = append(pending, pl) // Без идентификатора привязать лимит не к чему
+		}
+	}
+	clear(p.pendingLimits[len(pending):])
+	p.pendingLimits = pending
+	if ok {
+		p.Throttle.SetPeer(conn.ThrottleKey(), l.Upload, l.Download)
+	}
+}
+
+// dropPendingLimit удаляет ожидающий лимит name. Вызывается под p.mu.
+func (p *Peer) dropPendingLimit(name string) {
+	for i, pl := range p.pendingLimits {
+		if pl.name == name {
+			p.pendingLimits = append(p.pendingLimits[:i], p.pendingLimits[i+1:]...)
+			return
+		}
+	}
+}
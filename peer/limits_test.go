@@ -0,0 +1,73 @@
+package peer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+func TestSetPeerLimit(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	a.SetReconnectPolicy(ReconnectPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 1})
+
+	// Лимит по имени, заданный до подключения, применяется после рукопожатия
+	if a.SetPeerLimit("b", 1000, 2000) {
+		t.Fatal("SetPeerLimit сообщил о подключённом узле до подключения")
+	}
+	if got := a.PeerLimits(); !reflect.DeepEqual(got, map[string]PeerLimit{"b": {1000, 2000}}) {
+		t.Fatalf("лимиты до подключения %v", got)
+	}
+	first := connect(t, a, b)
+
+	tests := []struct {
+		name             string
+		set              func() bool
+		wantUp, wantDown int64
+	}{
+		{"заданный до подключения", func() bool { return true }, 1000, 2000},
+		{"по имени", func() bool { return a.SetPeerLimit("b", 10, 20) }, 10, 20},
+		{"по идентификатору", func() bool { return a.SetPeerLimit(b.ID, 30, 40) }, 30, 40},
+		{"по адресу", func() bool { return a.SetPeerLimit(memAddr(b), 50, 60) }, 50, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.set() {
+				t.Fatal("узел не найден")
+			}
+			if up, down := a.Throttle.Peer(b.ID); up != tt.wantUp || down != tt.wantDown {
+				t.Fatalf("лимиты %d, %d; ожидалось %d, %d", up, down, tt.wantUp, tt.wantDown)
+			}
+			// Как бы ни был назван узел, хранится один лимит - по его идентификатору
+			want := map[string]PeerLimit{b.ID: {tt.wantUp, tt.wantDown}}
+			if got := a.PeerLimits(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("сохранённые лимиты %v, ожидалось %v", got, want)
+			}
+		})
+	}
+
+	// После переподключения действует последний заданный лимит. О подключении
+	// сообщается после применения лимитов
+	connected := make(chan *connection.Connection, 1)
+	a.Subscribe(func(e Event) {
+		if e.Conn != first {
+			select {
+			case connected <- e.Conn:
+			default:
+			}
+		}
+	}, EventPeerConnected)
+	b.liveConnection(a.ID).Close()
+	select {
+	case <-connected:
+	case <-time.After(peertest.WaitTimeout):
+		t.Fatal("не дождались переподключения")
+	}
+	if up, down := a.Throttle.Peer(b.ID); up != 50 || down != 60 {
+		t.Fatalf("лимиты после переподключения %d, %d; ожидалось 50, 60", up, down)
+	}
+}
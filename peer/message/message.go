@@ -11,6 +11,18 @@ type Message struct {
 	Sender   string `json:"sender"`
 	Content  string `json:"content"`
 	Filename string `json:"filename,omitempty"`
+	ID       string `json:"id,omitempty"`   // Идентификатор передачи файла
+	Size     int64  `json:"size,omitempty"` // Размер файла в байтах
+	Hash     string `json:"hash,omitempty"` // SHA-256 содержимого файла
+
+	Transports  []string `json:"transports,omitempty"`  // Транспорты, поддерживаемые отправителем (в "info")
+	PeerID      string   `json:"peer_id,omitempty"`     // Идентификатор узла-отправителя (в "info")
+	Compression []string `json:"compression,omitempty"` // Алгоритмы сжатия, поддерживаемые отправителем (в "info")
+	Encoding    string   `json:"encoding,omitempty"`    // Алгоритм сжатия данных потока (в заголовке "stream")
+
+	RequestID string `json:"request_id,omitempty"` // Идентификатор запроса, на который ждут ответа
+	ReplyTo   string `json:"reply_to,omitempty"`   // Идентификатор запроса, на который отвечает сообщение
+	Error     string `json:"error,omitempty"`      // Ошибка обработки запроса (в ответе)
 }
 
 func NewMessage(typeFormat string, sender string, content string, filename *string) (*Message, error) {
@@ -39,3 +51,14 @@ func (m *Message) Bytes() (string, error) {
 	bytes, err := json.Marshal(m)
 	return string(bytes), err
 }
+
+// Bulk сообщает, относится ли сообщение к массовой передаче данных (блоки файлов).
+// Такие сообщения подлежат ограничению скорости, в отличие от служебных и текстовых.
+func (m *Message) Bulk() bool {
+	return m.Type == "file-chunk"
+}
+
+// IsReply сообщает, является ли сообщение ответом на запрос.
+func (m *Message) IsReply() bool {
+	return m.ReplyTo != ""
+}
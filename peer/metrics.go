@@ -0,0 +1,233 @@
+package peer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+// MetricsPath - путь HTTP-сервера метрик
+const MetricsPath = "/metrics"
+
+// metrics накапливает показатели соединений узла, чтобы счётчики
+// не уменьшались после закрытия соединений.
+type metrics struct {
+	mu      sync.Mutex
+	live    map[*connection.Connection]struct{} // Незакрытые соединения
+	opened  uint64                              // Установлено соединений
+	traffic connection.Traffic                  // Трафик закрытых соединений
+	missed  uint64                              // Пропущенные ответы на пинги закрытых соединений
+	dropped uint64                              // Отброшенные очередью сообщения закрытых соединений
+	server  *http.Server                        // HTTP-сервер метрик
+}
+
+// add начинает учёт соединения.
+func (m *metrics) add(conn *connection.Connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.live == nil {
+		m.live = make(map[*connection.Connection]struct{})
+	}
+	m.live[conn] = struct{}{}
+	m.opened++
+}
+
+// retire переносит показатели закрытого соединения в накопленные.
+func (m *metrics) retire(conn *connection.Connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.live[conn]; !ok {
+		return
+	}
+	delete(m.live, conn)
+	m.traffic.Add(conn.Traffic())
+	st := conn.Stats()
+	m.missed += uint64(st.Pings - st.Pongs)
+	m.dropped += conn.QueueStats().Dropped
+}
+
+// ServeMetrics запускает HTTP-сервер метрик в формате Prometheus на addr (путь MetricsPath).
+// Сервер останавливается вместе с узлом.
+func (p *Peer) ServeMetrics(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("не удалось запустить сервер метрик на %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, p.MetricsHandler())
+	srv := &http.Server{Handler: mux}
+
+	p.metrics.mu.Lock()
+	if p.metrics.server != nil {
+		p.metrics.mu.Unlock()
+		l.Close()
+		return errors.New("сервер метрик уже запущен")
+	}
+	p.metrics.server = srv
+	p.metrics.mu.Unlock()
+
+	started := p.spawn(func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			p.Logger().Error("сервер метрик остановлен", "err", err)
+		}
+	})
+	if !started {
+		l.Close()
+		return ErrPeerClosed
+	}
+	p.Logger().Info("сервер метрик запущен", "url", "http://"+l.Addr().String()+MetricsPath)
+	return nil
+}
+
+// closeMetrics останавливает HTTP-сервер метрик, если он запущен.
+func (p *Peer) closeMetrics() {
+	p.metrics.mu.Lock()
+	srv := p.metrics.server
+	p.metrics.mu.Unlock()
+	if srv != nil {
+		srv.Close()
+	}
+}
+
+// MetricsHandler возвращает HTTP-обработчик, отдающий метрики узла
+// в текстовом формате Prometheus.
+func (p *Peer) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		p.writeMetrics(bw)
+		bw.Flush()
+	})
+}
+
+// writeMetrics записывает метрики узла в w.
+func (p *Peer) writeMetrics(w *bufio.Writer) {
+	m := &p.metrics
+	m.mu.Lock()
+	opened, missed, dropped := m.opened, m.missed, m.dropped
+	var traffic connection.Traffic
+	traffic.Add(m.traffic)
+	live := make([]*connection.Connection, 0, len(m.live))
+	for conn := range m.live {
+		live = append(live, conn)
+		traffic.Add(conn.Traffic())
+		st := conn.Stats()
+		missed += uint64(st.Pings - st.Pongs)
+		dropped += conn.QueueStats().Dropped
+	}
+	m.mu.Unlock()
+
+	p.peersMu.Lock()
+	peers := len(p.peers)
+	p.peersMu.Unlock()
+
+	family(w, "p2pfs_peers_connected", "gauge", "Число узлов, с которыми установлено соединение после рукопожатия.")
+	sample(w, "p2pfs_peers_connected", float64(peers))
+	family(w, "p2pfs_connections", "gauge", "Число открытых соединений.")
+	sample(w, "p2pfs_connections", float64(len(live)))
+	family(w, "p2pfs_connections_opened_total", "counter", "Число установленных соединений.")
+	sample(w, "p2pfs_connections_opened_total", float64(opened))
+
+	family(w, "p2pfs_bytes_total", "counter", "Объём трафика соединений в байтах.")
+	sample(w, "p2pfs_bytes_total", float64(traffic.BytesIn), "direction", "in")
+	sample(w, "p2pfs_bytes_total", float64(traffic.BytesOut), "direction", "out")
+	family(w, "p2pfs_messages_total", "counter", "Число сообщений по типам; сообщения неизвестных типов учитываются как other.")
+	for _, dir := range []struct {
+		name   string
+		counts map[string]uint64
+	}{{"in", traffic.MessagesIn}, {"out", traffic.MessagesOut}} {
+		for _, typ := range sortedKeys(dir.counts) {
+			sample(w, "p2pfs_messages_total", float64(dir.counts[typ]), "direction", dir.name, "type", typ)
+		}
+	}
+
+	family(w, "p2pfs_heartbeat_failures_total", "counter", "Число пингов, оставшихся без ответа.")
+	sample(w, "p2pfs_heartbeat_failures_total", float64(missed))
+	// Показатели отдельных соединений сводятся по узлу: адреса соединений
+	// (с временными портами) в метках неограниченно умножали бы ряды
+	var rtt time.Duration
+	var depth int
+	for _, conn := range live {
+		if st := conn.Stats(); st.Pongs > 0 {
+			rtt = max(rtt, st.SRTT)
+		}
+		depth += conn.QueueStats().Depth
+	}
+	family(w, "p2pfs_heartbeat_rtt_seconds", "gauge", "Наибольшее сглаженное время приёма-передачи пинга среди соединений.")
+	sample(w, "p2pfs_heartbeat_rtt_seconds", rtt.Seconds())
+	family(w, "p2pfs_send_queue_depth", "gauge", "Число сообщений в очередях отправки всех соединений.")
+	sample(w, "p2pfs_send_queue_depth", float64(depth))
+	family(w, "p2pfs_send_queue_dropped_total", "counter", "Число сообщений, отброшенных переполненной очередью отправки.")
+	sample(w, "p2pfs_send_queue_dropped_total", float64(dropped))
+
+	family(w, "p2pfs_reconnect_attempts_total", "counter", "Число повторных попыток подключения к желаемым узлам.")
+	sample(w, "p2pfs_reconnect_attempts_total", float64(p.reconnect.retries.Load()))
+	family(w, "p2pfs_dial_failures_total", "counter", "Число неудачных попыток подключения к желаемым узлам.")
+	sample(w, "p2pfs_dial_failures_total", float64(p.reconnect.failures.Load()))
+
+	ts := p.Transfers.Stats()
+	family(w, "p2pfs_transfers_active", "gauge", "Число идущих передач файлов.")
+	sample(w, "p2pfs_transfers_active", float64(ts.Active))
+	family(w, "p2pfs_transfers_queued", "gauge", "Число передач файлов в очереди.")
+	sample(w, "p2pfs_transfers_queued", float64(ts.Queued))
+	directions := []transfer.Direction{transfer.DirectionUpload, transfer.DirectionDownload}
+	family(w, "p2pfs_transfers_total", "counter", "Число завершённых передач файлов по исходу.")
+	for _, dir := range directions {
+		sample(w, "p2pfs_transfers_total", float64(ts.Completed[dir]), "direction", dir.String(), "result", transfer.StateCompleted.String())
+		sample(w, "p2pfs_transfers_total", float64(ts.Failed[dir]), "direction", dir.String(), "result", transfer.StateFailed.String())
+		sample(w, "p2pfs_transfers_total", float64(ts.Canceled[dir]), "direction", dir.String(), "result", transfer.StateCanceled.String())
+	}
+	family(w, "p2pfs_transfer_bytes_total", "counter", "Объём переданных данных файлов в байтах.")
+	for _, dir := range directions {
+		sample(w, "p2pfs_transfer_bytes_total", float64(ts.Bytes[dir]), "direction", dir.String())
+	}
+}
+
+// sortedKeys возвращает упорядоченные ключи m.
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// family записывает описание метрики.
+func family(w *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample записывает значение метрики с метками, заданными парами имя-значение.
+func sample(w *bufio.Writer, name string, value float64, labels ...string) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labels[i])
+			w.WriteString(`="`)
+			w.WriteString(labelEscaper.Replace(labels[i+1]))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.WriteByte('\n')
+}
+
+// labelEscaper экранирует значения меток по правилам текстового формата Prometheus.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,57 @@
+package peer
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// scrape возвращает метрики узла в текстовом формате.
+func scrape(t *testing.T, p *Peer) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	p.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	connect(t, a, b)
+
+	ba := b.liveConnection(a.ID)
+	for _, typ := range []string{"text", "junk-1", "junk-2"} {
+		if err := ba.Send(message.Message{Type: typ, Content: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const other = `p2pfs_messages_total{direction="in",type="other"} 2`
+	peertest.Eventually(t, "учёт сообщений неизвестных типов", func() bool {
+		return strings.Contains(scrape(t, a), other)
+	})
+
+	out := scrape(t, a)
+	for _, want := range []string{
+		"p2pfs_peers_connected 1\n",
+		"p2pfs_connections 1\n",
+		`p2pfs_messages_total{direction="in",type="text"} 1`,
+		"p2pfs_send_queue_depth ",
+		"p2pfs_heartbeat_rtt_seconds ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("нет строки %q", want)
+		}
+	}
+	// Метки не должны расти с числом соединений и типов, выбранных удалённым узлом
+	for _, unwanted := range []string{"junk-", "remote_addr", ba.Addr(), a.liveConnection(b.ID).Addr()} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("метрики содержат %q", unwanted)
+		}
+	}
+}
@@ -3,187 +3,451 @@
 package peer
 
 import (
-	"bufio"
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/WhiCu/p2pFileShare/peer/bootstrap"
 	"github.com/WhiCu/p2pFileShare/peer/connection"
 	"github.com/WhiCu/p2pFileShare/peer/message"
-)
-
-const (
-	connReadDeadline = 20 * time.Minute // Таймаут для чтения из соединения
+	"github.com/WhiCu/p2pFileShare/peer/ratelimit"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+	"github.com/WhiCu/p2pFileShare/transfer"
 )
 
 // Peer представляет узел в P2P-сети.
 // Содержит информацию об адресе узла и активных соединениях.
 // Также управляет прослушиванием входящих соединений.
+// Методы Peer безопасны для вызова из нескольких горутин; Host и Port задаются до запуска.
 type Peer struct {
-	Username      string                     // Имя пользователя
-	Host          string                     // Хост узла (IP-адрес)
-	Port          string                     // Порт узла
-	Connections   *sync.Map                  // Активные соединения с другими узлами
-	Listener      net.Listener               // Слушатель для входящих TCP-соединений
-	BootstrapPort string                     // Порт сервера Bootstrap
-	Bootstrap     *bootstrap.BootstrapServer // Экземпляр Bootstrap-сервера
-	log           []message.Message          // Журнал сообщений
+	ID            string                            // Уникальный идентификатор узла
+	Username      string                            // Имя пользователя
+	Host          string                            // Хост узла (IP-адрес)
+	Port          string                            // Порт узла
+	Connections   *sync.Map                         // Активные соединения с другими узлами
+	BootstrapPort string                            // Порт сервера Bootstrap
+	Bootstrap     *bootstrap.BootstrapServer        // Экземпляр Bootstrap-сервера
+	Transfers     *transfer.Manager                 // Очередь и активные передачи файлов
+	Throttle      *ratelimit.Throttle               // Ограничения скорости отдачи и приёма
+	mu            sync.RWMutex                      // Защищает Port, Bootstrap, слушатели, advertise, транспорты, downloads, progress, лимиты узлов, acceptTimeout, logger и stopping
+	logger        *slog.Logger                      // Журнал узла
+	listener      net.Listener                      // Слушатель основного транспорта
+	listeners     map[string]net.Listener           // Слушатели по именам транспортов
+	advertise     string                            // Внешний адрес узла для ссылок ("" - адрес слушателя)
+	transports    map[string]transport.Transport    // Доступные транспорты по именам (схемам адресов)
+	transport     transport.Transport               // Основной транспорт
+	downloads     *transfer.Receiver                // Приёмник входящих файлов (nil - файлы не принимаются)
+	progress      transfer.ProgressFunc             // Обработчик событий о ходе передачи файлов
+	queuePolicy   connection.QueuePolicy            // Политика очереди отправки новых соединений
+	peerLimits    map[string]PeerLimit              // Лимиты скорости по идентификатору узла
+	pendingLimits []pendingLimit                    // Лимиты ещё не подключавшихся узлов в порядке задания
+	acceptTimeout time.Duration                     // Время ожидания согласия получателя принять файл
+	compression   []string                          // Алгоритмы сжатия, предлагаемые узлам при рукопожатии
+	logMu         sync.Mutex                        // Защищает log
+	log           []message.Message                 // Журнал сообщений
+	incoming      sync.Map                          // Принимаемые файлы: incomingKey -> *incomingFile
+	outgoing      sync.Map                          // Отправляемые файлы: ID -> *outgoingFile
+	shared        sync.Map                          // Файлы, открытые по ссылке: ID -> путь
+	peersMu       sync.Mutex                        // Защищает peers
+	peers         map[string]*connection.Connection // Единственное соединение с каждым узлом: ID узла -> соединение
+	reconnect     *reconnector                      // Поддержание соединений с желаемыми узлами
+	handlersMu    sync.RWMutex                      // Защищает handlers, unknown и middleware
+	handlers      map[string]HandlerFunc            // Обработчики сообщений по типам
+	unknown       HandlerFunc                       // Обработчик сообщений неизвестных типов
+	middleware    []Middleware                      // Промежуточные обработчики всех сообщений
+	eventsMu      sync.RWMutex                      // Защищает subscribers и nextSub
+	subscribers   map[int]subscriber                // Подписчики на события узла
+	nextSub       int                               // Идентификатор последней подписки
+	announced     sync.Map                          // Соединения, о подключении которых сообщено подписчикам
+	metrics       metrics                           // Накопленные показатели соединений и сервер метрик
+
+	ctx      context.Context    // Контекст работы узла, отменяется при остановке
+	cancel   context.CancelFunc // Отменяет ctx
+	stopping bool               // Узел останавливается: новые слушатели и горутины не запускаются
+	wg       sync.WaitGroup     // Горутины узла, завершения которых ждёт Shutdown
+	stopOnce sync.Once          // Гарантирует однократную остановку
+	stopped  chan struct{}      // Закрывается после остановки узла
 }
 
 // NewTCPPeer создаёт новый узел Peer с указанным именем пользователя, хостом и портом.
 func NewTCPPeer(username, host, port string) *Peer {
-	return &Peer{
-		Username:    username,
-		Host:        host,
-		Port:        port,
-		Connections: &sync.Map{},
+	return NewPeer(username, host, port, transport.TCP())
+}
+
+// NewPeer создаёт новый узел Peer с указанными транспортами.
+// Первый транспорт считается основным: через него работают StartListener и Bootstrap-сервер.
+// Без транспортов используется TCP.
+func NewPeer(username, host, port string, transports ...transport.Transport) *Peer {
+	p := &Peer{
+		ID:            newPeerID(),
+		Username:      username,
+		Host:          host,
+		Port:          port,
+		Connections:   &sync.Map{},
+		listeners:     make(map[string]net.Listener),
+		transports:    make(map[string]transport.Transport),
+		Throttle:      ratelimit.NewThrottle(),
+		peers:         make(map[string]*connection.Connection),
+		peerLimits:    make(map[string]PeerLimit),
+		subscribers:   make(map[int]subscriber),
+		handlers:      make(map[string]HandlerFunc),
+		stopped:       make(chan struct{}),
+		compression:   connection.SupportedCompressions,
+		logger:        slog.Default(),
+		acceptTimeout: DefaultAcceptTimeout,
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.reconnect = newReconnector(p, DefaultReconnectPolicy)
+	p.registerHandlers()
+	if len(transports) == 0 {
+		transports = []transport.Transport{transport.TCP()}
 	}
+	for _, t := range transports {
+		p.AddTransport(t)
+	}
+	p.Transfers = transfer.NewManager(transfer.DefaultMaxActive, transfer.DefaultMaxPerPeer, p.notifyProgress)
+	return p
+}
+
+// SetLogger задаёт журнал узла. Он же передаётся новым соединениям и Bootstrap-серверу.
+// nil восстанавливает slog.Default().
+func (p *Peer) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	p.mu.Lock()
+	p.logger = logger
+	p.mu.Unlock()
+}
+
+// Logger возвращает журнал узла.
+func (p *Peer) Logger() *slog.Logger {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.logger
 }
 
 // Addr возвращает полный адрес узла в формате "host:port".
 func (p *Peer) Addr() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return net.JoinHostPort(p.Host, p.Port)
 }
 
-// TCPAddr разрешает и возвращает TCP-адрес узла.
-func (p *Peer) TCPAddr() *net.TCPAddr {
-	address, err := net.ResolveTCPAddr("tcp", p.Addr())
+// SetAdvertiseAddr задаёт внешний адрес узла "host[:port]", по которому к нему подключаются
+// другие машины (например, адрес за NAT). Он попадает в ссылки Share; без порта
+// используется порт слушателя. Пустая строка - адрес слушателя основного транспорта.
+func (p *Peer) SetAdvertiseAddr(addr string) {
+	p.mu.Lock()
+	p.advertise = addr
+	p.mu.Unlock()
+}
+
+// TCPAddr разрешает и возвращает адрес узла средствами основного транспорта.
+func (p *Peer) TCPAddr() (net.Addr, error) {
+	address, err := p.transport.ResolveAddr(p.Addr())
 	if err != nil {
-		log.Fatalf("%s.TCPAddr: не удалось разрешить TCP-адрес: %v", p.Addr(), err)
+		return nil, fmt.Errorf("не удалось разрешить адрес %s: %w", p.Addr(), err)
 	}
-	return address
+	return address, nil
 }
 
 // StartBootstrap инициализирует и запускает Bootstrap-сервер.
-func (p *Peer) StartBootstrap(bootstrapPort string) {
+// Возвращает ошибку, если сервер не удалось запустить (например, порт занят).
+func (p *Peer) StartBootstrap(bootstrapPort string) error {
+	b := bootstrap.NewBootstrapServer(p.Host, bootstrapPort, p.Connections)
+	b.Transport = p.transport
+	b.Logger = p.Logger()
+	if err := b.Start(); err != nil {
+		return err
+	}
+	p.mu.Lock()
 	p.BootstrapPort = bootstrapPort
-	p.Bootstrap = bootstrap.NewBootstrapServer(p.Host, bootstrapPort, p.Connections)
-	p.Bootstrap.Start()
+	p.Bootstrap = b
+	p.mu.Unlock()
+	return nil
+}
+
+// AddTransport регистрирует транспорт. Адреса со схемой t.Name() (например, "quic://host:port")
+// будут устанавливаться через него. Транспорты регистрируются до запуска узла.
+func (p *Peer) AddTransport(t transport.Transport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.transport == nil {
+		p.transport = t
+	}
+	p.transports[t.Name()] = t
 }
 
-// StartTCPListener запускает TCP-сервер для принятия входящих соединений.
-func (p *Peer) StartTCPListener() {
-	listener, err := net.ListenTCP("tcp", p.TCPAddr())
+// Transport возвращает зарегистрированный транспорт по имени.
+func (p *Peer) Transport(name string) (transport.Transport, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	t, ok := p.transports[name]
+	return t, ok
+}
+
+// TransportNames возвращает имена зарегистрированных транспортов.
+func (p *Peer) TransportNames() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := make([]string, 0, len(p.transports))
+	for name := range p.transports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartTCPListener запускает сервер основного транспорта для принятия входящих соединений.
+// Возвращает ошибку, если адрес узла не удалось занять; тогда можно сменить Port и повторить.
+func (p *Peer) StartTCPListener() error {
+	return p.StartListener(p.transport.Name())
+}
+
+// StartListener запускает приём входящих соединений по транспорту name на адресе узла.
+// Если порт узла равен "0", после запуска основного транспорта Port содержит выбранный системой порт.
+func (p *Peer) StartListener(name string) error {
+	return p.Listen(name, p.Addr())
+}
+
+// Listen запускает приём входящих соединений по транспорту name на адресе addr
+// (например, путь к сокету для транспорта "unix").
+func (p *Peer) Listen(name, addr string) error {
+	t, ok := p.Transport(name)
+	if !ok {
+		return fmt.Errorf("транспорт %q не зарегистрирован", name)
+	}
+	listener, err := t.Listen(addr)
 	if err != nil {
-		log.Fatalf("%s.StartTCPListener: не удалось запустить TCP-сервер: %v", p.Addr(), err)
+		return fmt.Errorf("не удалось запустить %s-сервер: %w", name, err)
+	}
+	p.mu.Lock()
+	if p.stopping {
+		p.mu.Unlock()
+		listener.Close()
+		return ErrPeerClosed
+	}
+	p.listeners[name] = listener
+	if t == p.transport {
+		p.listener = listener
+		if p.Port == "0" && addr == net.JoinHostPort(p.Host, p.Port) {
+			if _, port, err := net.SplitHostPort(listener.Addr().String()); err == nil {
+				p.Port = port
+			}
+		}
 	}
-	p.Listener = listener
-	log.Printf("TCP-сервер запущен на %s", p.Addr())
-	go p.acceptIncomingConnections()
+	p.track(func() { p.acceptIncomingConnections(name, listener) })
+	p.mu.Unlock()
+	p.Logger().Info("сервер запущен", "transport", name, "addr", listener.Addr().String())
+	return nil
 }
 
-// ConnectToPeers устанавливает соединения с несколькими узлами.
-// Для каждого адреса вызывается ConnectToPeer.
+// ListenAddrs возвращает адреса запущенных слушателей по именам транспортов.
+func (p *Peer) ListenAddrs() map[string]net.Addr {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	addrs := make(map[string]net.Addr, len(p.listeners))
+	for name, l := range p.listeners {
+		addrs[name] = l.Addr()
+	}
+	return addrs
+}
+
+// ConnectToPeers устанавливает соединения с несколькими узлами в фоне.
+// Для каждого адреса вызывается ConnectToPeer; ошибки только журналируются.
 func (p *Peer) ConnectToPeers(addresses ...string) {
 	for _, addr := range addresses {
-		go p.ConnectToPeer(addr)
+		go func(addr string) {
+			if err := p.ConnectToPeer(context.Background(), addr); err != nil {
+				p.Logger().Warn("не удалось подключиться к узлу", "remote_addr", addr, "err", err)
+			}
+		}(addr)
 	}
 }
 
-// ConnectToPeer пытается подключиться к удалённому узлу по указанному адресу.
-// Если соединение не удаётся, попытки повторяются каждые 5 секунд.
-func (p *Peer) ConnectToPeer(address string) {
-	for {
-		if _, exists := p.Connections.Load(address); exists {
-			return // Уже подключены к этому узлу
-		}
-		conn, err := net.Dial("tcp", address)
-		if err != nil {
-			log.Printf("%s.ConnectToPeer: не удалось подключиться к %s: %v", p.Addr(), address, err)
-			log.Printf("Повторная попытка подключения к %s через 5 секунд...", address)
-			time.Sleep(5 * time.Second)
-			continue
-		}
-		p.registerConnection(address, conn)
-		break
+// ConnectToPeer добавляет узел в список желаемых и ждёт первого успешного подключения.
+// Адрес вида "scheme://addr" устанавливается через транспорт scheme, адрес без схемы - по TCP.
+// Неудачные попытки и потерянные соединения повторяются по политике ReconnectPolicy,
+// пока не отменён ctx, не вызван ForgetPeer или не исчерпаны попытки.
+// Возвращает ошибку, если узел так и не удалось подключить.
+func (p *Peer) ConnectToPeer(ctx context.Context, address string) error {
+	return p.reconnect.add(ctx, address)
+}
+
+// dial выполняет одну попытку подключения к узлу и регистрирует соединение.
+func (p *Peer) dial(ctx context.Context, address string) (*connection.Connection, error) {
+	if existing, ok := p.Connections.Load(connKey(address)); ok {
+		return existing.(*connection.Connection), nil // Уже подключены к этому узлу
+	}
+
+	scheme, addr := transport.SplitAddr(address)
+	t, ok := p.Transport(scheme)
+	if !ok {
+		return nil, fmt.Errorf("транспорт %q не поддерживается", scheme)
+	}
+	conn, err := t.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.registerConnection(address, conn, true)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-c.Done():
+		return nil, errConnClosed
+	default:
+		return c, nil
 	}
 }
 
 // acceptIncomingConnections обрабатывает входящие соединения от других узлов.
 // Каждое соединение обрабатывается в отдельной горутине.
-func (p *Peer) acceptIncomingConnections() {
+func (p *Peer) acceptIncomingConnections(name string, listener net.Listener) {
 	for {
-		conn, err := p.Listener.Accept()
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
-			log.Printf("%s.acceptIncomingConnections: не удалось принять соединение: %v", p.Addr(), err)
+			p.Logger().Error("не удалось принять соединение", "transport", name, "err", err)
 			continue
 		}
-		log.Printf("Входящее соединение от %s", conn.RemoteAddr().String())
-		p.registerConnection(conn.RemoteAddr().String(), conn)
+		address := transport.JoinAddr(name, conn.RemoteAddr().String())
+		p.Logger().Debug("входящее соединение", "remote_addr", address)
+		if _, err := p.registerConnection(address, conn, false); err != nil {
+			p.Logger().Warn("входящее соединение отклонено", "remote_addr", address, "err", err)
+		}
 	}
 }
 
 // registerConnection регистрирует новое соединение с удалённым узлом.
 // Добавляет соединение в список активных и запускает горутину для его обработки.
-func (p *Peer) registerConnection(address string, conn net.Conn) {
+// outbound указывает, что соединение установлено локальным узлом.
+// Вызывается только из горутин узла; после начала остановки соединения отклоняются.
+func (p *Peer) registerConnection(address string, conn net.Conn, outbound bool) (*connection.Connection, error) {
+	if p.ctx.Err() != nil {
+		conn.Close()
+		return nil, ErrPeerClosed
+	}
+	p.mu.RLock()
+	compression := p.compression
+	p.mu.RUnlock()
 	info := message.Message{
-		Type:   "info",
-		Sender: p.Username,
+		Type:        "info",
+		Sender:      p.Username,
+		Transports:  p.TransportNames(),
+		PeerID:      p.ID,
+		Compression: compression,
+	}
+	c := connection.NewConnection(conn, outbound, info, p.Logger())
+	c.SetThrottle(p.Throttle)
+	c.SetMessageTypes(p.knownMessageType)
+	p.mu.RLock()
+	c.SetQueuePolicy(p.queuePolicy)
+	p.mu.RUnlock()
+	p.metrics.add(c)
+	key := connKey(address)
+	p.Connections.Store(key, c)
+	if p.ctx.Err() != nil {
+		// Shutdown мог обойти Connections до Store: он не попрощается с этим соединением
+		// и ждал бы его горутин бесконечно
+		c.Close()
 	}
-	c := connection.NewConnection(conn, info)
-	p.Store(address, c)
-	log.Printf("Подключение к узлу %s установлено", address)
-	go p.handleConnection(c)
+	c.Logger().Info("соединение установлено", "outbound", outbound)
+	p.track(func() { p.handleConnection(key, c) })
+	return c, nil
 }
 
 // handleConnection управляет взаимодействием с удалённым узлом.
 // Читает сообщения от узла, логирует или обрабатывает их.
-// При закрытии соединения оно удаляется из списка активных.
-func (p *Peer) handleConnection(conn *connection.Connection) {
+// При закрытии соединения оно удаляется из списка активных по ключу key.
+func (p *Peer) handleConnection(key string, conn *connection.Connection) {
 	defer func() {
 		conn.Close()
-		log.Printf("%s.handleConnection: Соединение с %s удалено", p.Addr(), conn.Addr())
-		p.Connections.Delete(conn.Addr())
-		log.Printf("%s.handleConnection: Соединение с %s закрыто", p.Addr(), conn.Addr())
+		p.Connections.CompareAndDelete(key, conn)
+		p.metrics.retire(conn)
+		p.unbindPeer(conn)
+		p.Throttle.Forget(conn.ThrottleKey())
+		p.announceDisconnected(conn)
+		conn.Logger().Info("соединение закрыто")
 	}()
 
-	scanner := bufio.NewScanner(conn.Conn)
 	for {
-		// Устанавливаем дедлайн для предотвращения бесконечного ожидания
-		conn.Conn.SetReadDeadline(time.Now().Add(connReadDeadline))
-
-		if !scanner.Scan() {
-			break // EOF или таймаут завершают соединение
-		}
-
-		line := scanner.Text()
-		msg, err := message.MessageFromString(line)
+		stream, err := conn.AcceptStream()
 		if err != nil {
-			log.Printf("Ошибка при разборе сообщения: %v", err)
-			continue
+			break // Соединение закрыто
 		}
+		p.track(func() { p.handleStream(conn, stream) })
+	}
+}
+
+// handleStream читает сообщения из входящего потока и обрабатывает их.
+// Завершение управляющего потока означает завершение соединения.
+func (p *Peer) handleStream(conn *connection.Connection, stream *connection.Stream) {
+	defer stream.Close()
+	if stream.Kind == connection.StreamControl {
+		defer conn.Close()
+	}
 
-		// Обработка разных типов сообщений
-		switch msg.Type {
-		case "info":
-			log.Printf("%s: Информация о соединении: %s", conn.Addr(), msg.Sender)
-			conn.SetUsername(msg.Sender)
-		case "heartbeat":
+	for {
+		msg, err := stream.Receive()
+		if errors.Is(err, connection.ErrBadMessage) {
+			conn.Logger().Warn("ошибка при разборе сообщения", "stream", stream.Kind, "err", err)
 			continue
-		case "text":
-			log.Printf("[От %s]: %s", msg.Sender, msg.Content)
-			conn.AddMessages(*msg)
-		case "log":
-			p.log = append(p.log, *msg)
 		}
+		if err != nil {
+			if err != io.EOF {
+				conn.Logger().Warn("ошибка при чтении из потока", "stream", stream.Kind, "err", err)
+			}
+			return // EOF или таймаут завершают поток
+		}
+		if conn.Resolve(msg) {
+			continue // Ответ передан ожидающему запросу
+		}
+		p.dispatch(conn, msg)
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Printf("%s.handleConnection: ошибка при чтении из соединения: %v", conn.Addr(), err)
+// SetCompression включает или отключает сжатие передаваемых файлов для новых соединений.
+// Сжатие используется, только если его поддерживают обе стороны.
+func (p *Peer) SetCompression(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if enabled {
+		p.compression = connection.SupportedCompressions
+	} else {
+		p.compression = nil
 	}
 }
 
+// SetQueuePolicy задаёт политику очереди отправки для текущих и новых соединений.
+func (p *Peer) SetQueuePolicy(policy connection.QueuePolicy) {
+	p.mu.Lock()
+	p.queuePolicy = policy
+	p.mu.Unlock()
+	p.Connections.Range(func(_, value any) bool {
+		value.(*connection.Connection).SetQueuePolicy(policy)
+		return true
+	})
+}
+
 // SendMessageToPeers отправляет текстовое сообщение всем подключённым узлам.
+// Сообщения ставятся в очереди соединений, поэтому медленный узел не задерживает остальных
+// (кроме политики QueueBlock при заполненной очереди).
 func (p *Peer) SendMessageToPeers(text string) {
-	var wg sync.WaitGroup
 	p.Connections.Range(func(_, value any) bool {
-		wg.Add(1)
-		go p.SendMessageToPeer(value.(*connection.Connection), text, &wg)
+		p.SendMessageToPeer(value.(*connection.Connection), text, nil)
 		return true
 	})
-	wg.Wait()
 }
 
 // SendMessageToPeer отправляет текстовое сообщение конкретному узлу.
@@ -199,25 +463,54 @@ func (p *Peer) SendMessageToPeer(conn *connection.Connection, text string, wg *s
 	}
 
 	if err := conn.Send(msg); err != nil {
-		if conn.IsClosed {
-			log.Printf("%s.SendMessageToPeer: Не удалось отправить сообщение узлу %s: закрыто", p.Addr(), conn.Addr())
+		if conn.IsClosed() {
+			conn.Logger().Warn("не удалось отправить сообщение: соединение закрыто", "msg_type", msg.Type)
 			p.Connections.Range(func(key, value any) bool {
-				log.Println(key, value.(*connection.Connection).Addr())
+				p.Logger().Debug("активное соединение", "key", key, "remote_addr", value.(*connection.Connection).Addr())
 				return true
 			})
 		}
-		log.Printf("%s.SendMessageToPeer: Не удалось отправить сообщение узлу %s: %v", p.Addr(), conn.Addr(), err)
+		conn.Logger().Warn("не удалось отправить сообщение", "msg_type", msg.Type, "err", err)
 	}
 }
 
+// Log возвращает копию журнала сообщений.
 func (p *Peer) Log() []message.Message {
-	return p.log
+	p.logMu.Lock()
+	defer p.logMu.Unlock()
+	return slices.Clone(p.log)
+}
+
+// Connection находит активное соединение по адресу, идентификатору или имени пользователя узла.
+func (p *Peer) Connection(name string) (*connection.Connection, bool) {
+	_, conn, ok := p.lookup(name)
+	return conn, ok
+}
+
+// lookup находит активное соединение и его ключ в Connections.
+func (p *Peer) lookup(name string) (key string, conn *connection.Connection, ok bool) {
+	if value, found := p.Connections.Load(connKey(name)); found {
+		return connKey(name), value.(*connection.Connection), true
+	}
+	p.Connections.Range(func(k, value any) bool {
+		c := value.(*connection.Connection)
+		if c.Addr() == name || (c.PeerID() != "" && c.PeerID() == name) || c.Username() == name {
+			key, conn, ok = k.(string), c, true
+			return false
+		}
+		return true
+	})
+	return key, conn, ok
 }
 
 func (p *Peer) Store(key string, value *connection.Connection) {
+	p.Connections.Store(connKey(key), value)
+}
+
+// connKey приводит адрес к ключу в списке активных соединений.
+func connKey(key string) string {
 	if host, port, _ := net.SplitHostPort(key); host == "localhost" {
-		p.Connections.Store("127.0.0.1:"+port, value) // TODO: хардкод
-		return
+		return "127.0.0.1:" + port // TODO: хардкод
 	}
-	p.Connections.Store(key, value)
+	return key
 }
@@ -0,0 +1,46 @@
+package peer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+// newTestPeer создаёт и запускает узел name в сети mem. Узел останавливается по завершении теста.
+func newTestPeer(t *testing.T, mem *transport.Memory, name string) *Peer {
+	t.Helper()
+	return peertest.Start(t, NewPeer(name, name, "1", mem))
+}
+
+// memAddr возвращает адрес узла для ConnectToPeer.
+func memAddr(p *Peer) string {
+	return transport.JoinAddr("mem", p.Addr())
+}
+
+// connect подключает a к b и возвращает соединение на стороне a после рукопожатия.
+// Переподключения продолжаются до остановки узла.
+func connect(t *testing.T, a, b *Peer) *connection.Connection {
+	t.Helper()
+	if err := a.ConnectToPeer(context.Background(), memAddr(b)); err != nil {
+		t.Fatal(err)
+	}
+	peertest.Eventually(t, "рукопожатие", func() bool {
+		return a.liveConnection(b.ID) != nil && b.liveConnection(a.ID) != nil
+	})
+	return a.liveConnection(b.ID)
+}
+
+// openConnections возвращает число открытых соединений узла.
+func openConnections(p *Peer) int {
+	n := 0
+	p.Connections.Range(func(_, value any) bool {
+		if !value.(*connection.Connection).IsClosed() {
+			n++
+		}
+		return true
+	})
+	return n
+}
@@ -0,0 +1,53 @@
+// Пакет peertest содержит общие для тестов средства запуска узлов.
+// Пакет не зависит от peer, поэтому им пользуются и внутренние тесты peer.
+package peertest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// WaitTimeout - предельное время ожидания событий в тестах
+const WaitTimeout = 5 * time.Second
+
+// Discard - журнал, отбрасывающий записи
+var Discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// Node - запускаемый в тесте узел.
+type Node interface {
+	SetLogger(logger *slog.Logger)
+	Start(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// Start запускает узел n без журнала и останавливает его по завершении теста.
+func Start[N Node](t testing.TB, n N) N {
+	t.Helper()
+	n.SetLogger(Discard)
+	if err := n.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), WaitTimeout)
+		defer cancel()
+		if err := n.Shutdown(ctx); err != nil {
+			t.Errorf("остановка узла: %v", err)
+		}
+	})
+	return n
+}
+
+// Eventually ждёт выполнения cond не дольше WaitTimeout.
+func Eventually(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(WaitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,233 @@
+// Пакет ratelimit ограничивает скорость передачи данных алгоритмом token bucket.
+// Лимиты задаются глобально и для отдельных узлов и могут меняться во время работы.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minBurst - минимальный объём корзины в байтах, чтобы не дробить крупные блоки
+	minBurst = 64 * 1024
+	// maxSleep - максимальный интервал ожидания, после которого лимит перечитывается
+	maxSleep = 100 * time.Millisecond
+)
+
+// Limiter - корзина токенов, ограничивающая поток байт в секунду.
+// Нулевая скорость означает отсутствие ограничения.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // Байт в секунду
+	burst  float64 // Объём корзины
+	tokens float64 // Доступные токены (может уходить в минус после крупного блока)
+	last   time.Time
+}
+
+// NewLimiter создаёт ограничитель со скоростью rate байт/с (0 - без ограничения).
+func NewLimiter(rate int64) *Limiter {
+	l := &Limiter{last: time.Now()}
+	l.SetRate(rate)
+	return l
+}
+
+// SetRate изменяет скорость ограничителя. Ожидающие вызовы WaitN подхватывают
+// новое значение не позднее чем через maxSleep.
+func (l *Limiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.rate = float64(max(rate, 0))
+	l.burst = math.Max(l.rate, minBurst)
+	l.tokens = math.Min(l.tokens, l.burst)
+}
+
+// Rate возвращает текущую скорость в байтах в секунду.
+func (l *Limiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.rate)
+}
+
+// WaitN ожидает, пока можно будет передать n байт.
+// Блок больше объёма корзины пропускается целиком, а долг гасится следующими вызовами.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	for {
+		l.mu.Lock()
+		if l.rate <= 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		now := time.Now()
+		l.refill(now)
+		if l.tokens > 0 {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(min(max(wait, time.Millisecond), maxSleep))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// refill пополняет корзину за время, прошедшее с последнего вызова. Вызывается под mu.
+func (l *Limiter) refill(now time.Time) {
+	if l.rate > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+}
+
+// Throttle объединяет глобальные лимиты отдачи и приёма с лимитами для отдельных узлов.
+type Throttle struct {
+	upload   *Limiter // Глобальный лимит отдачи
+	download *Limiter // Глобальный лимит приёма
+
+	mu          sync.Mutex
+	peerUp      map[string]*Limiter
+	peerDown    map[string]*Limiter
+	defaultUp   int64 // Лимит отдачи для узлов без собственного лимита
+	defaultDown int64 // Лимит приёма для узлов без собственного лимита
+	custom      map[string]bool
+}
+
+// NewThrottle создаёт набор лимитов без ограничений.
+func NewThrottle() *Throttle {
+	return &Throttle{
+		upload:   NewLimiter(0),
+		download: NewLimiter(0),
+		peerUp:   make(map[string]*Limiter),
+		peerDown: make(map[string]*Limiter),
+		custom:   make(map[string]bool),
+	}
+}
+
+// SetGlobal задаёт общие лимиты отдачи и приёма в байтах в секунду.
+func (t *Throttle) SetGlobal(up, down int64) {
+	t.upload.SetRate(up)
+	t.download.SetRate(down)
+}
+
+// Global возвращает общие лимиты отдачи и приёма.
+func (t *Throttle) Global() (up, down int64) {
+	return t.upload.Rate(), t.download.Rate()
+}
+
+// SetPeerDefault задаёт лимиты для узлов, у которых нет собственных лимитов.
+func (t *Throttle) SetPeerDefault(up, down int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.defaultUp, t.defaultDown = up, down
+	for peer, l := range t.peerUp {
+		if !t.custom[peer] {
+			l.SetRate(up)
+			t.peerDown[peer].SetRate(down)
+		}
+	}
+}
+
+// SetPeer задаёт собственные лимиты для узла peer.
+func (t *Throttle) SetPeer(peer string, up, down int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.custom[peer] = true
+	upL, downL := t.limiters(peer)
+	upL.SetRate(up)
+	downL.SetRate(down)
+}
+
+// Peer возвращает лимиты отдачи и приёма, действующие для узла peer.
+func (t *Throttle) Peer(peer string) (up, down int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l, ok := t.peerUp[peer]; ok {
+		return l.Rate(), t.peerDown[peer].Rate()
+	}
+	return t.defaultUp, t.defaultDown
+}
+
+// Forget удаляет лимиты узла, установленные по умолчанию. Собственные лимиты сохраняются.
+func (t *Throttle) Forget(peer string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.custom[peer] {
+		delete(t.peerUp, peer)
+		delete(t.peerDown, peer)
+	}
+}
+
+// WaitUpload ожидает возможности отправить n байт узлу peer.
+func (t *Throttle) WaitUpload(ctx context.Context, peer string, n int) error {
+	t.mu.Lock()
+	l, _ := t.limiters(peer)
+	t.mu.Unlock()
+	if err := l.WaitN(ctx, n); err != nil {
+		return err
+	}
+	return t.upload.WaitN(ctx, n)
+}
+
+// WaitDownload ожидает возможности принять n байт от узла peer.
+func (t *Throttle) WaitDownload(ctx context.Context, peer string, n int) error {
+	t.mu.Lock()
+	_, l := t.limiters(peer)
+	t.mu.Unlock()
+	if err := l.WaitN(ctx, n); err != nil {
+		return err
+	}
+	return t.download.WaitN(ctx, n)
+}
+
+// limiters возвращает ограничители узла, создавая их при необходимости. Вызывается под mu.
+func (t *Throttle) limiters(peer string) (up, down *Limiter) {
+	up, ok := t.peerUp[peer]
+	if !ok {
+		up = NewLimiter(t.defaultUp)
+		t.peerUp[peer] = up
+		t.peerDown[peer] = NewLimiter(t.defaultDown)
+	}
+	return up, t.peerDown[peer]
+}
+
+// ParseRate разбирает скорость вида "512K", "10M" или "1G" (байт в секунду,
+// множители двоичные). "0", "off" и "unlimited" означают отсутствие ограничения.
+func ParseRate(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	switch s {
+	case "", "0", "OFF", "UNLIMITED":
+		return 0, nil
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "/S"), "B")
+
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("ratelimit: некорректная скорость %q", s)
+	}
+	return int64(v * float64(mult)), nil
+}
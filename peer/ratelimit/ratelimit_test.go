@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"off", 0, false},
+		{"Unlimited", 0, false},
+		{"1024", 1024, false},
+		{"512K", 512 << 10, false},
+		{"512k", 512 << 10, false},
+		{"10M", 10 << 20, false},
+		{"1G", 1 << 30, false},
+		{"1.5M", 3 << 19, false},
+		{"2MB", 2 << 20, false},
+		{"2MB/s", 2 << 20, false},
+		{" 64K ", 64 << 10, false},
+		{"-1K", 0, true},
+		{"fast", 0, true},
+		{"10X", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate(%q): ошибка %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseRate(%q) = %d, ожидалось %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	const rate = 1 << 20 // 1 МиБ/с, корзина тоже 1 МиБ
+	l := NewLimiter(rate)
+	ctx := context.Background()
+
+	// Полная корзина пропускает первый мегабайт сразу, второй ждёт около секунды
+	start := time.Now()
+	for i := 0; i < 32; i++ {
+		if err := l.WaitN(ctx, 64<<10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	if elapsed < 700*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("2 МиБ при 1 МиБ/с переданы за %s", elapsed)
+	}
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l := NewLimiter(0)
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		if err := l.WaitN(context.Background(), 1<<20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("ограничитель без лимита ждал %s", elapsed)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l := NewLimiter(1024)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	l.WaitN(ctx, minBurst+1<<20) // Уводит корзину в долг
+	if err := l.WaitN(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitN: %v, ожидалась отмена по ctx", err)
+	}
+}
+
+func TestLimiterSetRateWakesWaiters(t *testing.T) {
+	l := NewLimiter(1)
+	l.WaitN(context.Background(), minBurst+1<<20) // Долг, который при 1 байт/с не погасить
+
+	done := make(chan error, 1)
+	go func() { done <- l.WaitN(context.Background(), 1) }()
+	time.Sleep(20 * time.Millisecond)
+	l.SetRate(0)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("снятие лимита не разбудило ожидающий вызов")
+	}
+}
+
+func TestThrottlePeerLimits(t *testing.T) {
+	th := NewThrottle()
+	th.SetPeerDefault(100, 200)
+
+	tests := []struct {
+		name             string
+		setup            func()
+		peer             string
+		wantUp, wantDown int64
+	}{
+		{"лимит по умолчанию", func() {}, "a", 100, 200},
+		{"собственный лимит", func() { th.SetPeer("b", 10, 20) }, "b", 10, 20},
+		{"смена умолчания не трогает собственный", func() { th.SetPeerDefault(300, 400) }, "b", 10, 20},
+		{"смена умолчания для созданного узла", func() { th.WaitUpload(context.Background(), "c", 1) }, "c", 300, 400},
+		{"Forget сохраняет собственный", func() { th.Forget("b") }, "b", 10, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			up, down := th.Peer(tt.peer)
+			if up != tt.wantUp || down != tt.wantDown {
+				t.Fatalf("Peer(%q) = %d, %d; ожидалось %d, %d", tt.peer, up, down, tt.wantUp, tt.wantDown)
+			}
+		})
+	}
+
+	th.Forget("c")
+	th.mu.Lock()
+	_, ok := th.peerUp["c"]
+	th.mu.Unlock()
+	if ok {
+		t.Fatal("Forget не удалил лимиты по умолчанию")
+	}
+}
+
+func TestThrottleGlobal(t *testing.T) {
+	th := NewThrottle()
+	th.SetGlobal(1<<20, 2<<20)
+	if up, down := th.Global(); up != 1<<20 || down != 2<<20 {
+		t.Fatalf("Global() = %d, %d", up, down)
+	}
+}
@@ -0,0 +1,350 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+)
+
+// dialTimeout - максимальная длительность одной попытки подключения
+const dialTimeout = 10 * time.Second
+
+// errPeerForgotten - узел удалён из списка желаемых
+var errPeerForgotten = errors.New("узел удалён из списка подключений")
+
+// ErrUnknownPeer - соединение с узлом не найдено
+var ErrUnknownPeer = errors.New("peer: узел не найден")
+
+// PeerState - состояние подключения к желаемому узлу.
+type PeerState int
+
+const (
+	PeerConnecting PeerState = iota // Идёт попытка подключения
+	PeerConnected                   // Соединение установлено
+	PeerBackoff                     // Ожидание перед следующей попыткой
+	PeerFailed                      // Попытки исчерпаны или подключение отменено
+)
+
+// String возвращает название состояния.
+func (s PeerState) String() string {
+	switch s {
+	case PeerConnecting:
+		return "connecting"
+	case PeerConnected:
+		return "connected"
+	case PeerBackoff:
+		return "backoff"
+	case PeerFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// PeerStatus - снимок состояния подключения к желаемому узлу.
+type PeerStatus struct {
+	Address     string    // Адрес узла
+	State       PeerState // Текущее состояние
+	Attempts    int       // Число неудачных попыток подряд
+	NextAttempt time.Time // Время следующей попытки (для PeerBackoff)
+	LastError   error     // Последняя ошибка подключения
+}
+
+// ReconnectPolicy задаёт повторные попытки подключения с экспоненциальной задержкой.
+type ReconnectPolicy struct {
+	InitialDelay time.Duration // Задержка перед второй попыткой
+	MaxDelay     time.Duration // Максимальная задержка
+	Multiplier   float64       // Множитель задержки после каждой неудачи
+	Jitter       float64       // Случайное отклонение задержки, доля от 0 до 1
+	MaxAttempts  int           // Число неудачных попыток подряд до отказа (0 - без ограничения)
+}
+
+// DefaultReconnectPolicy - политика повторных подключений по умолчанию.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialDelay: time.Second,
+	MaxDelay:     2 * time.Minute,
+	Multiplier:   2,
+	Jitter:       0.2,
+	MaxAttempts:  0,
+}
+
+// delay возвращает задержку после attempt неудачных попыток подряд.
+func (rp ReconnectPolicy) delay(attempt int) time.Duration {
+	d := float64(rp.InitialDelay) * math.Pow(max(rp.Multiplier, 1), float64(attempt-1))
+	d = math.Min(d, float64(rp.MaxDelay))
+	if rp.Jitter > 0 {
+		d += d * rp.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(max(d, 0))
+}
+
+// SetReconnectPolicy изменяет политику повторных подключений для новых попыток.
+func (p *Peer) SetReconnectPolicy(policy ReconnectPolicy) {
+	p.reconnect.mu.Lock()
+	p.reconnect.policy = policy
+	p.reconnect.mu.Unlock()
+}
+
+// ForgetPeer прекращает поддерживать соединение с узлом. Текущее соединение не закрывается.
+func (p *Peer) ForgetPeer(address string) {
+	p.reconnect.remove(address)
+}
+
+// DisconnectPeer прекращает поддерживать соединение с узлом и закрывает текущее
+// соединение, предупредив узел сообщением "bye". name - адрес, идентификатор
+// или имя пользователя узла. Возвращает ErrUnknownPeer, если соединения нет.
+func (p *Peer) DisconnectPeer(ctx context.Context, name string) error {
+	p.ForgetPeer(name)
+	key, conn, ok := p.lookup(name)
+	if !ok {
+		return ErrUnknownPeer
+	}
+	for _, st := range p.PeerStates() {
+		if connKey(st.Address) == key {
+			p.ForgetPeer(st.Address)
+		}
+	}
+	p.sayBye(ctx, conn)
+	return nil
+}
+
+// PeerStates возвращает состояния всех желаемых узлов, упорядоченные по адресу.
+func (p *Peer) PeerStates() []PeerStatus {
+	return p.reconnect.states()
+}
+
+// reconnector поддерживает соединения с желаемыми узлами.
+type reconnector struct {
+	peer *Peer
+
+	mu      sync.Mutex
+	policy  ReconnectPolicy
+	targets map[string]*target
+
+	retries  atomic.Uint64 // Повторные попытки подключения (все, кроме первой)
+	failures atomic.Uint64 // Неудачные попытки подключения
+}
+
+// target - желаемый узел.
+type target struct {
+	cancel context.CancelFunc
+	status PeerStatus
+}
+
+func newReconnector(p *Peer, policy ReconnectPolicy) *reconnector {
+	return &reconnector{
+		peer:    p,
+		policy:  policy,
+		targets: make(map[string]*target),
+	}
+}
+
+// add начинает поддерживать соединение с address и ждёт первого подключения.
+// Если узел уже в списке, ожидание относится к уже идущим попыткам.
+func (r *reconnector) add(ctx context.Context, address string) error {
+	first := make(chan error, 1)
+
+	r.mu.Lock()
+	if t, ok := r.targets[address]; ok && t.status.State != PeerFailed {
+		r.mu.Unlock()
+		return r.waitConnected(ctx, address)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r.targets[address] = &target{
+		cancel: cancel,
+		status: PeerStatus{Address: address, State: PeerConnecting},
+	}
+	r.mu.Unlock()
+
+	started := r.peer.spawn(func() {
+		defer context.AfterFunc(r.peer.ctx, cancel)() // Остановка узла прекращает переподключения
+		r.run(ctx, address, first)
+	})
+	if !started {
+		r.remove(address)
+		return ErrPeerClosed
+	}
+	return <-first
+}
+
+// waitConnected ждёт, пока уже отслеживаемый узел подключится или окончательно откажет.
+func (r *reconnector) waitConnected(ctx context.Context, address string) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		t, ok := r.targets[address]
+		var status PeerStatus
+		if ok {
+			status = t.status
+		}
+		r.mu.Unlock()
+
+		switch {
+		case !ok:
+			return errPeerForgotten
+		case status.State == PeerConnected:
+			return nil
+		case status.State == PeerFailed:
+			return status.LastError
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// remove прекращает попытки подключения к address.
+func (r *reconnector) remove(address string) {
+	r.mu.Lock()
+	t, ok := r.targets[address]
+	delete(r.targets, address)
+	r.mu.Unlock()
+	if ok {
+		t.cancel()
+	}
+}
+
+// run подключается к узлу, следит за соединением и переподключается после его потери.
+// Результат первой попытки (успех или окончательный отказ) отправляется в first.
+func (r *reconnector) run(ctx context.Context, address string, first chan<- error) {
+	report := func(err error) {
+		select {
+		case first <- err:
+		default:
+		}
+	}
+
+	attempts := 0
+	for retry := false; ; retry = true {
+		if retry {
+			r.retries.Add(1)
+		}
+		r.update(address, func(s *PeerStatus) { s.State = PeerConnecting })
+
+		dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+		conn, err := r.peer.dial(dialCtx, address)
+		cancel()
+
+		if err == nil {
+			attempts = 0
+			r.update(address, func(s *PeerStatus) {
+				s.State, s.Attempts, s.LastError = PeerConnected, 0, nil
+				s.NextAttempt = time.Time{}
+			})
+			report(nil)
+			if !r.watch(ctx, conn) {
+				r.fail(address, ctx.Err())
+				report(ctx.Err())
+				return
+			}
+			r.peer.Logger().Warn("соединение потеряно", "remote_addr", address)
+			err = errConnClosed
+		} else if ctx.Err() != nil {
+			r.fail(address, ctx.Err())
+			report(ctx.Err())
+			return
+		} else {
+			r.failures.Add(1)
+			r.peer.Logger().Warn("не удалось подключиться", "remote_addr", address, "err", err)
+		}
+
+		attempts++
+		r.mu.Lock()
+		policy := r.policy
+		r.mu.Unlock()
+		if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+			r.peer.Logger().Error("подключение прекращено", "remote_addr", address, "attempts", attempts)
+			r.update(address, func(s *PeerStatus) { s.Attempts = attempts })
+			r.fail(address, err)
+			report(err)
+			return
+		}
+
+		delay := policy.delay(attempts)
+		r.update(address, func(s *PeerStatus) {
+			s.State, s.Attempts, s.LastError = PeerBackoff, attempts, err
+			s.NextAttempt = time.Now().Add(delay)
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			r.fail(address, ctx.Err())
+			report(ctx.Err())
+			return
+		}
+	}
+}
+
+// watch ждёт потери соединения с узлом. Если соединение закрыто как дубликат,
+// наблюдение переходит на оставшееся соединение с тем же узлом.
+// Возвращает false, если ожидание прервано отменой ctx.
+func (r *reconnector) watch(ctx context.Context, conn *connection.Connection) bool {
+	for conn != nil {
+		select {
+		case <-conn.Done():
+		case <-ctx.Done():
+			return false
+		}
+		conn = r.peer.liveConnection(conn.PeerID())
+	}
+	return true
+}
+
+// update изменяет состояние узла, если он ещё в списке желаемых.
+func (r *reconnector) update(address string, fn func(*PeerStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.targets[address]; ok {
+		fn(&t.status)
+	}
+}
+
+// fail переводит узел в состояние PeerFailed.
+func (r *reconnector) fail(address string, err error) {
+	r.update(address, func(s *PeerStatus) {
+		s.State, s.LastError, s.NextAttempt = PeerFailed, err, time.Time{}
+	})
+}
+
+// states возвращает снимок состояний желаемых узлов.
+func (r *reconnector) states() []PeerStatus {
+	r.mu.Lock()
+	list := make([]PeerStatus, 0, len(r.targets))
+	for _, t := range r.targets {
+		list = append(list, t.status)
+	}
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Address < list[j].Address })
+	return list
+}
+
+// liveConnection возвращает открытое соединение с узлом id, если оно есть.
+func (p *Peer) liveConnection(id string) *connection.Connection {
+	if id == "" {
+		return nil
+	}
+	p.peersMu.Lock()
+	conn := p.peers[id]
+	p.peersMu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	select {
+	case <-conn.Done():
+		return nil
+	default:
+		return conn
+	}
+}
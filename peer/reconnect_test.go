@@ -0,0 +1,99 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	policy := ReconnectPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+	tests := []struct {
+		name    string
+		policy  ReconnectPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"первая неудача", policy, 1, 100 * time.Millisecond},
+		{"вторая неудача", policy, 2, 200 * time.Millisecond},
+		{"четвёртая неудача", policy, 4, 800 * time.Millisecond},
+		{"ограничение MaxDelay", policy, 5, time.Second},
+		{"много неудач", policy, 100, time.Second},
+		{"множитель меньше 1", ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 0.5}, 3, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.delay(tt.attempt); got != tt.want {
+				t.Fatalf("delay(%d) = %s, ожидалось %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconnectDelayJitter(t *testing.T) {
+	policy := ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		if d := policy.delay(1); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("задержка %s вне диапазона отклонения", d)
+		}
+	}
+}
+
+func TestReconnectMaxAttempts(t *testing.T) {
+	mem := transport.NewMemory()
+	a := newTestPeer(t, mem, "a")
+	a.SetReconnectPolicy(ReconnectPolicy{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1, MaxAttempts: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), peertest.WaitTimeout)
+	defer cancel()
+	if err := a.ConnectToPeer(ctx, "mem://nobody:1"); err == nil {
+		t.Fatal("подключение к несуществующему узлу удалось")
+	}
+	states := a.PeerStates()
+	if len(states) != 1 || states[0].State != PeerFailed || states[0].Attempts != 3 || states[0].LastError == nil {
+		t.Fatalf("состояния %+v", states)
+	}
+	if retries, failures := a.reconnect.retries.Load(), a.reconnect.failures.Load(); retries != 2 || failures != 3 {
+		t.Fatalf("повторов %d, неудач %d; ожидалось 2 и 3", retries, failures)
+	}
+}
+
+func TestReconnectAfterLoss(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	a.SetReconnectPolicy(ReconnectPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 1})
+	first := connect(t, a, b)
+
+	b.liveConnection(a.ID).Close()
+	peertest.Eventually(t, "переподключение", func() bool {
+		conn := a.liveConnection(b.ID)
+		return conn != nil && conn != first && b.liveConnection(a.ID) != nil
+	})
+	if st := a.PeerStates(); len(st) != 1 || st[0].State != PeerConnected {
+		t.Fatalf("состояния %+v", st)
+	}
+}
+
+func TestForgetPeer(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	a.SetReconnectPolicy(ReconnectPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Multiplier: 1})
+	connect(t, a, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), peertest.WaitTimeout)
+	defer cancel()
+	if err := a.DisconnectPeer(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	peertest.Eventually(t, "закрытие соединения", func() bool { return openConnections(a) == 0 })
+	time.Sleep(50 * time.Millisecond) // Несколько задержек переподключения
+	if n := openConnections(a); n != 0 || len(a.PeerStates()) != 0 {
+		t.Fatalf("после DisconnectPeer соединений %d, желаемых узлов %v", n, a.PeerStates())
+	}
+	if err := a.DisconnectPeer(ctx, "b"); err != ErrUnknownPeer {
+		t.Fatalf("повторный DisconnectPeer: %v", err)
+	}
+}
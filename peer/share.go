@@ -0,0 +1,225 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/message"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+	"github.com/WhiCu/p2pFileShare/transfer"
+)
+
+// LinkScheme - схема ссылок на файлы, открытые узлом
+const LinkScheme = "p2pfs"
+
+// ErrUnknownShare - файл по ссылке не найден или доступ к нему закрыт
+var ErrUnknownShare = errors.New("peer: файл по ссылке не найден")
+
+// ErrNoPublicAddr - узел слушает только локальный адрес, и ссылка не откроется с другой машины
+var ErrNoPublicAddr = errors.New("peer: нет адреса, доступного другим машинам; задайте внешний адрес узла")
+
+// Link - ссылка на файл, открытый узлом через Share, вида "p2pfs://host:port/id".
+// Для транспорта, отличного от TCP, добавляется параметр transport, например "?transport=quic".
+type Link struct {
+	Address string // Адрес узла в формате ConnectToPeer
+	ID      string // Идентификатор открытого файла
+}
+
+// String возвращает ссылку в текстовом виде.
+func (l Link) String() string {
+	scheme, addr := transport.SplitAddr(l.Address)
+	u := url.URL{Scheme: LinkScheme, Host: addr, Path: "/" + l.ID}
+	if scheme != "tcp" {
+		u.RawQuery = url.Values{"transport": {scheme}}.Encode()
+	}
+	return u.String()
+}
+
+// ParseLink разбирает ссылку вида "p2pfs://host:port/id[?transport=name]".
+func ParseLink(s string) (Link, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Link{}, fmt.Errorf("некорректная ссылка %q: %w", s, err)
+	}
+	id := strings.Trim(u.Path, "/")
+	if u.Scheme != LinkScheme || u.Host == "" || id == "" || strings.Contains(id, "/") {
+		return Link{}, fmt.Errorf("некорректная ссылка %q: ожидается %s://host:port/id", s, LinkScheme)
+	}
+	scheme := u.Query().Get("transport")
+	if scheme == "" {
+		scheme = "tcp"
+	}
+	return Link{Address: transport.JoinAddr(scheme, u.Host), ID: id}, nil
+}
+
+// Share открывает доступ к файлу path по ссылке: узел, получивший ссылку,
+// может запросить файл через RequestFile, и файл будет ему отправлен. В ссылку попадает
+// адрес, доступный с других машин (см. SetAdvertiseAddr); если узел слушает только
+// локальный адрес, возвращается ErrNoPublicAddr.
+func (p *Peer) Share(path string) (Link, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Link{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return Link{}, fmt.Errorf("%s не является файлом", path)
+	}
+	addr, err := p.publicAddr()
+	if err != nil {
+		return Link{}, err
+	}
+	id := newPeerID()
+	p.shared.Store(id, path)
+	return Link{Address: transport.JoinAddr(p.transport.Name(), addr), ID: id}, nil
+}
+
+// publicAddr возвращает адрес основного транспорта, по которому узел доступен с других машин:
+// внешний адрес (SetAdvertiseAddr) или адрес слушателя. Вместо адреса всех интерфейсов
+// подставляется адрес одного из сетевых интерфейсов.
+func (p *Peer) publicAddr() (string, error) {
+	p.mu.RLock()
+	advertise := p.advertise
+	addr := net.JoinHostPort(p.Host, p.Port)
+	if p.listener != nil {
+		addr = p.listener.Addr().String()
+	}
+	p.mu.RUnlock()
+	if advertise == "" {
+		return reachableAddr(addr)
+	}
+	if _, _, err := net.SplitHostPort(advertise); err == nil {
+		return advertise, nil
+	}
+	// Внешний адрес без порта: порт слушателя
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("некорректный адрес узла %s: %w", addr, err)
+	}
+	return net.JoinHostPort(advertise, port), nil
+}
+
+// reachableAddr проверяет, что адрес слушателя addr доступен с других машин.
+// Адрес всех интерфейсов заменяется адресом сетевого интерфейса, а для
+// локального адреса возвращается ErrNoPublicAddr.
+func reachableAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("некорректный адрес узла %s: %w", addr, err)
+	}
+	if strings.EqualFold(host, "localhost") {
+		return "", ErrNoPublicAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return addr, nil // Имя хоста разрешается на стороне получателя ссылки
+	}
+	switch {
+	case ip.IsLoopback():
+		return "", ErrNoPublicAddr
+	case ip.IsUnspecified():
+		ip, ok := interfaceAddr(ip.Is4())
+		if !ok {
+			return "", ErrNoPublicAddr
+		}
+		return net.JoinHostPort(ip.String(), port), nil
+	}
+	return addr, nil
+}
+
+// interfaceAddr возвращает адрес сетевого интерфейса, кроме локальных и link-local;
+// при only4 - только IPv4.
+func interfaceAddr(only4 bool) (netip.Addr, bool) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip, ok := netip.AddrFromSlice(ipnet.IP)
+		if !ok {
+			continue
+		}
+		ip = ip.Unmap()
+		if ip.IsGlobalUnicast() && (!only4 || ip.Is4()) {
+			return ip, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// Unshare закрывает доступ к файлу, открытому через Share.
+func (p *Peer) Unshare(id string) {
+	p.shared.Delete(id)
+}
+
+// Shares возвращает открытые файлы: идентификатор -> путь.
+func (p *Peer) Shares() map[string]string {
+	shares := make(map[string]string)
+	p.shared.Range(func(key, value any) bool {
+		shares[key.(string)] = value.(string)
+		return true
+	})
+	return shares
+}
+
+// RequestFile запрашивает у узла файл, открытый им через Share под идентификатором id.
+// Узел ставит отправку в очередь; возвращается идентификатор передачи, под которым
+// файл будет принят (см. Transfers). Для приёма должен быть задан каталог загрузок.
+func (p *Peer) RequestFile(ctx context.Context, conn *connection.Connection, id string) (string, error) {
+	if p.receiver() == nil {
+		return "", errors.New("каталог загрузок не задан")
+	}
+	reply, err := conn.Request(ctx, message.Message{
+		Type:    "file-request",
+		Sender:  p.Username,
+		Content: id,
+	})
+	if err != nil {
+		return "", err
+	}
+	return downloadID(conn, reply.ID), nil
+}
+
+// handleFileRequest отвечает идентификатором передачи и ставит файл, открытый через Share,
+// в очередь отправки. Контрольная сумма большого файла считается долго, поэтому файл
+// открывается в отдельной горутине, не задерживая чтение управляющего потока (и heartbeat).
+func (p *Peer) handleFileRequest(_ context.Context, conn *connection.Connection, msg *message.Message) {
+	value, ok := p.shared.Load(msg.Content)
+	if !ok {
+		conn.Logger().Warn("запрошен неизвестный файл", "msg_type", msg.Type, "share_id", msg.Content)
+		conn.ReplyError(msg, ErrUnknownShare)
+		return
+	}
+	path := value.(string)
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		conn.Logger().Warn("запрошенный файл недоступен", "msg_type", msg.Type, "share_id", msg.Content, "path", path, "err", err)
+		conn.ReplyError(msg, ErrUnknownShare)
+		return
+	}
+
+	id := transfer.NewID()
+	started := p.spawn(func() {
+		if _, err := p.sendFile(conn, path, transfer.PriorityNormal, id); err != nil {
+			conn.Logger().Warn("не удалось отправить запрошенный файл", "share_id", msg.Content, "transfer_id", id, "err", err)
+		}
+	})
+	if !started {
+		conn.ReplyError(msg, ErrPeerClosed)
+		return
+	}
+	conn.Reply(msg, message.Message{
+		Type:   msg.Type,
+		Sender: p.Username,
+		ID:     id,
+	})
+}
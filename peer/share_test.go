@@ -0,0 +1,132 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/WhiCu/p2pFileShare/peer/connection"
+	"github.com/WhiCu/p2pFileShare/peer/peertest"
+	"github.com/WhiCu/p2pFileShare/peer/transport"
+)
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Link
+		wantErr bool
+	}{
+		{"p2pfs://127.0.0.1:8080/abc", Link{Address: "127.0.0.1:8080", ID: "abc"}, false},
+		{"p2pfs://b:1/abc?transport=mem", Link{Address: "mem://b:1", ID: "abc"}, false},
+		{"p2pfs://b:1/abc/", Link{Address: "b:1", ID: "abc"}, false},
+		{"http://b:1/abc", Link{}, true},
+		{"p2pfs://b:1/", Link{}, true},
+		{"p2pfs:///abc", Link{}, true},
+		{"p2pfs://b:1/a/b", Link{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLink(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("ParseLink(%q) = %+v, %v", tt.in, got, err)
+			}
+			if err == nil {
+				if again, _ := ParseLink(got.String()); again != got {
+					t.Fatalf("String() = %q разбирается в %+v", got.String(), again)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestFile(t *testing.T) {
+	mem := transport.NewMemory()
+	a, b := newTestPeer(t, mem, "a"), newTestPeer(t, mem, "b")
+	if err := a.SetDownloadDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	ab := connect(t, a, b)
+
+	link, err := b.Share(writeFile(t, "shared.txt", "открытый файл"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Address != memAddr(b) {
+		t.Fatalf("адрес ссылки %s, ожидался %s", link.Address, memAddr(b))
+	}
+
+	ctx := context.Background()
+	id, err := a.RequestFile(ctx, ab, link.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := downloaded(t, a, id); got != "открытый файл" {
+		t.Fatalf("принято %q", got)
+	}
+
+	b.Unshare(link.ID)
+	for _, id := range []string{link.ID, "unknown"} {
+		if _, err := a.RequestFile(ctx, ab, id); !errors.Is(err, connection.ErrRemote) {
+			t.Fatalf("запрос закрытого файла %s: %v", id, err)
+		}
+	}
+}
+
+func TestReachableAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    string
+		wantErr error
+	}{
+		{"192.168.1.10:8080", "192.168.1.10:8080", nil},
+		{"peer.example:8080", "peer.example:8080", nil},
+		{"127.0.0.1:8080", "", ErrNoPublicAddr},
+		{"[::1]:8080", "", ErrNoPublicAddr},
+		{"localhost:8080", "", ErrNoPublicAddr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got, err := reachableAddr(tt.addr)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("reachableAddr(%q) = %q, %v", tt.addr, got, err)
+			}
+		})
+	}
+
+	// Адрес всех интерфейсов заменяется адресом интерфейса, если он есть
+	got, err := reachableAddr("0.0.0.0:8080")
+	if err != nil && !errors.Is(err, ErrNoPublicAddr) {
+		t.Fatal(err)
+	}
+	if err == nil {
+		if host, port, _ := net.SplitHostPort(got); port != "8080" || net.ParseIP(host).IsUnspecified() {
+			t.Fatalf("адрес всех интерфейсов заменён на %q", got)
+		}
+	}
+}
+
+func TestShareAdvertiseAddr(t *testing.T) {
+	p := peertest.Start(t, NewPeer("a", "127.0.0.1", "0", transport.TCP()))
+	path := writeFile(t, "shared.txt", "данные")
+	if _, err := p.Share(path); !errors.Is(err, ErrNoPublicAddr) {
+		t.Fatalf("ссылка на локальный адрес: %v", err)
+	}
+	if len(p.Shares()) != 0 {
+		t.Fatal("файл открыт без ссылки")
+	}
+
+	for _, tt := range []struct{ advertise, want string }{
+		{"203.0.113.5:9000", "203.0.113.5:9000"},
+		{"peer.example", net.JoinHostPort("peer.example", p.Port)},
+	} {
+		p.SetAdvertiseAddr(tt.advertise)
+		link, err := p.Share(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link.Address != tt.want {
+			t.Fatalf("адрес ссылки %s, ожидался %s", link.Address, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// memAddr - адрес в транспорте в памяти.
+type memAddr string
+
+func (a memAddr) Network() string { return "mem" }
+func (a memAddr) String() string  { return string(a) }
+
+// Memory - транспорт в памяти процесса поверх net.Pipe.
+// Узлы, использующие один экземпляр Memory, могут соединяться друг с другом
+// без сети, что удобно для тестов и встраивания.
+type Memory struct {
+	mu        sync.Mutex
+	listeners map[string]*memListener
+	seq       atomic.Uint64
+}
+
+// NewMemory создаёт изолированную сеть в памяти.
+func NewMemory() *Memory {
+	return &Memory{listeners: make(map[string]*memListener)}
+}
+
+// Name возвращает "mem".
+func (m *Memory) Name() string {
+	return "mem"
+}
+
+// ResolveAddr проверяет адрес: подходит любое непустое имя.
+func (m *Memory) ResolveAddr(addr string) (net.Addr, error) {
+	if addr == "" {
+		return nil, errors.New("transport: пустой адрес")
+	}
+	return memAddr(addr), nil
+}
+
+// Listen регистрирует слушателя под именем addr.
+func (m *Memory) Listen(addr string) (net.Listener, error) {
+	if _, err := m.ResolveAddr(addr); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.listeners[addr]; exists {
+		return nil, fmt.Errorf("transport: адрес %s уже занят", addr)
+	}
+	l := &memListener{
+		memory: m,
+		addr:   memAddr(addr),
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+	m.listeners[addr] = l
+	return l, nil
+}
+
+// Dial соединяется со слушателем addr.
+func (m *Memory) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	m.mu.Lock()
+	l, ok := m.listeners[addr]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("transport: нет слушателя на %s", addr)
+	}
+
+	local := memAddr("mem-" + strconv.FormatUint(m.seq.Add(1), 10))
+	client, server := net.Pipe()
+	select {
+	case l.conns <- &memConn{Conn: server, local: l.addr, remote: local}:
+		return &memConn{Conn: client, local: local, remote: l.addr}, nil
+	case <-l.closed:
+		client.Close()
+		server.Close()
+		return nil, fmt.Errorf("transport: нет слушателя на %s", addr)
+	case <-ctx.Done():
+		client.Close()
+		server.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// memListener принимает соединения транспорта в памяти.
+type memListener struct {
+	memory    *Memory
+	addr      memAddr
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept ожидает очередное входящее соединение.
+func (l *memListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close прекращает приём соединений и освобождает адрес.
+func (l *memListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		l.memory.mu.Lock()
+		delete(l.memory.listeners, string(l.addr))
+		l.memory.mu.Unlock()
+	})
+	return nil
+}
+
+// Addr возвращает адрес слушателя.
+func (l *memListener) Addr() net.Addr {
+	return l.addr
+}
+
+// memConn - конец net.Pipe с осмысленными адресами.
+type memConn struct {
+	net.Conn
+	local, remote memAddr
+}
+
+func (c *memConn) LocalAddr() net.Addr  { return c.local }
+func (c *memConn) RemoteAddr() net.Addr { return c.remote }
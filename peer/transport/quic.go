@@ -0,0 +1,262 @@
+package transport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	// quicALPN - идентификатор протокола приложения в TLS-рукопожатии.
+	// Версия 2: логические потоки узла - потоки QUIC, а не yamux поверх одного потока.
+	quicALPN = "p2pfs/2"
+	// quicIdleTimeout - время бездействия, после которого QUIC-соединение разрывается
+	quicIdleTimeout = time.Minute
+	// quicMaxStreams - число одновременно открытых удалённым узлом потоков
+	quicMaxStreams = 1024
+)
+
+// errStreamsOnly - данные QUIC-соединения передаются только в его потоках
+var errStreamsOnly = errors.New("transport: данные QUIC передаются в потоках (Open/Accept)")
+
+// quicTransport - транспорт поверх QUIC (UDP).
+// Соединения поддерживают встроенные потоки QUIC (методы Open и Accept), поэтому
+// каждый логический поток узла - отдельный поток QUIC без собственного мультиплексора.
+type quicTransport struct {
+	tlsConf *tls.Config
+	conf    *quic.Config
+}
+
+// QUIC создаёт транспорт поверх QUIC. Канал всегда шифруется TLS 1.3, встроенным в QUIC.
+//
+// Без tlsConf (или без сертификата в нём) узел предъявляет новый самоподписанный сертификат,
+// а сертификат удалённого узла не проверяется: соединение защищено от пассивного
+// прослушивания, но не от подмены узла активным посредником. Личность узла в этом
+// случае не подтверждается ни транспортом, ни рукопожатием (идентификатор узла
+// сообщает сам узел).
+//
+// Чтобы узлы проверяли друг друга, задайте в tlsConf сертификат узла и RootCAs:
+// тогда сертификат сервера проверяется по корневым сертификатам, как для wss.
+func QUIC(tlsConf *tls.Config) (Transport, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS13}
+	if tlsConf != nil {
+		conf = tlsConf.Clone()
+		conf.MinVersion = tls.VersionTLS13
+	}
+	if len(conf.Certificates) == 0 && conf.GetCertificate == nil {
+		cert, err := selfSignedCert()
+		if err != nil {
+			return nil, fmt.Errorf("transport: не удалось создать сертификат QUIC: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	if conf.RootCAs == nil {
+		conf.InsecureSkipVerify = true // Проверять нечем: только шифрование, см. описание QUIC
+	}
+	conf.NextProtos = []string{quicALPN}
+	return &quicTransport{
+		tlsConf: conf,
+		conf: &quic.Config{
+			MaxIdleTimeout:     quicIdleTimeout,
+			KeepAlivePeriod:    quicIdleTimeout / 3,
+			MaxIncomingStreams: quicMaxStreams,
+		},
+	}, nil
+}
+
+// Name возвращает "quic".
+func (t *quicTransport) Name() string {
+	return "quic"
+}
+
+// ResolveAddr разрешает UDP-адрес.
+func (t *quicTransport) ResolveAddr(addr string) (net.Addr, error) {
+	return net.ResolveUDPAddr("udp", addr)
+}
+
+// Listen запускает приём QUIC-соединений на UDP-адресе addr.
+func (t *quicTransport) Listen(addr string) (net.Listener, error) {
+	ql, err := quic.ListenAddr(addr, t.tlsConf, t.conf)
+	if err != nil {
+		return nil, err
+	}
+	return &quicListener{ql: ql, closed: make(chan struct{})}, nil
+}
+
+// Dial устанавливает QUIC-соединение с адресом addr.
+func (t *quicTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	qc, err := quic.DialAddr(ctx, addr, t.tlsConf, t.conf)
+	if err != nil {
+		return nil, err
+	}
+	return &quicConn{conn: qc}, nil
+}
+
+// quicListener принимает QUIC-соединения.
+type quicListener struct {
+	ql        *quic.Listener
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Accept ожидает очередное входящее соединение.
+func (l *quicListener) Accept() (net.Conn, error) {
+	qc, err := l.ql.Accept(context.Background())
+	if err != nil {
+		select {
+		case <-l.closed:
+			return nil, net.ErrClosed
+		default:
+			return nil, err
+		}
+	}
+	return &quicConn{conn: qc}, nil
+}
+
+// Close прекращает приём соединений. Повторные вызовы ничего не делают.
+func (l *quicListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.ql.Close()
+	})
+	return err
+}
+
+// Addr возвращает локальный адрес.
+func (l *quicListener) Addr() net.Addr {
+	return l.ql.Addr()
+}
+
+// quicConn представляет QUIC-соединение как net.Conn. Данные передаются только
+// в потоках, открытых через Open и принятых через Accept; Read и Write не поддерживаются.
+type quicConn struct {
+	conn quic.Connection
+}
+
+// Open открывает новый поток. Удалённая сторона узнаёт о потоке после первой записи в него.
+func (c *quicConn) Open() (net.Conn, error) {
+	s, err := c.conn.OpenStreamSync(c.conn.Context())
+	if err != nil {
+		return nil, quicErr(err)
+	}
+	return &quicStream{Stream: s, conn: c.conn}, nil
+}
+
+// Accept ожидает поток, открытый удалённой стороной.
+func (c *quicConn) Accept() (net.Conn, error) {
+	s, err := c.conn.AcceptStream(c.conn.Context())
+	if err != nil {
+		return nil, quicErr(err)
+	}
+	return &quicStream{Stream: s, conn: c.conn}, nil
+}
+
+func (c *quicConn) Read([]byte) (int, error)         { return 0, errStreamsOnly }
+func (c *quicConn) Write([]byte) (int, error)        { return 0, errStreamsOnly }
+func (c *quicConn) SetDeadline(time.Time) error      { return errStreamsOnly }
+func (c *quicConn) SetReadDeadline(time.Time) error  { return errStreamsOnly }
+func (c *quicConn) SetWriteDeadline(time.Time) error { return errStreamsOnly }
+
+// Close закрывает QUIC-соединение со всеми потоками.
+func (c *quicConn) Close() error {
+	return c.conn.CloseWithError(0, "")
+}
+
+// LocalAddr возвращает локальный адрес соединения.
+func (c *quicConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr возвращает адрес удалённого узла.
+func (c *quicConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// quicStream представляет поток QUIC как net.Conn.
+type quicStream struct {
+	quic.Stream
+	conn quic.Connection
+}
+
+// Read читает данные потока; закрытие соединения или потока возвращает net.ErrClosed.
+func (s *quicStream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	return n, quicErr(err)
+}
+
+// Write записывает данные в поток.
+func (s *quicStream) Write(p []byte) (int, error) {
+	n, err := s.Stream.Write(p)
+	return n, quicErr(err)
+}
+
+// Close завершает запись (удалённая сторона получит io.EOF после всех данных)
+// и отказывается от непрочитанных входящих данных.
+func (s *quicStream) Close() error {
+	s.Stream.CancelRead(0)
+	return s.Stream.Close()
+}
+
+// LocalAddr возвращает локальный адрес соединения.
+func (s *quicStream) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// RemoteAddr возвращает адрес удалённого узла.
+func (s *quicStream) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// quicErr приводит ошибки штатного закрытия QUIC-соединения и потоков к net.ErrClosed.
+func quicErr(err error) error {
+	var appErr *quic.ApplicationError
+	var streamErr *quic.StreamError
+	var idleErr *quic.IdleTimeoutError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &appErr) && appErr.ErrorCode == 0,
+		errors.As(err, &streamErr) && streamErr.ErrorCode == 0,
+		errors.As(err, &idleErr):
+		return fmt.Errorf("%w: %v", net.ErrClosed, err)
+	}
+	return err
+}
+
+// selfSignedCert создаёт самоподписанный сертификат ECDSA P-256.
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: quicALPN},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,102 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQUICStreams(t *testing.T) {
+	tr, err := QUIC(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP недоступен: %v", err)
+	}
+	defer l.Close()
+
+	// Сервер возвращает содержимое каждого потока обратно
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			s, err := conn.(Multiplexer).Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer s.Close()
+				io.Copy(s, s)
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := tr.Dial(ctx, l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	mux, ok := conn.(Multiplexer)
+	if !ok {
+		t.Fatal("QUIC-соединение не поддерживает потоки")
+	}
+	if _, err := conn.Write([]byte("x")); err == nil {
+		t.Fatal("запись в QUIC-соединение вне потока")
+	}
+
+	for _, msg := range []string{"первый поток", "второй поток"} {
+		s, err := mux.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(s, buf); err != nil || string(buf) != msg {
+			t.Fatalf("эхо %q (%v), ожидалось %q", buf, err, msg)
+		}
+		s.Close()
+	}
+}
+
+func TestQUICListenerClose(t *testing.T) {
+	tr, err := QUIC(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP недоступен: %v", err)
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		accepted <- err
+	}()
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("повторный Close: %v", err)
+	}
+	select {
+	case err := <-accepted:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Accept после Close: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close не прервал Accept")
+	}
+}
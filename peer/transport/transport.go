@@ -0,0 +1,82 @@
+// Пакет transport отделяет узел от конкретного сетевого протокола.
+// Транспорт умеет слушать адрес и устанавливать соединения, возвращая net.Conn,
+// поверх которого работает протокол сообщений узла.
+package transport
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// Transport - сетевой протокол, по которому узлы устанавливают соединения.
+// Адреса передаются без схемы: "host:port" для сетевых транспортов,
+// путь к сокету для Unix, произвольное имя для транспорта в памяти.
+type Transport interface {
+	// Name возвращает имя транспорта, оно же схема адреса ("tcp", "quic", "unix", "mem").
+	Name() string
+	// ResolveAddr проверяет и разрешает адрес транспорта.
+	ResolveAddr(addr string) (net.Addr, error)
+	// Listen начинает приём входящих соединений на адресе addr.
+	Listen(addr string) (net.Listener, error)
+	// Dial устанавливает соединение с адресом addr.
+	Dial(ctx context.Context, addr string) (net.Conn, error)
+}
+
+// Multiplexer - соединение со встроенными потоками (например, QUIC).
+// Соединение узла открывает логические потоки прямо в нём, без собственного мультиплексора;
+// Read и Write такого соединения могут не поддерживаться.
+type Multiplexer interface {
+	// Open открывает новый поток
+	Open() (net.Conn, error)
+	// Accept ожидает поток, открытый удалённой стороной
+	Accept() (net.Conn, error)
+}
+
+// SplitAddr разделяет адрес вида "scheme://addr" на схему и адрес транспорта.
+// Адрес без схемы считается TCP-адресом.
+func SplitAddr(addr string) (scheme, address string) {
+	if scheme, address, ok := strings.Cut(addr, "://"); ok {
+		return strings.ToLower(scheme), address
+	}
+	return "tcp", addr
+}
+
+// JoinAddr собирает адрес узла из схемы и адреса транспорта.
+// Для TCP схема опускается, что совместимо с адресами без схемы.
+func JoinAddr(scheme, address string) string {
+	if scheme == "tcp" {
+		return address
+	}
+	return scheme + "://" + address
+}
+
+// tcpTransport - транспорт поверх TCP.
+type tcpTransport struct {
+	dialer net.Dialer
+}
+
+// TCP возвращает транспорт поверх TCP.
+func TCP() Transport {
+	return &tcpTransport{}
+}
+
+// Name возвращает "tcp".
+func (t *tcpTransport) Name() string {
+	return "tcp"
+}
+
+// ResolveAddr разрешает TCP-адрес.
+func (t *tcpTransport) ResolveAddr(addr string) (net.Addr, error) {
+	return net.ResolveTCPAddr("tcp", addr)
+}
+
+// Listen запускает TCP-сервер на адресе addr.
+func (t *tcpTransport) Listen(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+// Dial устанавливает TCP-соединение с адресом addr.
+func (t *tcpTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	return t.dialer.DialContext(ctx, "tcp", addr)
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSplitAddr(t *testing.T) {
+	tests := []struct {
+		in, scheme, addr string
+		joined           string // Адрес, собранный JoinAddr обратно
+	}{
+		{"127.0.0.1:8080", "tcp", "127.0.0.1:8080", "127.0.0.1:8080"},
+		{"quic://10.0.0.2:8080", "quic", "10.0.0.2:8080", "quic://10.0.0.2:8080"},
+		{"QUIC://10.0.0.2:8080", "quic", "10.0.0.2:8080", "quic://10.0.0.2:8080"},
+		{"unix:///tmp/p2pfs.sock", "unix", "/tmp/p2pfs.sock", "unix:///tmp/p2pfs.sock"},
+		{"mem://b:1", "mem", "b:1", "mem://b:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			scheme, addr := SplitAddr(tt.in)
+			if scheme != tt.scheme || addr != tt.addr {
+				t.Fatalf("SplitAddr(%q) = %q, %q", tt.in, scheme, addr)
+			}
+			if joined := JoinAddr(scheme, addr); joined != tt.joined {
+				t.Fatalf("JoinAddr(%q, %q) = %q", scheme, addr, joined)
+			}
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	mem := NewMemory()
+	l, err := mem.Listen("b:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Listen("b:1"); err == nil {
+		t.Fatal("адрес занят дважды")
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := l.Accept()
+		accepted <- c
+	}()
+	client, err := mem.Dial(context.Background(), "b:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server := <-accepted
+	defer server.Close()
+	if server.LocalAddr().String() != "b:1" || client.RemoteAddr().String() != "b:1" ||
+		server.RemoteAddr().String() != client.LocalAddr().String() {
+		t.Fatalf("адреса: клиент %s -> %s, сервер %s -> %s",
+			client.LocalAddr(), client.RemoteAddr(), server.LocalAddr(), server.RemoteAddr())
+	}
+	go client.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("прочитано %q (%v)", buf, err)
+	}
+
+	l.Close()
+	l.Close()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Accept после Close: %v", err)
+	}
+	if _, err := mem.Dial(context.Background(), "b:1"); err == nil {
+		t.Fatal("соединение с закрытым слушателем установлено")
+	}
+	if _, err := mem.Listen("b:1"); err != nil {
+		t.Fatalf("адрес не освобождён после Close: %v", err)
+	}
+}
+
+func TestMemoryDialCancel(t *testing.T) {
+	mem := NewMemory()
+	l, err := mem.Listen("b:1") // Слушатель, который не принимает соединения
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := mem.Dial(ctx, "b:1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Dial: %v", err)
+	}
+}
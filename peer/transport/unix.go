@@ -0,0 +1,76 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync/atomic"
+)
+
+// unixTransport - транспорт поверх Unix-сокетов для узлов на одной машине.
+type unixTransport struct {
+	dialer net.Dialer
+}
+
+// Unix возвращает транспорт поверх Unix-сокетов. Адрес - путь к файлу сокета.
+func Unix() Transport {
+	return &unixTransport{}
+}
+
+// Name возвращает "unix".
+func (t *unixTransport) Name() string {
+	return "unix"
+}
+
+// ResolveAddr разрешает адрес Unix-сокета.
+func (t *unixTransport) ResolveAddr(addr string) (net.Addr, error) {
+	return net.ResolveUnixAddr("unix", addr)
+}
+
+// Listen создаёт сокет по пути addr. Файл сокета удаляется при закрытии слушателя.
+func (t *unixTransport) Listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &unixListener{Listener: l}, nil
+}
+
+// Dial подключается к сокету по пути addr.
+func (t *unixTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	return t.dialer.DialContext(ctx, "unix", addr)
+}
+
+// unixListener выдаёт принятым соединениям уникальные удалённые адреса:
+// у клиентского Unix-сокета адреса обычно нет, а узел различает соединения по адресу.
+type unixListener struct {
+	net.Listener
+	seq atomic.Uint64
+}
+
+// Accept ожидает очередное входящее соединение.
+func (l *unixListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if remote := conn.RemoteAddr(); remote != nil && remote.String() != "" && remote.String() != "@" {
+		return conn, nil
+	}
+	remote := &net.UnixAddr{
+		Name: l.Addr().String() + "#" + strconv.FormatUint(l.seq.Add(1), 10),
+		Net:  "unix",
+	}
+	return &addrConn{Conn: conn, remote: remote}, nil
+}
+
+// addrConn подменяет удалённый адрес соединения.
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+// RemoteAddr возвращает подменённый удалённый адрес.
+func (c *addrConn) RemoteAddr() net.Addr {
+	return c.remote
+}
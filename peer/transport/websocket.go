@@ -0,0 +1,267 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultWebSocketPath - путь HTTP-ресурса, если адрес его не содержит
+	DefaultWebSocketPath = "/p2p"
+	// wsHandshakeTimeout - время на HTTP-рукопожатие WebSocket
+	wsHandshakeTimeout = 15 * time.Second
+	// wsBufferSize - размер буферов чтения и записи WebSocket
+	wsBufferSize = 32 * 1024
+)
+
+// wsTransport - транспорт поверх WebSocket для сетей, где разрешён только HTTP(S).
+// Адрес имеет вид "host:port[/path]"; при подключении учитываются переменные
+// окружения HTTP_PROXY, HTTPS_PROXY и NO_PROXY.
+//
+// Протокол узлов не аутентифицирует собеседника, поэтому соединения от браузеров
+// принимаются только со страниц того же адреса или из списка origins: иначе любая
+// открытая пользователем страница могла бы подключиться к узлу на localhost.
+// Клиенты вне браузера заголовок Origin не передают и принимаются всегда.
+type wsTransport struct {
+	scheme  string      // "ws" или "wss"
+	tlsConf *tls.Config // Для wss: сертификаты сервера и корневые сертификаты клиента
+	origins []string    // Разрешённые источники браузерных клиентов, например "https://app.example"
+}
+
+// WebSocket возвращает транспорт ws:// (WebSocket без шифрования).
+// origins - источники страниц, которым разрешено подключаться к узлу из браузера.
+func WebSocket(origins ...string) Transport {
+	return &wsTransport{scheme: "ws", origins: origins}
+}
+
+// SecureWebSocket возвращает транспорт wss:// (WebSocket поверх TLS).
+// Для приёма соединений tlsConf должен содержать сертификат сервера;
+// при подключении nil означает проверку сертификата по системным корневым сертификатам.
+// origins - как у WebSocket.
+func SecureWebSocket(tlsConf *tls.Config, origins ...string) Transport {
+	return &wsTransport{scheme: "wss", tlsConf: tlsConf, origins: origins}
+}
+
+// checkOrigin разрешает запросы без заголовка Origin, со страниц того же адреса,
+// что и узел, и из списка t.origins.
+func (t *wsTransport) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range t.origins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Name возвращает "ws" или "wss".
+func (t *wsTransport) Name() string {
+	return t.scheme
+}
+
+// splitWSAddr отделяет "host:port" от пути ресурса.
+func splitWSAddr(addr string) (hostport, path string) {
+	if i := strings.IndexByte(addr, '/'); i >= 0 {
+		return addr[:i], addr[i:]
+	}
+	return addr, DefaultWebSocketPath
+}
+
+// ResolveAddr разрешает TCP-адрес HTTP-сервера.
+func (t *wsTransport) ResolveAddr(addr string) (net.Addr, error) {
+	hostport, _ := splitWSAddr(addr)
+	return net.ResolveTCPAddr("tcp", hostport)
+}
+
+// Listen запускает HTTP-сервер на addr и принимает WebSocket-соединения на пути ресурса.
+func (t *wsTransport) Listen(addr string) (net.Listener, error) {
+	hostport, path := splitWSAddr(addr)
+	if t.scheme == "wss" && (t.tlsConf == nil || (len(t.tlsConf.Certificates) == 0 && t.tlsConf.GetCertificate == nil)) {
+		return nil, errors.New("transport: для wss нужен сертификат сервера")
+	}
+
+	tcp, err := net.Listen("tcp", hostport)
+	if err != nil {
+		return nil, err
+	}
+	var inner net.Listener = tcp
+	if t.scheme == "wss" {
+		inner = tls.NewListener(tcp, t.tlsConf)
+	}
+
+	l := &wsListener{
+		addr:   tcp.Addr(),
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  wsBufferSize,
+		WriteBufferSize: wsBufferSize,
+		CheckOrigin:     t.checkOrigin,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return // Upgrader уже ответил клиенту ошибкой
+		}
+		select {
+		case l.conns <- newWSConn(ws):
+		case <-l.closed:
+			ws.Close()
+		}
+	})
+	l.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: wsHandshakeTimeout,
+	}
+	go l.server.Serve(inner)
+	return l, nil
+}
+
+// Dial подключается к WebSocket-ресурсу addr, при необходимости через HTTP-прокси.
+func (t *wsTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	hostport, path := splitWSAddr(addr)
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		TLSClientConfig:  t.tlsConf,
+		HandshakeTimeout: wsHandshakeTimeout,
+		ReadBufferSize:   wsBufferSize,
+		WriteBufferSize:  wsBufferSize,
+	}
+	ws, resp, err := dialer.DialContext(ctx, t.scheme+"://"+hostport+path, nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("transport: %s: %w (HTTP %s)", addr, err, resp.Status)
+		}
+		return nil, err
+	}
+	return newWSConn(ws), nil
+}
+
+// wsListener отдаёт принятые WebSocket-соединения как net.Conn.
+type wsListener struct {
+	addr      net.Addr
+	server    *http.Server
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept ожидает очередное входящее соединение.
+func (l *wsListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close останавливает HTTP-сервер. Уже принятые соединения не закрываются.
+func (l *wsListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.server.Close()
+	})
+	return err
+}
+
+// Addr возвращает адрес HTTP-сервера.
+func (l *wsListener) Addr() net.Addr {
+	return l.addr
+}
+
+// wsConn представляет WebSocket-соединение как поток байт (net.Conn).
+// Каждая запись уходит отдельным бинарным сообщением, чтение склеивает сообщения.
+type wsConn struct {
+	ws *websocket.Conn
+
+	readMu sync.Mutex
+	reader io.Reader // Текущее читаемое сообщение
+
+	writeMu sync.Mutex
+}
+
+func newWSConn(ws *websocket.Conn) *wsConn {
+	return &wsConn{ws: ws}
+}
+
+// Read читает данные из очередных бинарных сообщений.
+func (c *wsConn) Read(p []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	for {
+		if c.reader == nil {
+			typ, r, err := c.ws.NextReader()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					return 0, io.EOF
+				}
+				return 0, err
+			}
+			if typ != websocket.BinaryMessage {
+				continue // Текстовые сообщения не относятся к протоколу
+			}
+			c.reader = r
+		}
+		n, err := c.reader.Read(p)
+		if err == io.EOF {
+			c.reader = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Write отправляет p одним бинарным сообщением.
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close отправляет кадр закрытия и закрывает соединение.
+func (c *wsConn) Close() error {
+	c.writeMu.Lock()
+	c.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	return c.ws.Close()
+}
+
+func (c *wsConn) LocalAddr() net.Addr  { return c.ws.LocalAddr() }
+func (c *wsConn) RemoteAddr() net.Addr { return c.ws.RemoteAddr() }
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error  { return c.ws.SetReadDeadline(t) }
+func (c *wsConn) SetWriteDeadline(t time.Time) error { return c.ws.SetWriteDeadline(t) }
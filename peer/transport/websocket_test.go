@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketOrigin(t *testing.T) {
+	l, err := WebSocket("https://app.example").Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	addr := l.Addr().String()
+
+	tests := []struct {
+		name   string
+		origin string // "" - без заголовка Origin, как у клиентов вне браузера
+		want   bool
+	}{
+		{"без Origin", "", true},
+		{"тот же адрес", "http://" + addr, true},
+		{"из списка", "https://app.example", true},
+		{"из списка без учёта регистра", "https://APP.example", true},
+		{"чужая страница", "http://evil.example", false},
+		{"другой порт", "http://127.0.0.1:1", false},
+		{"другая схема из списка", "http://app.example", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			ws, resp, err := websocket.DefaultDialer.Dial("ws://"+addr+DefaultWebSocketPath, header)
+			if ws != nil {
+				ws.Close()
+			}
+			if (err == nil) != tt.want {
+				t.Fatalf("подключение с Origin %q: %v, ожидалось разрешение %v", tt.origin, err, tt.want)
+			}
+			if !tt.want && (resp == nil || resp.StatusCode != http.StatusForbidden) {
+				t.Fatalf("ответ %v, ожидался 403", resp)
+			}
+		})
+	}
+}
+
+func TestWebSocketDial(t *testing.T) {
+	ws := WebSocket()
+	l, err := ws.Listen("127.0.0.1:0/peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+
+	c, err := ws.Dial(context.Background(), l.Addr().String()+"/peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	server := <-accepted
+	defer server.Close()
+
+	if _, err := c.Write([]byte("привет")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len("привет"))
+	if _, err := io.ReadFull(server, buf); err != nil || string(buf) != "привет" {
+		t.Fatalf("принято %q: %v", buf, err)
+	}
+}
@@ -0,0 +1,41 @@
+package transfer
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sniffSize - сколько байт начала файла используется для определения его типа
+const sniffSize = 512
+
+// compressedExts - расширения файлов, содержимое которых уже сжато
+var compressedExts = map[string]bool{
+	".7z": true, ".apk": true, ".avi": true, ".br": true, ".bz2": true,
+	".docx": true, ".flac": true, ".gif": true, ".gz": true, ".heic": true,
+	".jar": true, ".jpeg": true, ".jpg": true, ".lz4": true, ".m4a": true,
+	".mkv": true, ".mov": true, ".mp3": true, ".mp4": true, ".odt": true,
+	".ogg": true, ".opus": true, ".png": true, ".pptx": true, ".rar": true,
+	".tgz": true, ".webm": true, ".webp": true, ".xlsx": true, ".xz": true,
+	".zip": true, ".zst": true,
+}
+
+// Compressible сообщает, имеет ли смысл сжимать файл при передаче.
+// Файлы, уже сжатые по расширению или по сигнатуре начала head
+// (архивы, изображения, аудио и видео), не сжимаются повторно.
+func Compressible(name string, head []byte) bool {
+	if compressedExts[strings.ToLower(filepath.Ext(name))] {
+		return false
+	}
+	if len(head) == 0 {
+		return true
+	}
+	switch ct := http.DetectContentType(head); {
+	case strings.HasPrefix(ct, "image/"), strings.HasPrefix(ct, "audio/"), strings.HasPrefix(ct, "video/"):
+		return ct == "image/bmp" || ct == "image/x-icon" || ct == "audio/wave"
+	case ct == "application/zip", ct == "application/x-gzip", ct == "application/x-rar-compressed",
+		ct == "application/x-7z-compressed", ct == "application/wasm":
+		return false
+	}
+	return true
+}
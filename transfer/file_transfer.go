@@ -1 +1,102 @@
+// Пакет transfer реализует передачу файлов между узлами P2P-сети.
+// Файл передаётся предложением (Offer), последовательностью блоков и
+// завершающим сообщением; получатель проверяет контрольную сумму перед сохранением.
 package transfer
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ChunkSize - размер одного блока файла при передаче
+const ChunkSize = 32 * 1024
+
+var (
+	// ErrInvalidName - имя файла не может быть приведено к безопасному виду
+	ErrInvalidName = errors.New("transfer: недопустимое имя файла")
+	// ErrSizeMismatch - получено больше или меньше данных, чем заявлено в предложении
+	ErrSizeMismatch = errors.New("transfer: размер файла не совпадает")
+	// ErrHashMismatch - контрольная сумма полученного файла не совпадает с заявленной
+	ErrHashMismatch = errors.New("transfer: контрольная сумма не совпадает")
+)
+
+// Offer описывает файл, который узел предлагает передать.
+type Offer struct {
+	ID       string // Идентификатор передачи
+	Filename string // Имя файла (задаётся отправителем, не доверенное)
+	Size     int64  // Размер файла в байтах
+	Hash     string // SHA-256 содержимого в шестнадцатеричном виде
+}
+
+// NewID возвращает случайный идентификатор передачи.
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic("transfer: не удалось сгенерировать идентификатор: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Upload - открытый для отправки файл вместе с описанием для получателя.
+type Upload struct {
+	Offer
+	Compressible bool // Содержимое имеет смысл сжимать при передаче
+	file         *os.File
+}
+
+// OpenUpload открывает файл, вычисляет его размер и контрольную сумму
+// и подготавливает его к чтению с начала.
+func OpenUpload(path string) (*Upload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("transfer: не удалось открыть файл: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("transfer: не удалось получить информацию о файле: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("transfer: %s не является обычным файлом", path)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("transfer: не удалось прочитать файл: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("transfer: не удалось перемотать файл: %w", err)
+	}
+	head := make([]byte, sniffSize)
+	n, _ := f.ReadAt(head, 0)
+
+	return &Upload{
+		Offer: Offer{
+			ID:       NewID(),
+			Filename: filepath.Base(path),
+			Size:     info.Size(),
+			Hash:     hex.EncodeToString(h.Sum(nil)),
+		},
+		Compressible: Compressible(path, head[:n]),
+		file:         f,
+	}, nil
+}
+
+// Read читает очередную порцию данных файла.
+func (u *Upload) Read(p []byte) (int, error) {
+	return u.file.Read(p)
+}
+
+// Close закрывает файл.
+func (u *Upload) Close() error {
+	return u.file.Close()
+}
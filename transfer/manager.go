@@ -0,0 +1,554 @@
+package transfer
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+const (
+	// DefaultMaxActive - число одновременных передач по умолчанию
+	DefaultMaxActive = 4
+	// DefaultMaxPerPeer - число одновременных передач с одним узлом в каждом направлении по умолчанию
+	DefaultMaxPerPeer = 2
+	// HistorySize - число последних завершённых передач, сохраняемых менеджером
+	HistorySize = 100
+)
+
+// Приоритеты передач: задачи с большим приоритетом запускаются раньше.
+const (
+	PriorityLow    = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+var (
+	// ErrUnknownTask - передача с указанным идентификатором не найдена
+	ErrUnknownTask = errors.New("transfer: передача не найдена")
+	// ErrDuplicateTask - передача с таким идентификатором уже есть в менеджере
+	ErrDuplicateTask = errors.New("transfer: передача с таким идентификатором уже существует")
+)
+
+// RunFunc выполняет передачу. Реализация должна периодически вызывать
+// Task.Wait, чтобы поддерживать паузу и отмену, и Task.Add для учёта прогресса.
+type RunFunc func(ctx context.Context, t *Task) error
+
+// Task - передача файла, управляемая Manager.
+type Task struct {
+	ID        string    // Идентификатор передачи
+	Filename  string    // Имя файла
+	Peer      string    // Адрес удалённого узла
+	Direction Direction // Направление передачи
+	Size      int64     // Размер файла в байтах
+	Priority  int       // Приоритет в очереди
+
+	run      RunFunc
+	onPause  func(paused bool)
+	onCancel func()
+	tracker  *Tracker
+
+	mu       sync.Mutex
+	started  bool
+	paused   bool
+	resumed  chan struct{} // Закрывается при снятии паузы
+	ctx      context.Context
+	cancel   context.CancelFunc
+	canceled bool
+
+	seq   uint64        // Порядок постановки в очередь
+	index int           // Позиция в очереди (для heap)
+	done  chan struct{} // Закрывается по завершении передачи
+	err   error
+}
+
+// NewTask создаёт задачу передачи, выполняемую функцией run.
+func NewTask(id, filename, peer string, dir Direction, size int64, priority int, run RunFunc) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Task{
+		ID:        id,
+		Filename:  filename,
+		Peer:      peer,
+		Direction: dir,
+		Size:      size,
+		Priority:  priority,
+		run:       run,
+		tracker:   NewTracker(id, filename, peer, dir, size, nil),
+		ctx:       ctx,
+		cancel:    cancel,
+		index:     -1,
+		done:      make(chan struct{}),
+	}
+}
+
+// OnPause задаёт функцию, вызываемую при постановке на паузу и снятии с неё.
+// Используется, например, чтобы сообщить о паузе удалённому узлу.
+func (t *Task) OnPause(fn func(paused bool)) {
+	t.onPause = fn
+}
+
+// OnCancel задаёт функцию, вызываемую один раз при отмене передачи,
+// в том числе если передача ещё стоит в очереди.
+func (t *Task) OnCancel(fn func()) {
+	t.onCancel = fn
+}
+
+// Wait блокируется, пока передача стоит на паузе.
+// Возвращает ошибку, если передача отменена или ctx завершён.
+func (t *Task) Wait(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		paused, resumed := t.paused, t.resumed
+		t.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !paused {
+			return nil
+		}
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Add учитывает n переданных байт.
+func (t *Task) Add(n int) {
+	t.tracker.Add(n)
+}
+
+// SetPath запоминает путь, под которым сохранён принятый файл (см. Progress.Path).
+func (t *Task) SetPath(path string) {
+	t.tracker.SetPath(path)
+}
+
+// Pause приостанавливает передачу.
+func (t *Task) Pause() {
+	t.mu.Lock()
+	if t.paused || t.canceled {
+		t.mu.Unlock()
+		return
+	}
+	t.paused = true
+	t.resumed = make(chan struct{})
+	started := t.started
+	t.mu.Unlock()
+
+	if started {
+		t.tracker.SetPaused(true)
+	}
+	if t.onPause != nil {
+		t.onPause(true)
+	}
+}
+
+// Resume снимает передачу с паузы.
+func (t *Task) Resume() {
+	t.mu.Lock()
+	if !t.paused || t.canceled {
+		t.mu.Unlock()
+		return
+	}
+	t.paused = false
+	close(t.resumed)
+	started := t.started
+	t.mu.Unlock()
+
+	if started {
+		t.tracker.SetPaused(false)
+	}
+	if t.onPause != nil {
+		t.onPause(false)
+	}
+}
+
+// Cancel отменяет передачу.
+func (t *Task) Cancel() {
+	t.mu.Lock()
+	if t.canceled {
+		t.mu.Unlock()
+		return
+	}
+	t.canceled = true
+	t.mu.Unlock()
+
+	t.cancel()
+	if t.onCancel != nil {
+		t.onCancel()
+	}
+}
+
+// Done возвращает канал, закрывающийся по завершении передачи.
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err возвращает результат завершённой передачи.
+func (t *Task) Err() error {
+	<-t.done
+	return t.err
+}
+
+// Progress возвращает текущий снимок прогресса передачи.
+func (t *Task) Progress() Progress {
+	return t.tracker.Progress()
+}
+
+// Manager управляет очередью передач: ограничивает число одновременных
+// передач в целом и с каждым узлом и запускает задачи в порядке приоритета.
+// Лимит на узел считается отдельно для отправки и приёма: иначе отправки,
+// ждущие согласия узла, заняли бы слоты, нужные для приёма его встречных файлов.
+// Приостановленная передача продолжает занимать слот.
+type Manager struct {
+	mu         sync.Mutex
+	maxActive  int
+	maxPerPeer int
+	notify     ProgressFunc
+
+	queue   taskQueue
+	tasks   map[string]*Task // Все незавершённые передачи
+	active  int
+	perPeer map[peerSlot]int // Число активных передач по узлам и направлениям
+	seq     uint64
+
+	draining bool // Новые передачи из очереди не запускаются
+
+	completed [2]uint64 // Завершённые передачи по направлениям
+	failed    [2]uint64
+	canceled  [2]uint64
+	bytes     [2]uint64 // Байты завершённых передач по направлениям
+
+	history []Progress // Последние завершённые передачи, от старых к новым
+}
+
+// Stats - показатели передач менеджера. Счётчики накапливаются с момента
+// создания менеджера, массивы индексируются значением Direction.
+type Stats struct {
+	Active    int       // Запущенные передачи
+	Queued    int       // Передачи в очереди
+	Completed [2]uint64 // Успешно завершённые передачи
+	Failed    [2]uint64 // Передачи, завершившиеся ошибкой
+	Canceled  [2]uint64 // Отменённые передачи
+	Bytes     [2]uint64 // Передано байт, включая идущие передачи
+}
+
+// NewManager создаёт менеджер передач. Значения лимитов <= 0 снимают ограничение.
+// notify получает события о ходе всех передач и может быть nil.
+func NewManager(maxActive, maxPerPeer int, notify ProgressFunc) *Manager {
+	return &Manager{
+		maxActive:  maxActive,
+		maxPerPeer: maxPerPeer,
+		notify:     notify,
+		tasks:      make(map[string]*Task),
+		perPeer:    make(map[peerSlot]int),
+	}
+}
+
+// SetLimits изменяет лимиты одновременных передач: всего и с одним узлом в каждом направлении.
+func (m *Manager) SetLimits(maxActive, maxPerPeer int) {
+	m.mu.Lock()
+	m.maxActive, m.maxPerPeer = maxActive, maxPerPeer
+	m.mu.Unlock()
+	m.schedule()
+}
+
+// Enqueue ставит задачу в очередь. Задача запускается, как только позволяют лимиты.
+// После Drain задача сразу завершается отменой. Если передача с тем же идентификатором
+// ещё не завершена, задача не ставится в очередь и возвращается ErrDuplicateTask.
+func (m *Manager) Enqueue(t *Task) error {
+	t.tracker.setNotify(m.notify)
+
+	m.mu.Lock()
+	if _, exists := m.tasks[t.ID]; exists {
+		m.mu.Unlock()
+		return ErrDuplicateTask
+	}
+	if m.draining {
+		m.mu.Unlock()
+		t.Cancel()
+		t.err = context.Canceled
+		t.tracker.Cancel()
+		m.mu.Lock()
+		m.record(t.Progress())
+		m.mu.Unlock()
+		close(t.done)
+		return nil
+	}
+	m.seq++
+	t.seq = m.seq
+	m.tasks[t.ID] = t
+	heap.Push(&m.queue, t)
+	m.mu.Unlock()
+
+	t.tracker.emit(t.tracker.Progress())
+	m.schedule()
+	return nil
+}
+
+// Get возвращает незавершённую передачу по идентификатору.
+func (m *Manager) Get(id string) (*Task, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	return t, ok
+}
+
+// List возвращает снимки прогресса всех незавершённых передач.
+func (m *Manager) List() []Progress {
+	m.mu.Lock()
+	tasks := make([]*Task, 0, len(m.tasks))
+	for _, t := range m.tasks {
+		tasks = append(tasks, t)
+	}
+	m.mu.Unlock()
+
+	list := make([]Progress, 0, len(tasks))
+	for _, t := range tasks {
+		list = append(list, t.Progress())
+	}
+	return list
+}
+
+// Pause приостанавливает передачу по идентификатору.
+func (m *Manager) Pause(id string) error {
+	t, ok := m.Get(id)
+	if !ok {
+		return ErrUnknownTask
+	}
+	t.Pause()
+	return nil
+}
+
+// Resume снимает передачу с паузы по идентификатору.
+func (m *Manager) Resume(id string) error {
+	t, ok := m.Get(id)
+	if !ok {
+		return ErrUnknownTask
+	}
+	t.Resume()
+	return nil
+}
+
+// Cancel отменяет передачу по идентификатору.
+// Передача, ещё стоящая в очереди, удаляется из неё.
+func (m *Manager) Cancel(id string) error {
+	t, ok := m.Get(id)
+	if !ok {
+		return ErrUnknownTask
+	}
+	t.Cancel()
+
+	m.mu.Lock()
+	queued := t.index >= 0
+	if queued {
+		heap.Remove(&m.queue, t.index)
+		delete(m.tasks, t.ID)
+	}
+	m.mu.Unlock()
+
+	if queued {
+		t.err = context.Canceled
+		t.tracker.Cancel()
+		m.mu.Lock()
+		m.record(t.Progress())
+		m.mu.Unlock()
+		close(t.done)
+	}
+	return nil
+}
+
+// Drain прекращает запуск передач, отменяет стоящие в очереди и ждёт завершения
+// запущенных. Если ctx завершается раньше, оставшиеся передачи отменяются
+// и возвращается ошибка ctx.
+func (m *Manager) Drain(ctx context.Context) error {
+	m.mu.Lock()
+	m.draining = true
+	m.mu.Unlock()
+
+	for {
+		m.mu.Lock()
+		var queued, active []*Task
+		for _, t := range m.tasks {
+			if t.index >= 0 {
+				queued = append(queued, t)
+			} else {
+				active = append(active, t)
+			}
+		}
+		m.mu.Unlock()
+
+		if len(queued) == 0 && len(active) == 0 {
+			return nil
+		}
+		for _, t := range queued {
+			m.Cancel(t.ID)
+		}
+		for _, t := range active {
+			select {
+			case <-t.Done():
+			case <-ctx.Done():
+				for _, t := range active {
+					m.Cancel(t.ID)
+				}
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// schedule запускает задачи из очереди, пока позволяют лимиты.
+func (m *Manager) schedule() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.draining {
+		return
+	}
+
+	var blocked []*Task // Задачи, упёршиеся в лимит своего узла
+	for m.queue.Len() > 0 && (m.maxActive <= 0 || m.active < m.maxActive) {
+		t := heap.Pop(&m.queue).(*Task)
+		slot := peerSlot{t.Peer, t.Direction}
+		if m.maxPerPeer > 0 && m.perPeer[slot] >= m.maxPerPeer {
+			blocked = append(blocked, t)
+			continue
+		}
+		m.active++
+		m.perPeer[slot]++
+		go m.execute(t)
+	}
+	for _, t := range blocked {
+		heap.Push(&m.queue, t)
+	}
+}
+
+// execute выполняет задачу и освобождает её слот по завершении.
+func (m *Manager) execute(t *Task) {
+	t.mu.Lock()
+	t.started = true
+	paused := t.paused
+	t.mu.Unlock()
+
+	t.tracker.Start()
+	if paused {
+		t.tracker.SetPaused(true)
+	}
+
+	err := t.run(t.ctx, t)
+	if t.ctx.Err() != nil && (err == nil || errors.Is(err, context.Canceled)) {
+		err = context.Canceled
+		t.tracker.Cancel()
+	} else {
+		t.tracker.Finish(err)
+	}
+	t.err = err
+	t.mu.Lock()
+	t.canceled = true // Завершённую передачу отменить уже нельзя
+	t.mu.Unlock()
+	t.cancel()
+
+	m.mu.Lock()
+	m.active--
+	slot := peerSlot{t.Peer, t.Direction}
+	if m.perPeer[slot]--; m.perPeer[slot] <= 0 {
+		delete(m.perPeer, slot)
+	}
+	delete(m.tasks, t.ID)
+	m.record(t.Progress())
+	m.mu.Unlock()
+
+	close(t.done)
+	m.schedule()
+}
+
+// record учитывает завершённую передачу в показателях менеджера.
+// Вызывается с захваченным m.mu.
+func (m *Manager) record(p Progress) {
+	d := p.Direction
+	switch p.State {
+	case StateCompleted:
+		m.completed[d]++
+	case StateFailed:
+		m.failed[d]++
+	case StateCanceled:
+		m.canceled[d]++
+	}
+	m.bytes[d] += uint64(p.Done)
+
+	if len(m.history) == HistorySize {
+		m.history = append(m.history[:0], m.history[1:]...)
+	}
+	m.history = append(m.history, p)
+}
+
+// History возвращает снимки последних HistorySize завершённых передач, от старых к новым.
+func (m *Manager) History() []Progress {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.history)
+}
+
+// Stats возвращает показатели передач.
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	st := Stats{
+		Queued:    m.queue.Len(),
+		Active:    m.active,
+		Completed: m.completed,
+		Failed:    m.failed,
+		Canceled:  m.canceled,
+		Bytes:     m.bytes,
+	}
+	// Учёт под m.mu: передача попадает либо в завершённые, либо в идущие, но не в обе
+	for _, t := range m.tasks {
+		if t.index < 0 {
+			p := t.Progress()
+			st.Bytes[p.Direction] += uint64(p.Done)
+		}
+	}
+	m.mu.Unlock()
+	return st
+}
+
+// peerSlot - ключ лимита передач с одним узлом в одном направлении.
+type peerSlot struct {
+	peer string
+	dir  Direction
+}
+
+// taskQueue - очередь задач по убыванию приоритета, при равенстве - по порядку постановки.
+type taskQueue []*Task
+
+func (q taskQueue) Len() int { return len(q) }
+
+func (q taskQueue) Less(i, j int) bool {
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority > q[j].Priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q taskQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *taskQueue) Push(x any) {
+	t := x.(*Task)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+
+func (q *taskQueue) Pop() any {
+	old := *q
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*q = old[:n-1]
+	return t
+}
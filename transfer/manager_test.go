@@ -0,0 +1,240 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitTimeout - предельное время ожидания событий в тестах
+const waitTimeout = 5 * time.Second
+
+// blockingTask создаёт задачу, которая сообщает о запуске в started и ждёт release.
+func blockingTask(id, peer string, dir Direction, priority int, started chan<- string, release <-chan struct{}) *Task {
+	return NewTask(id, id, peer, dir, 0, priority, func(ctx context.Context, t *Task) error {
+		started <- t.ID
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// expectStarted ждёт запуска задачи и возвращает её идентификатор.
+func expectStarted(t *testing.T, started <-chan string) string {
+	t.Helper()
+	select {
+	case id := <-started:
+		return id
+	case <-time.After(waitTimeout):
+		t.Fatal("задача не запущена")
+		return ""
+	}
+}
+
+// expectIdle проверяет, что за короткое время не запущено ни одной задачи.
+func expectIdle(t *testing.T, started <-chan string) {
+	t.Helper()
+	select {
+	case id := <-started:
+		t.Fatalf("задача %s запущена сверх лимита", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestManagerPriority(t *testing.T) {
+	m := NewManager(1, 0, nil)
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	defer close(release)
+
+	// Первая задача занимает единственный слот, остальные выстраиваются в очередь
+	if err := m.Enqueue(blockingTask("first", "a", DirectionUpload, PriorityNormal, started, release)); err != nil {
+		t.Fatal(err)
+	}
+	expectStarted(t, started)
+
+	gate := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	for _, tt := range []struct {
+		id       string
+		priority int
+	}{
+		{"low", PriorityLow},
+		{"normal-1", PriorityNormal},
+		{"high", PriorityHigh},
+		{"normal-2", PriorityNormal},
+	} {
+		task := NewTask(tt.id, tt.id, "a", DirectionUpload, 0, tt.priority, func(ctx context.Context, t *Task) error {
+			<-gate
+			mu.Lock()
+			order = append(order, t.ID)
+			mu.Unlock()
+			return nil
+		})
+		if err := m.Enqueue(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(gate)
+	release <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	for m.Stats().Completed[DirectionUpload] < 5 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("задачи не завершились")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	want := []string{"high", "normal-1", "normal-2", "low"}
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("порядок запуска %v, ожидался %v", order, want)
+		}
+	}
+}
+
+func TestManagerPerPeerLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		tasks   []peerSlot // Узел и направление задач в порядке постановки
+		running int        // Сколько задач должно запуститься при лимите 1 на узел
+	}{
+		{"один узел, одно направление", []peerSlot{{"a", DirectionUpload}, {"a", DirectionUpload}}, 1},
+		{"разные узлы", []peerSlot{{"a", DirectionUpload}, {"b", DirectionUpload}}, 2},
+		// Отправки, ждущие согласия узла, не должны мешать приёму его файлов
+		{"встречные передачи", []peerSlot{{"a", DirectionUpload}, {"a", DirectionDownload}}, 2},
+		{"две отправки и два приёма", []peerSlot{
+			{"a", DirectionUpload}, {"a", DirectionUpload},
+			{"a", DirectionDownload}, {"a", DirectionDownload},
+		}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(0, 1, nil)
+			started := make(chan string, len(tt.tasks))
+			release := make(chan struct{})
+			defer close(release)
+
+			for i, slot := range tt.tasks {
+				id := string(rune('0' + i))
+				if err := m.Enqueue(blockingTask(id, slot.peer, slot.dir, PriorityNormal, started, release)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < tt.running; i++ {
+				expectStarted(t, started)
+			}
+			expectIdle(t, started)
+			if st := m.Stats(); st.Active != tt.running || st.Queued != len(tt.tasks)-tt.running {
+				t.Fatalf("активных %d, в очереди %d; ожидалось %d и %d",
+					st.Active, st.Queued, tt.running, len(tt.tasks)-tt.running)
+			}
+		})
+	}
+}
+
+func TestManagerDuplicateID(t *testing.T) {
+	m := NewManager(0, 0, nil)
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	defer close(release)
+
+	if err := m.Enqueue(blockingTask("same", "a", DirectionDownload, PriorityNormal, started, release)); err != nil {
+		t.Fatal(err)
+	}
+	expectStarted(t, started)
+
+	err := m.Enqueue(blockingTask("same", "b", DirectionDownload, PriorityNormal, started, release))
+	if !errors.Is(err, ErrDuplicateTask) {
+		t.Fatalf("Enqueue: %v, ожидалась ErrDuplicateTask", err)
+	}
+	if task, _ := m.Get("same"); task.Peer != "a" {
+		t.Fatalf("дубликат заменил исходную передачу: узел %s", task.Peer)
+	}
+}
+
+func TestManagerCancelQueued(t *testing.T) {
+	m := NewManager(1, 0, nil)
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	defer close(release)
+
+	if err := m.Enqueue(blockingTask("running", "a", DirectionUpload, PriorityNormal, started, release)); err != nil {
+		t.Fatal(err)
+	}
+	expectStarted(t, started)
+
+	canceled := make(chan struct{})
+	queued := blockingTask("queued", "a", DirectionUpload, PriorityNormal, started, release)
+	queued.OnCancel(func() { close(canceled) })
+	if err := m.Enqueue(queued); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Cancel("queued"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-queued.Done():
+	case <-time.After(waitTimeout):
+		t.Fatal("отменённая задача не завершилась")
+	}
+	select {
+	case <-canceled:
+	default:
+		t.Fatal("OnCancel не вызван")
+	}
+	if got := queued.Progress().State; got != StateCanceled {
+		t.Fatalf("состояние %s, ожидалось %s", got, StateCanceled)
+	}
+	if err := m.Cancel("unknown"); !errors.Is(err, ErrUnknownTask) {
+		t.Fatalf("Cancel неизвестной передачи: %v", err)
+	}
+}
+
+func TestManagerProgressPath(t *testing.T) {
+	final := make(chan Progress, 1)
+	m := NewManager(0, 0, func(p Progress) {
+		if p.Finished() {
+			final <- p
+		}
+	})
+	task := NewTask("id", "f.txt", "a", DirectionDownload, 3, PriorityNormal, func(ctx context.Context, t *Task) error {
+		t.Add(3)
+		t.SetPath("/downloads/f (1).txt")
+		return nil
+	})
+	if err := m.Enqueue(task); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-final:
+		if p.State != StateCompleted || p.Path != "/downloads/f (1).txt" || p.Done != 3 {
+			t.Fatalf("итоговое событие %+v", p)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("нет события о завершении")
+	}
+	if h := m.History(); len(h) != 1 || h[0].Path != "/downloads/f (1).txt" {
+		t.Fatalf("история %+v", h)
+	}
+}
+
+func TestTaskBeforeEnqueue(t *testing.T) {
+	task := NewTask("id", "f.txt", "a", DirectionUpload, 10, PriorityNormal, nil)
+	task.Add(4)
+	task.SetPath("f.txt")
+	if p := task.Progress(); p.ID != "id" || p.State != StateQueued || p.Done != 4 || p.Path != "f.txt" {
+		t.Fatalf("прогресс задачи вне очереди %+v", p)
+	}
+}
@@ -0,0 +1,256 @@
+package transfer
+
+import (
+	"sync"
+	"time"
+)
+
+// State - состояние передачи файла.
+type State int
+
+const (
+	StateQueued    State = iota // Передача ожидает начала
+	StateRunning                // Идёт передача данных
+	StatePaused                 // Передача приостановлена
+	StateCompleted              // Передача успешно завершена
+	StateFailed                 // Передача завершилась ошибкой
+	StateCanceled               // Передача отменена
+)
+
+// String возвращает название состояния.
+func (s State) String() string {
+	switch s {
+	case StateQueued:
+		return "queued"
+	case StateRunning:
+		return "running"
+	case StatePaused:
+		return "paused"
+	case StateCompleted:
+		return "completed"
+	case StateFailed:
+		return "failed"
+	case StateCanceled:
+		return "canceled"
+	}
+	return "unknown"
+}
+
+// Direction - направление передачи относительно локального узла.
+type Direction int
+
+const (
+	DirectionUpload   Direction = iota // Отправка файла
+	DirectionDownload                  // Приём файла
+)
+
+// String возвращает название направления.
+func (d Direction) String() string {
+	if d == DirectionDownload {
+		return "download"
+	}
+	return "upload"
+}
+
+// Progress - снимок состояния передачи файла.
+type Progress struct {
+	ID        string        // Идентификатор передачи
+	Filename  string        // Имя файла
+	Peer      string        // Адрес удалённого узла
+	Direction Direction     // Направление передачи
+	State     State         // Текущее состояние
+	Done      int64         // Передано байт
+	Total     int64         // Размер файла в байтах
+	Rate      float64       // Скорость передачи, байт/с
+	ETA       time.Duration // Оценка оставшегося времени (0 - неизвестно)
+	Err       error         // Ошибка для StateFailed
+	Path      string        // Путь, под которым сохранён принятый файл (после завершения приёма)
+}
+
+// Percent возвращает долю переданных данных в процентах.
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		if p.State == StateCompleted {
+			return 100
+		}
+		return 0
+	}
+	return float64(p.Done) * 100 / float64(p.Total)
+}
+
+// Finished сообщает, завершена ли передача (успешно или нет).
+func (p Progress) Finished() bool {
+	return p.State == StateCompleted || p.State == StateFailed || p.State == StateCanceled
+}
+
+// ProgressFunc получает события о ходе передачи.
+// Вызывается синхронно из горутины передачи, поэтому не должна блокироваться надолго.
+type ProgressFunc func(Progress)
+
+// ProgressChan возвращает ProgressFunc, отправляющую события в канал ch.
+// Если канал заполнен, промежуточные события отбрасываются, а финальные доставляются всегда.
+func ProgressChan(ch chan<- Progress) ProgressFunc {
+	return func(p Progress) {
+		if p.Finished() {
+			ch <- p
+			return
+		}
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+const (
+	// progressInterval - минимальный интервал между промежуточными событиями
+	progressInterval = 200 * time.Millisecond
+	// rateSmoothing - коэффициент сглаживания скорости (экспоненциальное среднее)
+	rateSmoothing = 0.3
+)
+
+// Tracker считает прогресс передачи и сообщает о нём через ProgressFunc.
+type Tracker struct {
+	mu       sync.Mutex
+	progress Progress
+	notify   ProgressFunc
+
+	lastTime time.Time // Время последнего пересчёта скорости
+	lastDone int64     // Значение Done при последнем пересчёте
+}
+
+// NewTracker создаёт счётчик прогресса. notify может быть nil.
+func NewTracker(id, filename, peer string, dir Direction, total int64, notify ProgressFunc) *Tracker {
+	return &Tracker{
+		progress: Progress{
+			ID:        id,
+			Filename:  filename,
+			Peer:      peer,
+			Direction: dir,
+			State:     StateQueued,
+			Total:     total,
+		},
+		notify:   notify,
+		lastTime: time.Now(),
+	}
+}
+
+// Start переводит передачу в состояние StateRunning.
+func (t *Tracker) Start() {
+	t.mu.Lock()
+	t.progress.State = StateRunning
+	t.lastTime = time.Now()
+	t.lastDone = t.progress.Done
+	p := t.progress
+	t.mu.Unlock()
+	t.emit(p)
+}
+
+// SetPaused переводит передачу в состояние StatePaused или возвращает в StateRunning.
+func (t *Tracker) SetPaused(paused bool) {
+	t.mu.Lock()
+	if t.progress.Finished() {
+		t.mu.Unlock()
+		return
+	}
+	if paused {
+		t.progress.State = StatePaused
+		t.progress.Rate, t.progress.ETA = 0, 0
+	} else {
+		t.progress.State = StateRunning
+		t.lastTime, t.lastDone = time.Now(), t.progress.Done
+	}
+	p := t.progress
+	t.mu.Unlock()
+	t.emit(p)
+}
+
+// Add учитывает n переданных байт.
+func (t *Tracker) Add(n int) {
+	t.mu.Lock()
+	t.progress.Done += int64(n)
+
+	now := time.Now()
+	elapsed := now.Sub(t.lastTime)
+	if elapsed < progressInterval && t.progress.Done < t.progress.Total {
+		t.mu.Unlock()
+		return
+	}
+
+	if elapsed > 0 {
+		rate := float64(t.progress.Done-t.lastDone) / elapsed.Seconds()
+		if t.progress.Rate == 0 {
+			t.progress.Rate = rate
+		} else {
+			t.progress.Rate = rateSmoothing*rate + (1-rateSmoothing)*t.progress.Rate
+		}
+		t.lastTime, t.lastDone = now, t.progress.Done
+	}
+
+	t.progress.ETA = 0
+	if t.progress.Rate > 0 && t.progress.Total > t.progress.Done {
+		remaining := float64(t.progress.Total - t.progress.Done)
+		t.progress.ETA = time.Duration(remaining / t.progress.Rate * float64(time.Second))
+	}
+	p := t.progress
+	t.mu.Unlock()
+	t.emit(p)
+}
+
+// SetPath запоминает путь сохранённого файла; он попадёт в событие о завершении.
+func (t *Tracker) SetPath(path string) {
+	t.mu.Lock()
+	t.progress.Path = path
+	t.mu.Unlock()
+}
+
+// Finish завершает передачу: err == nil - успешно, иначе - с ошибкой.
+func (t *Tracker) Finish(err error) {
+	if err != nil {
+		t.finish(StateFailed, err)
+		return
+	}
+	t.finish(StateCompleted, nil)
+}
+
+// Cancel помечает передачу как отменённую.
+func (t *Tracker) Cancel() {
+	t.finish(StateCanceled, nil)
+}
+
+func (t *Tracker) finish(state State, err error) {
+	t.mu.Lock()
+	if t.progress.Finished() {
+		t.mu.Unlock()
+		return
+	}
+	t.progress.State = state
+	t.progress.Err = err
+	t.progress.ETA = 0
+	p := t.progress
+	t.mu.Unlock()
+	t.emit(p)
+}
+
+// Progress возвращает текущий снимок прогресса.
+func (t *Tracker) Progress() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress
+}
+
+// setNotify задаёт получателя событий; Manager подключает его при постановке в очередь.
+func (t *Tracker) setNotify(notify ProgressFunc) {
+	t.mu.Lock()
+	t.notify = notify
+	t.mu.Unlock()
+}
+
+func (t *Tracker) emit(p Progress) {
+	t.mu.Lock()
+	notify := t.notify
+	t.mu.Unlock()
+	if notify != nil {
+		notify(p)
+	}
+}
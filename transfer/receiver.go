@@ -0,0 +1,238 @@
+package transfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxNameLen - максимальная длина имени файла в байтах
+const maxNameLen = 255
+
+// reservedNames - имена устройств, недопустимые в качестве имени файла в Windows
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename приводит имя файла, полученное от удалённого узла, к безопасному виду.
+// От пути остаётся только последний элемент, удаляются управляющие и
+// зарезервированные символы, а также ведущие точки (скрытые файлы).
+func SanitizeFilename(name string) (string, error) {
+	// Разделители обеих платформ считаются разделителями пути
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = path.Base(name)
+
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(name, ". ")
+	name = strings.TrimRight(name, ". ")
+
+	if name == "" {
+		return "", ErrInvalidName
+	}
+
+	stem := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	if reservedNames[stem] {
+		name = "_" + name
+	}
+
+	if len(name) > maxNameLen {
+		ext := filepath.Ext(name)
+		if len(ext) > maxNameLen/2 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxNameLen-len(ext)], "") + ext
+	}
+	return name, nil
+}
+
+// Receiver принимает файлы в заданный корневой каталог.
+// Имена файлов очищаются, а совпадающие имена получают числовой суффикс.
+type Receiver struct {
+	Dir string // Абсолютный путь к каталогу загрузок
+
+	mu sync.Mutex // Защищает выбор свободного имени и публикацию файла
+}
+
+// NewReceiver создаёт приёмник файлов для каталога dir, создавая его при необходимости.
+func NewReceiver(dir string) (*Receiver, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("transfer: некорректный каталог загрузок %q: %w", dir, err)
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("transfer: не удалось создать каталог загрузок: %w", err)
+	}
+	return &Receiver{Dir: abs}, nil
+}
+
+// Begin начинает приём файла по предложению offer.
+// Данные записываются во временный файл внутри каталога загрузок.
+func (r *Receiver) Begin(offer Offer) (*Download, error) {
+	name, err := SanitizeFilename(offer.Filename)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", err, offer.Filename)
+	}
+	if offer.Size < 0 {
+		return nil, ErrSizeMismatch
+	}
+
+	tmp, err := os.CreateTemp(r.Dir, ".p2pfs-*.part")
+	if err != nil {
+		return nil, fmt.Errorf("transfer: не удалось создать временный файл: %w", err)
+	}
+
+	return &Download{
+		Offer:    offer,
+		Name:     name,
+		receiver: r,
+		file:     tmp,
+		hash:     sha256.New(),
+	}, nil
+}
+
+// maxCandidates - число вариантов имени "name (n).ext", перебираемых при совпадении имён
+const maxCandidates = 10000
+
+// commit переносит временный файл под свободным именем в каталоге загрузок.
+// Существующий файл не перезаписывается, даже если его создал другой процесс:
+// занятое имя обнаруживается при публикации, и пробуется следующее.
+func (r *Receiver) commit(tmpPath, name string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := 0; i < maxCandidates; i++ {
+		target, err := r.candidate(name, i)
+		if err != nil {
+			return "", err
+		}
+		err = publish(tmpPath, target)
+		if err == nil {
+			return target, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("transfer: не удалось сохранить файл: %w", err)
+		}
+	}
+	return "", fmt.Errorf("transfer: не найдено свободное имя для %q", name)
+}
+
+// candidate возвращает i-й вариант пути для файла name внутри каталога загрузок:
+// само имя, затем "name (1).ext", "name (2).ext" и т. д.
+func (r *Receiver) candidate(name string, i int) (string, error) {
+	candidate := name
+	if i > 0 {
+		ext := filepath.Ext(name)
+		candidate = strings.TrimSuffix(name, ext) + " (" + strconv.Itoa(i) + ")" + ext
+	}
+	target := filepath.Join(r.Dir, candidate)
+
+	// Дополнительная проверка: итоговый путь обязан лежать в каталоге загрузок
+	if rel, err := filepath.Rel(r.Dir, target); err != nil || rel != candidate {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return target, nil
+}
+
+// publish переносит файл tmpPath в target, не заменяя существующий файл.
+// Если target уже существует, возвращает ошибку, для которой errors.Is(err, fs.ErrExist).
+func publish(tmpPath, target string) error {
+	// Жёсткая ссылка создаётся атомарно и только на свободном имени
+	err := os.Link(tmpPath, target)
+	if err == nil {
+		os.Remove(tmpPath)
+		return nil
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	// Файловая система без жёстких ссылок: занимаем имя пустым файлом и заменяем его
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	if err := os.Rename(tmpPath, target); err != nil {
+		os.Remove(target)
+		return err
+	}
+	return nil
+}
+
+// Download - файл, принимаемый в данный момент.
+type Download struct {
+	Offer
+	Name string // Очищенное имя файла
+
+	receiver *Receiver
+	file     *os.File
+	hash     hash.Hash
+	written  int64
+}
+
+// Write записывает очередной блок данных во временный файл.
+func (d *Download) Write(p []byte) (int, error) {
+	if d.written+int64(len(p)) > d.Size {
+		return 0, ErrSizeMismatch
+	}
+	n, err := d.file.Write(p)
+	d.hash.Write(p[:n])
+	d.written += int64(n)
+	return n, err
+}
+
+// Written возвращает количество уже принятых байт.
+func (d *Download) Written() int64 {
+	return d.written
+}
+
+// Commit завершает приём: проверяет размер и контрольную сумму и только после
+// этого атомарно публикует временный файл под свободным именем. Возвращает итоговый путь.
+// При любой ошибке временный файл удаляется.
+func (d *Download) Commit() (string, error) {
+	tmpPath := d.file.Name()
+	if err := d.file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("transfer: не удалось записать файл: %w", err)
+	}
+	if d.written != d.Size {
+		os.Remove(tmpPath)
+		return "", ErrSizeMismatch
+	}
+	if sum := hex.EncodeToString(d.hash.Sum(nil)); !strings.EqualFold(sum, d.Hash) {
+		os.Remove(tmpPath)
+		return "", ErrHashMismatch
+	}
+
+	target, err := d.receiver.commit(tmpPath, d.Name)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return target, nil
+}
+
+// Abort прерывает приём и удаляет временный файл.
+func (d *Download) Abort() {
+	d.file.Close()
+	os.Remove(d.file.Name())
+}
@@ -0,0 +1,178 @@
+package transfer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  error
+	}{
+		{"обычное имя", "report.pdf", "report.pdf", nil},
+		{"путь unix", "../../etc/passwd", "passwd", nil},
+		{"путь windows", `..\..\Windows\system.ini`, "system.ini", nil},
+		{"скрытый файл", ".bashrc", "bashrc", nil},
+		{"управляющие символы", "a\x00b\nc.txt", "abc.txt", nil},
+		{"зарезервированные символы", `a<b>c:d"e|f?g*h.txt`, "abcdefgh.txt", nil},
+		{"устройство windows", "CON.txt", "_CON.txt", nil},
+		{"устройство в нижнем регистре", "lpt1", "_lpt1", nil},
+		{"точки и пробелы по краям", " . name . ", "name", nil},
+		{"только точки", "..", "", ErrInvalidName},
+		{"пустое имя", "", "", ErrInvalidName},
+		{"каталог", "dir/", "dir", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizeFilename(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SanitizeFilename(%q): ошибка %v, ожидалась %v", tt.in, err, tt.err)
+			}
+			if got != tt.want {
+				t.Fatalf("SanitizeFilename(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameLong(t *testing.T) {
+	got, err := SanitizeFilename(strings.Repeat("я", 300) + ".txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) > maxNameLen {
+		t.Fatalf("длина %d больше %d", len(got), maxNameLen)
+	}
+	if !strings.HasSuffix(got, ".txt") {
+		t.Fatalf("потеряно расширение: %q", got)
+	}
+}
+
+// receive принимает data под именем name и возвращает путь сохранённого файла.
+func receive(t *testing.T, r *Receiver, name string, data []byte) (string, error) {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	d, err := r.Begin(Offer{ID: NewID(), Filename: name, Size: int64(len(data)), Hash: hex.EncodeToString(sum[:])})
+	if err != nil {
+		return "", err
+	}
+	if _, err := d.Write(data); err != nil {
+		d.Abort()
+		return "", err
+	}
+	return d.Commit()
+}
+
+func TestReceiverCommit(t *testing.T) {
+	r, err := NewReceiver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := receive(t, r, "../secret.txt", []byte("one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := receive(t, r, "secret.txt", []byte("two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("второй файл записан поверх первого: %s", first)
+	}
+	for path, want := range map[string]string{first: "one", second: "two"} {
+		if filepath.Dir(path) != r.Dir {
+			t.Errorf("%s сохранён вне каталога загрузок %s", path, r.Dir)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != want {
+			t.Errorf("%s: содержимое %q (%v), ожидалось %q", path, data, err, want)
+		}
+	}
+}
+
+func TestReceiverKeepsExistingFile(t *testing.T) {
+	r, err := NewReceiver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(r.Dir, "data.bin")
+	if err := os.WriteFile(existing, []byte("local"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := receive(t, r, "data.bin", []byte("remote"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path == existing {
+		t.Fatal("существующий файл перезаписан")
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "local" {
+		t.Fatalf("существующий файл изменён: %q", data)
+	}
+}
+
+func TestReceiverConcurrentCommit(t *testing.T) {
+	r, err := NewReceiver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 16
+	var wg sync.WaitGroup
+	paths := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = receive(t, r, "same.txt", []byte{byte(i)})
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("приём %d: %v", i, errs[i])
+		}
+		if seen[paths[i]] {
+			t.Fatalf("два файла сохранены под именем %s", paths[i])
+		}
+		seen[paths[i]] = true
+		if data, _ := os.ReadFile(paths[i]); !bytes.Equal(data, []byte{byte(i)}) {
+			t.Fatalf("%s: содержимое %v, ожидалось %v", paths[i], data, []byte{byte(i)})
+		}
+	}
+}
+
+func TestReceiverHashMismatch(t *testing.T) {
+	r, err := NewReceiver(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := r.Begin(Offer{ID: NewID(), Filename: "f.txt", Size: 3, Hash: strings.Repeat("0", 64)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Commit(); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("Commit: %v, ожидалась ErrHashMismatch", err)
+	}
+	if entries, _ := os.ReadDir(r.Dir); len(entries) != 0 {
+		t.Fatalf("после ошибки в каталоге остались файлы: %v", entries)
+	}
+}
@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// HistorySize - сколько введённых строк хранит история редактора
+const HistorySize = 500
+
+// CompleteFunc возвращает варианты дополнения слова word; args - слова строки перед ним.
+type CompleteFunc func(args []string, word string) []string
+
+// Editor - строка ввода с историей и дополнением по Tab.
+type Editor struct {
+	Complete CompleteFunc // Дополнение по Tab (nil - без дополнения)
+
+	line    []rune
+	pos     int      // Позиция курсора в line
+	history []string // Введённые строки, последняя - в конце
+	histPos int      // Позиция в истории; len(history) - новая строка
+	draft   []rune   // Недописанная строка на время просмотра истории
+	hint    []string // Варианты дополнения, показываемые под строкой
+}
+
+// Line возвращает текущую строку и позицию курсора в ней (в символах).
+func (e *Editor) Line() (string, int) {
+	return string(e.line), e.pos
+}
+
+// Hint возвращает варианты последнего неоднозначного дополнения.
+func (e *Editor) Hint() []string {
+	return e.hint
+}
+
+// HandleKey обрабатывает нажатие. По Enter возвращает введённую строку и true
+// и добавляет её в историю.
+func (e *Editor) HandleKey(k Key) (string, bool) {
+	if k.Code != KeyTab {
+		e.hint = nil
+	}
+	switch k.Code {
+	case KeyRune:
+		e.insert(k.Rune)
+	case KeyEnter:
+		line := string(e.line)
+		e.addHistory(line)
+		e.line, e.pos, e.draft = nil, 0, nil
+		return line, true
+	case KeyTab:
+		e.complete()
+	case KeyBackspace:
+		if e.pos > 0 {
+			e.line = append(e.line[:e.pos-1], e.line[e.pos:]...)
+			e.pos--
+		}
+	case KeyDelete:
+		if e.pos < len(e.line) {
+			e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+		}
+	case KeyLeft:
+		e.pos = max(e.pos-1, 0)
+	case KeyRight:
+		e.pos = min(e.pos+1, len(e.line))
+	case KeyHome:
+		e.pos = 0
+	case KeyEnd:
+		e.pos = len(e.line)
+	case KeyUp:
+		e.browse(-1)
+	case KeyDown:
+		e.browse(1)
+	case KeyCtrlK:
+		e.line = e.line[:e.pos]
+	case KeyCtrlU:
+		e.line = append([]rune(nil), e.line[e.pos:]...)
+		e.pos = 0
+	case KeyCtrlW:
+		start := e.pos
+		for start > 0 && unicode.IsSpace(e.line[start-1]) {
+			start--
+		}
+		start = wordStart(e.line, start)
+		e.line = append(e.line[:start], e.line[e.pos:]...)
+		e.pos = start
+	}
+	return "", false
+}
+
+// insert вставляет символы в позицию курсора.
+func (e *Editor) insert(runes ...rune) {
+	tail := append([]rune(nil), e.line[e.pos:]...)
+	e.line = append(append(e.line[:e.pos], runes...), tail...)
+	e.pos += len(runes)
+}
+
+// addHistory добавляет непустую строку в историю, пропуская повтор последней.
+func (e *Editor) addHistory(line string) {
+	if strings.TrimSpace(line) != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != line) {
+		e.history = append(e.history, line)
+		if len(e.history) > HistorySize {
+			e.history = e.history[len(e.history)-HistorySize:]
+		}
+	}
+	e.histPos = len(e.history)
+}
+
+// browse перемещается по истории на delta строк.
+func (e *Editor) browse(delta int) {
+	next := e.histPos + delta
+	if next < 0 || next > len(e.history) {
+		return
+	}
+	if e.histPos == len(e.history) {
+		e.draft = append([]rune(nil), e.line...)
+	}
+	e.histPos = next
+	if next == len(e.history) {
+		e.line = e.draft
+	} else {
+		e.line = []rune(e.history[next])
+	}
+	e.pos = len(e.line)
+}
+
+// wordStart возвращает начало слова под курсором.
+func (e *Editor) wordStart() int {
+	return wordStart(e.line, e.pos)
+}
+
+// wordStart возвращает начало слова, заканчивающегося в позиции pos.
+func wordStart(line []rune, pos int) int {
+	for pos > 0 && !unicode.IsSpace(line[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// complete дополняет слово под курсором: единственный вариант подставляется целиком,
+// при нескольких - общий префикс, а сами варианты показываются в подсказке.
+func (e *Editor) complete() {
+	if e.Complete == nil {
+		return
+	}
+	start := e.wordStart()
+	word := string(e.line[start:e.pos])
+	args := strings.Fields(string(e.line[:start]))
+	candidates := e.Complete(args, word)
+	switch len(candidates) {
+	case 0:
+		e.hint = nil
+		return
+	case 1:
+		e.hint = nil
+		e.replaceWord(start, candidates[0]+" ")
+		return
+	}
+	e.hint = candidates
+	if prefix := commonPrefix(candidates); len([]rune(prefix)) > len([]rune(word)) {
+		e.replaceWord(start, prefix)
+	}
+}
+
+// replaceWord заменяет текст от start до курсора на s.
+func (e *Editor) replaceWord(start int, s string) {
+	e.line = append(e.line[:start], e.line[e.pos:]...)
+	e.pos = start
+	e.insert([]rune(s)...)
+}
+
+// commonPrefix возвращает общий префикс строк.
+func commonPrefix(list []string) string {
+	prefix := []rune(list[0])
+	for _, s := range list[1:] {
+		runes := []rune(s)
+		n := 0
+		for n < len(prefix) && n < len(runes) && prefix[n] == runes[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
@@ -0,0 +1,102 @@
+package tui
+
+import "unicode/utf8"
+
+// KeyCode - код нажатой клавиши.
+type KeyCode int
+
+const (
+	KeyNone      KeyCode = iota // Неизвестная последовательность
+	KeyRune                     // Печатный символ (Key.Rune)
+	KeyEnter                    // Enter
+	KeyTab                      // Tab
+	KeyBackspace                // Backspace
+	KeyDelete                   // Delete
+	KeyEscape                   // Esc
+	KeyUp                       // Стрелка вверх
+	KeyDown                     // Стрелка вниз
+	KeyLeft                     // Стрелка влево
+	KeyRight                    // Стрелка вправо
+	KeyHome                     // Home, Ctrl+A
+	KeyEnd                      // End, Ctrl+E
+	KeyPageUp                   // Page Up
+	KeyPageDown                 // Page Down
+	KeyCtrlC                    // Ctrl+C
+	KeyCtrlD                    // Ctrl+D
+	KeyCtrlK                    // Ctrl+K - удалить до конца строки
+	KeyCtrlL                    // Ctrl+L - перерисовать экран
+	KeyCtrlU                    // Ctrl+U - удалить до начала строки
+	KeyCtrlW                    // Ctrl+W - удалить слово перед курсором
+)
+
+// Key - нажатие клавиши.
+type Key struct {
+	Code KeyCode
+	Rune rune // Символ для KeyRune
+}
+
+// controlKeys - коды управляющих символов.
+var controlKeys = map[byte]KeyCode{
+	0x01: KeyHome,
+	0x02: KeyLeft, // Ctrl+B
+	0x03: KeyCtrlC,
+	0x04: KeyCtrlD,
+	0x05: KeyEnd,
+	0x06: KeyRight, // Ctrl+F
+	0x08: KeyBackspace,
+	0x09: KeyTab,
+	0x0a: KeyEnter,
+	0x0b: KeyCtrlK,
+	0x0c: KeyCtrlL,
+	0x0d: KeyEnter,
+	0x0e: KeyDown, // Ctrl+N
+	0x10: KeyUp,   // Ctrl+P
+	0x15: KeyCtrlU,
+	0x17: KeyCtrlW,
+	0x7f: KeyBackspace,
+}
+
+// escapeKeys - коды последовательностей, начинающихся с ESC [ или ESC O.
+var escapeKeys = map[string]KeyCode{
+	"A": KeyUp, "B": KeyDown, "C": KeyRight, "D": KeyLeft,
+	"H": KeyHome, "F": KeyEnd,
+	"1~": KeyHome, "7~": KeyHome, "4~": KeyEnd, "8~": KeyEnd,
+	"3~": KeyDelete, "5~": KeyPageUp, "6~": KeyPageDown,
+}
+
+// parseKey разбирает первое нажатие в data и возвращает его и число прочитанных байт.
+func parseKey(data []byte) (Key, int) {
+	b := data[0]
+	if b == 0x1b {
+		return parseEscape(data)
+	}
+	if code, ok := controlKeys[b]; ok {
+		return Key{Code: code}, 1
+	}
+	if b < 0x20 {
+		return Key{}, 1
+	}
+	r, size := utf8.DecodeRune(data)
+	if r == utf8.RuneError {
+		return Key{}, 1
+	}
+	return Key{Code: KeyRune, Rune: r}, size
+}
+
+// parseEscape разбирает последовательность, начинающуюся с ESC.
+// Одиночный ESC в конце прочитанного блока считается нажатием Esc.
+func parseEscape(data []byte) (Key, int) {
+	if len(data) < 2 || (data[1] != '[' && data[1] != 'O') {
+		return Key{Code: KeyEscape}, 1
+	}
+	// Параметры (цифры и ';') и завершающий символ
+	i := 2
+	for i < len(data) && (data[i] >= '0' && data[i] <= '9' || data[i] == ';') {
+		i++
+	}
+	if i == len(data) {
+		return Key{}, len(data)
+	}
+	seq := string(data[2 : i+1])
+	return Key{Code: escapeKeys[seq]}, i + 1
+}
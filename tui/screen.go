@@ -0,0 +1,204 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Style - оформление строки панели.
+type Style int
+
+const (
+	StyleNormal Style = iota // Обычный текст
+	StyleDim                 // Второстепенный текст
+	StyleBold                // Выделенный текст
+	StyleAccent              // Цветной акцент
+	StyleError               // Ошибка
+)
+
+// escape возвращает управляющую последовательность стиля.
+func (s Style) escape() string {
+	switch s {
+	case StyleDim:
+		return "\033[2m"
+	case StyleBold:
+		return "\033[1m"
+	case StyleAccent:
+		return "\033[36m"
+	case StyleError:
+		return "\033[31m"
+	}
+	return ""
+}
+
+// Line - строка панели.
+type Line struct {
+	Text  string
+	Style Style
+}
+
+// Pane - панель с рамкой и заголовком.
+type Pane struct {
+	Title string
+	Lines []Line
+}
+
+// Frame - содержимое экрана: боковая панель слева, основная справа,
+// нижняя панель во всю ширину, строка состояния и строка ввода.
+type Frame struct {
+	Side   Pane // Боковая панель; длинные строки обрезаются
+	Main   Pane // Основная панель; длинные строки переносятся, показывается конец
+	Bottom Pane // Нижняя панель; высота подстраивается под число строк
+
+	Scroll int    // На сколько строк основная панель прокручена назад от конца
+	Status Line   // Строка состояния
+	Prompt string // Приглашение строки ввода
+	Input  string // Строка ввода
+	Cursor int    // Позиция курсора в Input (в символах)
+}
+
+// Минимальные размеры областей экрана
+const (
+	minSideWidth  = 20
+	maxSideWidth  = 36
+	minPaneHeight = 3
+)
+
+// MainHeight возвращает число строк текста основной панели при высоте экрана height
+// и числе строк нижней панели bottom - шаг прокрутки на страницу.
+func MainHeight(height, bottom int) int {
+	return max(height-2-bottomHeight(height, bottom)-2, 1)
+}
+
+// bottomHeight возвращает высоту нижней панели с рамкой.
+func bottomHeight(height, lines int) int {
+	return min(max(lines+2, minPaneHeight), max((height-2)/3, minPaneHeight))
+}
+
+// render формирует вывод кадра для экрана width x height.
+func (f *Frame) render(width, height int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\033[?25l\033[H")
+
+	top := max(height-2-bottomHeight(height, len(f.Bottom.Lines)), minPaneHeight)
+	sideWidth := min(max(width/4, minSideWidth), maxSideWidth)
+	if width < 3*minSideWidth {
+		sideWidth = 0 // Узкий экран: только основная панель
+	}
+
+	rows := make([]string, 0, height)
+	side := f.Side.render(sideWidth, top, false, 0)
+	main := f.Main.render(width-sideWidth, top, true, f.Scroll)
+	for i := 0; i < top; i++ {
+		row := main[i]
+		if sideWidth > 0 {
+			row = side[i] + row
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, f.Bottom.render(width, height-2-top, false, 0)...)
+	rows = append(rows, styled(pad(f.Status.Text, width), f.Status.Style))
+
+	// Строка ввода с горизонтальной прокруткой до курсора
+	input := []rune(f.Input)
+	avail := max(width-utf8.RuneCountInString(f.Prompt)-1, 1)
+	offset := max(f.Cursor-avail, 0)
+	visible := input[offset:min(len(input), offset+avail)]
+	rows = append(rows, pad(f.Prompt+string(visible), width))
+
+	for i, row := range rows {
+		if i >= height {
+			break
+		}
+		fmt.Fprintf(&buf, "\033[%d;1H%s", i+1, row)
+	}
+	col := utf8.RuneCountInString(f.Prompt) + f.Cursor - offset + 1
+	fmt.Fprintf(&buf, "\033[%d;%dH\033[?25h", height, min(col, width))
+	return buf.Bytes()
+}
+
+// render возвращает height строк панели шириной width с рамкой.
+// wrap переносит длинные строки и показывает конец текста, сдвинутый назад на scroll строк.
+func (p *Pane) render(width, height int, wrap bool, scroll int) []string {
+	if width <= 0 || height <= 0 {
+		return make([]string, max(height, 0))
+	}
+	inner := max(width-2, 0)
+	body := max(height-2, 0)
+
+	var lines []Line
+	if wrap {
+		for _, l := range p.Lines {
+			for _, part := range wrapText(l.Text, inner) {
+				lines = append(lines, Line{Text: part, Style: l.Style})
+			}
+		}
+		end := max(len(lines)-scroll, min(body, len(lines)))
+		lines = lines[max(end-body, 0):end]
+	} else {
+		lines = p.Lines
+		if len(lines) > body && body > 0 {
+			more := len(lines) - body + 1
+			lines = append(lines[:body-1:body-1], Line{Text: fmt.Sprintf("… ещё %d", more), Style: StyleDim})
+		}
+	}
+
+	title := ""
+	if p.Title != "" {
+		title = truncate("─ "+p.Title+" ", inner)
+	}
+	out := make([]string, 0, height)
+	out = append(out, "┌"+styled(title, StyleBold)+strings.Repeat("─", inner-utf8.RuneCountInString(title))+"┐")
+	for i := 0; i < body; i++ {
+		text := ""
+		style := StyleNormal
+		if i < len(lines) {
+			text, style = truncate(lines[i].Text, inner), lines[i].Style
+		}
+		out = append(out, "│"+styled(pad(text, inner), style)+"│")
+	}
+	if height > 1 {
+		out = append(out, "└"+strings.Repeat("─", inner)+"┘")
+	}
+	return out[:height]
+}
+
+// styled оформляет s стилем style.
+func styled(s string, style Style) string {
+	if esc := style.escape(); esc != "" {
+		return esc + s + "\033[0m"
+	}
+	return s
+}
+
+// pad дополняет s пробелами до width символов, обрезая длинную строку.
+func pad(s string, width int) string {
+	s = truncate(s, width)
+	return s + strings.Repeat(" ", max(width-utf8.RuneCountInString(s), 0))
+}
+
+// wrapText разбивает s на строки не длиннее width символов, по возможности по пробелам.
+func wrapText(s string, width int) []string {
+	if width <= 0 {
+		return nil
+	}
+	runes := []rune(s)
+	var lines []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, string(runes[:cut]))
+		runes = runes[cut:]
+		if len(runes) > 0 && runes[0] == ' ' {
+			runes = runes[1:]
+		}
+	}
+	return append(lines, string(runes))
+}
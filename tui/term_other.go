@@ -0,0 +1,23 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package tui
+
+import (
+	"errors"
+	"os"
+)
+
+// errUnsupported - полноэкранный режим не поддерживается в этой ОС
+var errUnsupported = errors.New("tui: полноэкранный режим не поддерживается")
+
+// isTerminal сообщает, является ли fd терминалом.
+func isTerminal(uintptr) bool { return false }
+
+// makeRaw не поддерживается.
+func makeRaw(uintptr) (func() error, error) { return nil, errUnsupported }
+
+// termSize не поддерживается.
+func termSize(uintptr) (int, int, error) { return 0, 0, errUnsupported }
+
+// notifyResize ничего не делает: размер опрашивается при каждой отрисовке.
+func notifyResize(chan<- os.Signal) {}
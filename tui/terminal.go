@@ -0,0 +1,117 @@
+// Package tui реализует полноэкранный терминальный интерфейс: «сырой» режим
+// терминала, разбор нажатий клавиш, строку ввода с историей и дополнением,
+// отрисовку панелей.
+package tui
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// Terminal - терминал в полноэкранном режиме.
+type Terminal struct {
+	in      *os.File
+	out     *os.File
+	restore func() error // Восстанавливает режим терминала
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// IsTerminal сообщает, подключён ли f к терминалу.
+func IsTerminal(f *os.File) bool {
+	return isTerminal(f.Fd())
+}
+
+// Open переводит терминал in/out в полноэкранный режим: «сырой» ввод и
+// альтернативный экран. Close возвращает терминал в исходное состояние.
+func Open(in, out *os.File) (*Terminal, error) {
+	if !IsTerminal(in) || !IsTerminal(out) {
+		return nil, errors.New("tui: ввод и вывод должны быть терминалом")
+	}
+	restore, err := makeRaw(in.Fd())
+	if err != nil {
+		return nil, err
+	}
+	t := &Terminal{in: in, out: out, restore: restore}
+	t.out.WriteString("\033[?1049h\033[H\033[2J") // Альтернативный экран
+	return t, nil
+}
+
+// Close восстанавливает режим терминала и возвращается к основному экрану.
+func (t *Terminal) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	t.out.WriteString("\033[0m\033[?25h\033[?1049l")
+	return t.restore()
+}
+
+// Size возвращает размер терминала в символах.
+func (t *Terminal) Size() (width, height int) {
+	width, height, err := termSize(t.out.Fd())
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// Resized возвращает канал, в который приходит значение при изменении размера терминала.
+func (t *Terminal) Resized() <-chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	notifyResize(ch)
+	return ch
+}
+
+// ReadKeys читает нажатия клавиш и передаёт их в keys, пока ввод не закрыт.
+// Канал keys закрывается при ошибке чтения.
+func (t *Terminal) ReadKeys(keys chan<- Key) {
+	defer close(keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := t.in.Read(buf)
+		if err != nil {
+			return
+		}
+		data := buf[:n]
+		for len(data) > 0 {
+			k, size := parseKey(data)
+			data = data[size:]
+			if k.Code != KeyNone {
+				keys <- k
+			}
+		}
+	}
+}
+
+// Draw выводит кадр на экран.
+func (t *Terminal) Draw(f *Frame) {
+	width, height := t.Size()
+	data := f.render(width, height)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.out.Write(data)
+	}
+}
+
+// truncate обрезает s до width символов.
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
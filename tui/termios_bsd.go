@@ -0,0 +1,10 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package tui
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlReadTermios  = unix.TIOCGETA // Чтение режима терминала
+	ioctlWriteTermios = unix.TIOCSETA // Установка режима терминала
+)
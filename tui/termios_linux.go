@@ -0,0 +1,8 @@
+package tui
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlReadTermios  = unix.TCGETS // Чтение режима терминала
+	ioctlWriteTermios = unix.TCSETS // Установка режима терминала
+)